@@ -24,6 +24,52 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/deploy-manager/diff": {
+            "post": {
+                "description": "compare the ManifestWork of a deployment on the hub with the one the job would produce, ignoring server-populated fields",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Diff job manifests",
+                "parameters": [
+                    {
+                        "description": "Job to compare, with the resource name of an existing deployment",
+                        "name": "job",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ManifestDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Job cannot be compared",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/deploy-manager/execute": {
             "get": {
                 "description": "Pull and execute jobs",
@@ -62,6 +108,67 @@ const docTemplate = `{
                 }
             }
         },
+        "/deploy-manager/reconcile": {
+            "post": {
+                "description": "report orphaned, missing and drifted ManifestWorks by comparing the hub with the last job applied to each resource, among the jobs given by the caller",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resources"
+                ],
+                "summary": "Reconcile ManifestWorks",
+                "parameters": [
+                    {
+                        "description": "Jobs of the Job Manager; the last one of each resource is kept",
+                        "name": "jobs",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Job"
+                            }
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Keep the live spec of drifted ManifestWorks and relabel them with their last job",
+                        "name": "adopt",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Create missing ManifestWorks and overwrite drifted ones with the desired manifests",
+                        "name": "recreate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Delete orphaned ManifestWorks that carry a resource ID",
+                        "name": "gc",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReconcileReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/deploy-manager/resource": {
             "get": {
                 "description": "get resource status by id",
@@ -80,7 +187,7 @@ const docTemplate = `{
                         "type": "string",
                         "description": "Resource ID",
                         "name": "uid",
-                        "in": "path",
+                        "in": "query",
                         "required": true
                     },
                     {
@@ -122,13 +229,19 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/deploy-manager/resource/sync": {
+        "/deploy-manager/resource/revisions": {
             "get": {
-                "description": "start sync-up",
+                "description": "list the specs applied to a resource, oldest first",
                 "consumes": [
                     "application/json"
                 ],
@@ -138,19 +251,35 @@ const docTemplate = `{
                 "tags": [
                     "resources"
                 ],
-                "summary": "Start sync-up",
+                "summary": "List resource revisions",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Authentication header",
-                        "name": "Authorization",
-                        "in": "header",
+                        "description": "Resource name",
+                        "name": "resource_name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Node target",
+                        "name": "node_target",
+                        "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Ok",
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Revision"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "type": "string"
                         }
@@ -163,240 +292,1808 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "k8s_io_apimachinery_pkg_apis_meta_v1.ConditionStatus": {
-            "type": "string",
-            "enum": [
-                "True",
-                "False",
-                "Unknown"
-            ],
-            "x-enum-varnames": [
-                "ConditionTrue",
-                "ConditionFalse",
-                "ConditionUnknown"
-            ]
         },
-        "models.Job": {
-            "type": "object",
-            "properties": {
-                "created_at": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "job_group_description": {
-                    "type": "string"
-                },
-                "job_group_id": {
-                    "type": "string"
-                },
-                "job_group_name": {
-                    "type": "string"
-                },
-                "manifests": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.PlainManifest"
+        "/deploy-manager/resource/revisions/{revision}": {
+            "get": {
+                "description": "get a revision of a resource with the specs of its ManifestWorks",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resources"
+                ],
+                "summary": "Get resource revision",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Revision number",
+                        "name": "revision",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resource name",
+                        "name": "resource_name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Node target",
+                        "name": "node_target",
+                        "in": "query",
+                        "required": true
                     }
-                },
-                "namespace": {
-                    "type": "string"
-                },
-                "orchestrator": {
-                    "description": "Locker              *bool            ` + "`" + `json:\"locker,omitempty\"` + "`" + `",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/models.OrchestratorType"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Revision"
                         }
-                    ]
-                },
-                "owner_id": {
-                    "description": "ResourceUID         string           ` + "`" + `json:\"uuid,omitempty\"` + "`" + `",
-                    "type": "string"
-                },
-                "resource": {
-                    "$ref": "#/definitions/models.Resource"
-                },
-                "state": {
-                    "$ref": "#/definitions/models.JobState"
-                },
-                "sub_type": {
-                    "$ref": "#/definitions/models.RemediationType"
-                },
-                "targets": {
-                    "$ref": "#/definitions/models.Target"
-                },
-                "type": {
-                    "$ref": "#/definitions/models.JobType"
-                },
-                "updated_at": {
-                    "type": "string"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Revision not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
                 }
             }
         },
-        "models.JobState": {
-            "type": "integer",
-            "enum": [
-                1,
-                2,
-                3,
-                4
-            ],
-            "x-enum-varnames": [
-                "Applied",
-                "Progressing",
-                "Available",
-                "Degraded"
-            ]
-        },
-        "models.JobType": {
-            "type": "integer",
-            "enum": [
-                5,
-                6,
-                7,
-                8
-            ],
-            "x-enum-varnames": [
-                "CreateDeployment",
-                "DeleteDeployment",
-                "UpdateDeployment",
-                "ReplaceDeployment"
+        "/deploy-manager/resource/rollback": {
+            "post": {
+                "description": "apply a previous revision of the job's resource as a rollback update job, locking and reporting the job like the jobs pulled from the Job Manager",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Roll back a resource",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authentication header",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Job Manager job targeting the resource to roll back",
+                        "name": "job",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision to restore, defaults to remediation.revision of the job",
+                        "name": "revision",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Job could not be locked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Rollback failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Rollback not reported to the Job Manager",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/deploy-manager/resource/sync": {
+            "get": {
+                "description": "start sync-up",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resources"
+                ],
+                "summary": "Start sync-up",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authentication header",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only sync resources of this job group",
+                        "name": "job_group_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only sync resources of this owner",
+                        "name": "owner_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Synced, failed and skipped ManifestWorks by cluster, the ones that could not be matched to a Job Manager resource, and the status batches sent to the Job Manager",
+                        "schema": {
+                            "$ref": "#/definitions/models.SyncSummary"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/deploy-manager/resources": {
+            "get": {
+                "description": "list the resources deployed on the hub with their computed state, filtered, sorted and paginated",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resources"
+                ],
+                "summary": "List resources",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only resources of this cluster",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only resources of this application",
+                        "name": "app_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only resources of this component",
+                        "name": "component",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only resources of this job group",
+                        "name": "job_group_id",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "Pending",
+                            "Applied",
+                            "Progressing",
+                            "Available",
+                            "Degraded"
+                        ],
+                        "type": "string",
+                        "description": "Only resources in this state",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Resources per page, 20 by default and 100 at most",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "name",
+                            "cluster",
+                            "app",
+                            "state",
+                            "created_at"
+                        ],
+                        "type": "string",
+                        "description": "Field to sort by",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort order",
+                        "name": "order",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResourceList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/deploy-manager/resources/events": {
+            "get": {
+                "description": "stream, as server-sent events, the condition changes of the resources deployed on the hub, starting with their current conditions",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "resources"
+                ],
+                "summary": "Stream resource status changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only resources of this cluster",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only resources of this job group",
+                        "name": "job_group_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only the resource with this Job Manager ID",
+                        "name": "resource_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only the resource with this name",
+                        "name": "resource_name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of events, each holding a resource event as JSON",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResourceEvent"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/deploy-manager/resources/{cluster}/{name}": {
+            "get": {
+                "description": "get a resource with the kind, name, namespace, conditions, status feedback and application annotations of each of its objects",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resources"
+                ],
+                "summary": "Get resource detail",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Cluster of the resource",
+                        "name": "cluster",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resource name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResourceDetail"
+                        }
+                    },
+                    "404": {
+                        "description": "Can not find Resource",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/deploy-manager/validate": {
+            "post": {
+                "description": "validate the manifests of a job against the bundled Kubernetes schemas without deploying them",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Validate job manifests",
+                "parameters": [
+                    {
+                        "description": "Job to validate",
+                        "name": "job",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Kubernetes version to validate against, defaults to the version of the job's target cluster",
+                        "name": "kube_version",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ValidationReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid Kubernetes version",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "k8s_io_apimachinery_pkg_apis_meta_v1.ConditionStatus": {
+            "type": "string",
+            "enum": [
+                "True",
+                "False",
+                "Unknown"
+            ],
+            "x-enum-varnames": [
+                "ConditionTrue",
+                "ConditionFalse",
+                "ConditionUnknown"
+            ]
+        },
+        "models.AutoscalerParams": {
+            "type": "object",
+            "properties": {
+                "cpu_utilization": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "description": "Kind is HorizontalPodAutoscaler, the default, or VerticalPodAutoscaler",
+                    "type": "string"
+                },
+                "max_allowed": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "max_replicas": {
+                    "type": "integer"
+                },
+                "memory_utilization": {
+                    "type": "integer"
+                },
+                "min_allowed": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "min_replicas": {
+                    "description": "MinReplicas, MaxReplicas and the average utilization targets, in percent of the requests, of a\nHorizontalPodAutoscaler",
+                    "type": "integer"
+                },
+                "update_mode": {
+                    "description": "UpdateMode (Off, Initial, Recreate or Auto) and the bounds of the recommended requests of a\nVerticalPodAutoscaler",
+                    "type": "string"
+                }
+            }
+        },
+        "models.BatchReport": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "resources": {
+                    "type": "integer"
+                },
+                "status_code": {
+                    "type": "integer"
+                },
+                "succeeded": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.ClusterSync": {
+            "type": "object",
+            "properties": {
+                "cluster_name": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "synced": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.FieldChange": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "new": {
+                    "type": "string"
+                },
+                "old": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.HelmChart": {
+            "type": "object",
+            "properties": {
+                "archive": {
+                    "description": "Archive is the base64 encoded chart package (.tgz)",
+                    "type": "string"
+                },
+                "release_name": {
+                    "type": "string"
+                },
+                "values": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ImageRewrite": {
+            "type": "object",
+            "properties": {
+                "container": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "original": {
+                    "type": "string"
+                },
+                "rewritten": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Job": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "description": "Changes are the fields changed by an UpdateDeployment job",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldChange"
+                    }
+                },
+                "chart": {
+                    "$ref": "#/definitions/models.HelmChart"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "image_rewrites": {
+                    "description": "ImageRewrites are the images rewritten to registry mirrors while building the job's manifests",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImageRewrite"
+                    }
+                },
+                "job_group_description": {
+                    "type": "string"
+                },
+                "job_group_id": {
+                    "type": "string"
+                },
+                "job_group_name": {
+                    "type": "string"
+                },
+                "kustomization": {
+                    "$ref": "#/definitions/models.KustomizeOverlay"
+                },
+                "manifests": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PlainManifest"
+                    }
+                },
+                "namespace": {
+                    "type": "string"
+                },
+                "orchestrator": {
+                    "description": "Locker              *bool            ` + "`" + `json:\"locker,omitempty\"` + "`" + `",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OrchestratorType"
+                        }
+                    ]
+                },
+                "owner_id": {
+                    "description": "ResourceUID         string           ` + "`" + `json:\"uuid,omitempty\"` + "`" + `",
+                    "type": "string"
+                },
+                "parameters": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "remediation": {
+                    "description": "Remediation holds the parameters of UpdateDeployment jobs",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.RemediationParams"
+                        }
+                    ]
+                },
+                "resource": {
+                    "$ref": "#/definitions/models.Resource"
+                },
+                "state": {
+                    "$ref": "#/definitions/models.JobState"
+                },
+                "sub_type": {
+                    "$ref": "#/definitions/models.RemediationType"
+                },
+                "targets": {
+                    "$ref": "#/definitions/models.Target"
+                },
+                "templated": {
+                    "description": "Templated enables Go templates in the manifests and chart values, see TemplateData",
+                    "type": "boolean"
+                },
+                "type": {
+                    "$ref": "#/definitions/models.JobType"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobState": {
+            "type": "integer",
+            "enum": [
+                1,
+                2,
+                3,
+                4
+            ],
+            "x-enum-varnames": [
+                "Applied",
+                "Progressing",
+                "Available",
+                "Degraded"
+            ]
+        },
+        "models.JobType": {
+            "type": "integer",
+            "enum": [
+                5,
+                6,
+                7,
+                8
+            ],
+            "x-enum-varnames": [
+                "CreateDeployment",
+                "DeleteDeployment",
+                "UpdateDeployment",
+                "ReplaceDeployment"
+            ]
+        },
+        "models.KustomizeOverlay": {
+            "type": "object",
+            "properties": {
+                "common_labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "images": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.Image"
+                    }
+                },
+                "name_prefix": {
+                    "type": "string"
+                },
+                "name_suffix": {
+                    "type": "string"
+                },
+                "patches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.Patch"
+                    }
+                }
+            }
+        },
+        "models.ManifestDiff": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "changed": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "fields": {
+                    "description": "Fields are the fields of the changed objects that differ, from the object root",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldChange"
+                    }
+                },
+                "manifest_work": {
+                    "type": "string"
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "unified": {
+                    "description": "Unified is a unified diff of the objects as YAML",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ManifestStatus": {
+            "type": "object",
+            "properties": {
+                "annotations": {
+                    "description": "Annotations are the annotations linking the object to its ICOS application",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "api_version": {
+                    "type": "string"
+                },
+                "conditions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.Condition"
+                    }
+                },
+                "feedback": {
+                    "description": "Feedback are the status feedback values of the object, by name",
+                    "type": "object"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "manifest_work": {
+                    "description": "ManifestWork is the primary ManifestWork or the part holding the object",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "namespace": {
+                    "type": "string"
+                },
+                "ordinal": {
+                    "description": "Ordinal is the index of the object within its ManifestWork",
+                    "type": "integer"
+                },
+                "resource": {
+                    "description": "Resource is the API resource of the object, once reported by the work agent",
+                    "type": "string"
+                }
+            }
+        },
+        "models.OrchestratorType": {
+            "type": "string",
+            "enum": [
+                "ocm",
+                "nuvla"
+            ],
+            "x-enum-varnames": [
+                "OCM",
+                "NUVLA"
+            ]
+        },
+        "models.PlainManifest": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "yamlString": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ReconcileEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "cluster_name": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "resource_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ReconcileReport": {
+            "type": "object",
+            "properties": {
+                "drifted": {
+                    "description": "Drifted ManifestWorks no longer match the manifests of the last job applied to them",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ReconcileEntry"
+                    }
+                },
+                "missing": {
+                    "description": "Missing resources have no ManifestWork on their target cluster",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ReconcileEntry"
+                    }
+                },
+                "orphaned": {
+                    "description": "Orphaned ManifestWorks were created by the deploy manager but belong to none of the known resources",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ReconcileEntry"
+                    }
+                },
+                "unchecked": {
+                    "description": "Unchecked ManifestWorks were last changed by an update job, whose desired manifests are unknown,\nso they were not checked for drift",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ReconcileEntry"
+                    }
+                },
+                "unreachable": {
+                    "description": "Unreachable clusters could not be listed; their resources are neither reported missing nor\nrecreated",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ReconcileEntry"
+                    }
+                }
+            }
+        },
+        "models.RemediationParams": {
+            "type": "object",
+            "properties": {
+                "autoscaler": {
+                    "description": "Autoscaler, for AddAutoscaler, UpdateAutoscaler and RemoveAutoscaler, describes the autoscaler",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.AutoscalerParams"
+                        }
+                    ]
+                },
+                "component": {
+                    "description": "Component limits the remediation to the objects of that name, all of them when empty",
+                    "type": "string"
+                },
+                "containers": {
+                    "description": "Containers limits the remediation to the named containers, all of them when empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "cpu_step": {
+                    "description": "CPUStep, MemoryStep or Percent, for ScaleOut and ScaleIn, override the ones of VERTICAL_SCALING",
+                    "type": "string"
+                },
+                "image_digest": {
+                    "type": "string"
+                },
+                "image_tag": {
+                    "description": "ImageTag or ImageDigest, for SecurityRemediation, replace the tag or digest of the images of the\nnamed Containers",
+                    "type": "string"
+                },
+                "memory_step": {
+                    "type": "string"
+                },
+                "percent": {
+                    "type": "integer"
+                },
+                "replicas": {
+                    "description": "Replicas, for ScaleUp and ScaleDown, is the replica count to scale to and, for RemoveAutoscaler,\nthe one Deployments get back from their HorizontalPodAutoscaler",
+                    "type": "integer"
+                },
+                "resources": {
+                    "description": "Resources, for ScaleOut and ScaleIn, are the CPU and memory requests to scale to",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "revision": {
+                    "description": "Revision, for Rollback, is the revision of the resource to restore",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.RemediationType": {
+            "type": "string",
+            "enum": [
+                "scale-up",
+                "scale-down",
+                "scale-out",
+                "scale-in",
+                "reallocation",
+                "security-remediation",
+                "add-autoscaler",
+                "update-autoscaler",
+                "remove-autoscaler",
+                "rollback"
+            ],
+            "x-enum-varnames": [
+                "ScaleUp",
+                "ScaleDown",
+                "ScaleOut",
+                "ScaleIn",
+                "Reallocation",
+                "SecurityRemediation",
+                "AddAutoscaler",
+                "UpdateAutoscaler",
+                "RemoveAutoscaler",
+                "Rollback"
+            ]
+        },
+        "models.Resource": {
+            "type": "object",
+            "properties": {
+                "app_name": {
+                    "type": "string"
+                },
+                "cluster_name": {
+                    "description": "ClusterName, AppName, Component, JobGroupID and State are filled in resource listings",
+                    "type": "string"
+                },
+                "component": {
+                    "type": "string"
+                },
+                "conditions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.Condition"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "job_group_id": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "resource_name": {
+                    "type": "string"
+                },
+                "resource_parts": {
+                    "description": "ResourceParts are the additional ManifestWorks holding manifests that did not fit in the primary one",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "resource_uuid": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/models.ResourceState"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ResourceDetail": {
+            "type": "object",
+            "properties": {
+                "app_name": {
+                    "type": "string"
+                },
+                "cluster_name": {
+                    "description": "ClusterName, AppName, Component, JobGroupID and State are filled in resource listings",
+                    "type": "string"
+                },
+                "component": {
+                    "type": "string"
+                },
+                "conditions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.Condition"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "job_group_id": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "manifests": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ManifestStatus"
+                    }
+                },
+                "resource_name": {
+                    "type": "string"
+                },
+                "resource_parts": {
+                    "description": "ResourceParts are the additional ManifestWorks holding manifests that did not fit in the primary one",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "resource_uuid": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/models.ResourceState"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ResourceEvent": {
+            "type": "object",
+            "properties": {
+                "resource": {
+                    "$ref": "#/definitions/models.Resource"
+                },
+                "state": {
+                    "$ref": "#/definitions/models.JobState"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ResourceList": {
+            "type": "object",
+            "properties": {
+                "page": {
+                    "type": "integer"
+                },
+                "page_size": {
+                    "type": "integer"
+                },
+                "resources": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Resource"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ResourceState": {
+            "type": "string",
+            "enum": [
+                "Pending",
+                "Applied",
+                "Progressing",
+                "Available",
+                "Degraded"
+            ],
+            "x-enum-varnames": [
+                "ResourcePending",
+                "ResourceApplied",
+                "ResourceProgressing",
+                "ResourceAvailable",
+                "ResourceDegraded"
             ]
         },
-        "models.OrchestratorType": {
-            "type": "string",
-            "enum": [
-                "ocm",
-                "nuvla"
-            ],
-            "x-enum-varnames": [
-                "OCM",
-                "NUVLA"
-            ]
+        "models.Revision": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "job_type": {
+                    "type": "string"
+                },
+                "manifest_work": {
+                    "type": "string"
+                },
+                "revision": {
+                    "type": "integer"
+                },
+                "rollback_of": {
+                    "description": "RollbackOf is the revision restored by a rollback",
+                    "type": "integer"
+                },
+                "sub_type": {
+                    "$ref": "#/definitions/models.RemediationType"
+                },
+                "works": {
+                    "description": "Works are the specs of the primary ManifestWork and its parts, in order. They are left out of\nrevision lists.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.ManifestWorkSpec"
+                    }
+                }
+            }
+        },
+        "models.StatusReport": {
+            "type": "object",
+            "properties": {
+                "batches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BatchReport"
+                    }
+                },
+                "sent": {
+                    "type": "integer"
+                },
+                "unchanged": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SyncSummary": {
+            "type": "object",
+            "properties": {
+                "clusters": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ClusterSync"
+                    }
+                },
+                "status": {
+                    "description": "Status is the outcome of reporting the synced resources to the Job Manager",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.StatusReport"
+                        }
+                    ]
+                },
+                "unattributed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UnattributedWork"
+                    }
+                }
+            }
+        },
+        "models.Target": {
+            "type": "object",
+            "properties": {
+                "cluster_name": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "node_name": {
+                    "type": "string"
+                },
+                "orchestrator": {
+                    "$ref": "#/definitions/models.OrchestratorType"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UnattributedWork": {
+            "type": "object",
+            "properties": {
+                "cluster_name": {
+                    "type": "string"
+                },
+                "conditions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.Condition"
+                    }
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "uid": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ValidationError": {
+            "type": "object",
+            "properties": {
+                "document": {
+                    "type": "integer"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "source": {
+                    "description": "Source is the part of the job the document comes from, e.g. manifests[0], chart or kustomization",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ValidationReport": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ValidationError"
+                    }
+                },
+                "kube_version": {
+                    "type": "string"
+                },
+                "schema_version": {
+                    "type": "string"
+                },
+                "unvalidated": {
+                    "description": "Unvalidated are the objects whose kind the schema does not describe, such as custom resources,\nwhich are only checked for their name and API version",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ValidationError"
+                    }
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "open-cluster-management_io_api_work_v1.Manifest": {
+            "type": "object"
+        },
+        "types.Image": {
+            "type": "object",
+            "properties": {
+                "digest": {
+                    "description": "Digest is the value used to replace the original image tag.\nIf digest is present NewTag value is ignored.",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name is a tag-less image name.",
+                    "type": "string"
+                },
+                "newName": {
+                    "description": "NewName is the value used to replace the original name.",
+                    "type": "string"
+                },
+                "newTag": {
+                    "description": "NewTag is the value used to replace the original tag.",
+                    "type": "string"
+                },
+                "tagSuffix": {
+                    "description": "TagSuffix is the value used to suffix the original tag\nIf Digest and NewTag is present an error is thrown",
+                    "type": "string"
+                }
+            }
+        },
+        "types.Patch": {
+            "type": "object",
+            "properties": {
+                "options": {
+                    "description": "Options is a list of options for the patch",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "boolean"
+                    }
+                },
+                "patch": {
+                    "description": "Patch is the content of a patch.",
+                    "type": "string"
+                },
+                "path": {
+                    "description": "Path is a relative file path to the patch file.",
+                    "type": "string"
+                },
+                "target": {
+                    "description": "Target points to the resources that the patch is applied to",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.Selector"
+                        }
+                    ]
+                }
+            }
+        },
+        "types.Selector": {
+            "type": "object",
+            "properties": {
+                "annotationSelector": {
+                    "description": "AnnotationSelector is a string that follows the label selection expression\nhttps://kubernetes.io/docs/concepts/overview/working-with-objects/labels/#api\nIt matches with the resource annotations.",
+                    "type": "string"
+                },
+                "group": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "labelSelector": {
+                    "description": "LabelSelector is a string that follows the label selection expression\nhttps://kubernetes.io/docs/concepts/overview/working-with-objects/labels/#api\nIt matches with the resource labels.",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name of the resource.",
+                    "type": "string"
+                },
+                "namespace": {
+                    "description": "Namespace the resource belongs to, if it can belong to a namespace.",
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
         },
-        "models.PlainManifest": {
+        "v1.Condition": {
             "type": "object",
             "properties": {
-                "created_at": {
+                "lastTransitionTime": {
+                    "description": "lastTransitionTime is the last time the condition transitioned from one status to another.\nThis should be when the underlying condition changed.  If that is not known, then using the time when the API field changed is acceptable.\n+required\n+kubebuilder:validation:Required\n+kubebuilder:validation:Type=string\n+kubebuilder:validation:Format=date-time",
                     "type": "string"
                 },
-                "id": {
+                "message": {
+                    "description": "message is a human readable message indicating details about the transition.\nThis may be an empty string.\n+required\n+kubebuilder:validation:Required\n+kubebuilder:validation:MaxLength=32768",
+                    "type": "string"
+                },
+                "observedGeneration": {
+                    "description": "observedGeneration represents the .metadata.generation that the condition was set based upon.\nFor instance, if .metadata.generation is currently 12, but the .status.conditions[x].observedGeneration is 9, the condition is out of date\nwith respect to the current state of the instance.\n+optional\n+kubebuilder:validation:Minimum=0",
                     "type": "integer"
                 },
-                "updated_at": {
+                "reason": {
+                    "description": "reason contains a programmatic identifier indicating the reason for the condition's last transition.\nProducers of specific condition types may define expected values and meanings for this field,\nand whether the values are considered a guaranteed API.\nThe value should be a CamelCase string.\nThis field may not be empty.\n+required\n+kubebuilder:validation:Required\n+kubebuilder:validation:MaxLength=1024\n+kubebuilder:validation:MinLength=1\n+kubebuilder:validation:Pattern=` + "`" + `^[A-Za-z]([A-Za-z0-9_,:]*[A-Za-z0-9_])?$` + "`" + `",
                     "type": "string"
                 },
-                "yamlString": {
+                "status": {
+                    "description": "status of the condition, one of True, False, Unknown.\n+required\n+kubebuilder:validation:Required\n+kubebuilder:validation:Enum=True;False;Unknown",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/k8s_io_apimachinery_pkg_apis_meta_v1.ConditionStatus"
+                        }
+                    ]
+                },
+                "type": {
+                    "description": "type of condition in CamelCase or in foo.example.com/CamelCase.\n---\nMany .condition.type values are consistent across resources like Available, but because arbitrary conditions can be\nuseful (see .node.status.conditions), the ability to deconflict is important.\nThe regex it matches is (dns1123SubdomainFmt/)?(qualifiedNameFmt)\n+required\n+kubebuilder:validation:Required\n+kubebuilder:validation:Pattern=` + "`" + `^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*/)?(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])$` + "`" + `\n+kubebuilder:validation:MaxLength=316",
                     "type": "string"
                 }
             }
         },
-        "models.RemediationType": {
+        "v1.DeleteOption": {
+            "type": "object",
+            "properties": {
+                "propagationPolicy": {
+                    "description": "propagationPolicy can be Foreground, Orphan or SelectivelyOrphan\nSelectivelyOrphan should be rarely used.  It is provided for cases where particular resources is transfering\nownership from one ManifestWork to another or another management unit.\nSetting this value will allow a flow like\n1. create manifestwork/2 to manage foo\n2. update manifestwork/1 to selectively orphan foo\n3. remove foo from manifestwork/1 without impacting continuity because manifestwork/2 adopts it.\n+kubebuilder:default=Foreground",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.DeletePropagationPolicyType"
+                        }
+                    ]
+                },
+                "selectivelyOrphans": {
+                    "description": "selectivelyOrphan represents a list of resources following orphan deletion stratecy",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.SelectivelyOrphan"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.DeletePropagationPolicyType": {
             "type": "string",
             "enum": [
-                "scale-up",
-                "scale-down",
-                "scale-out",
-                "scale-in",
-                "reallocation"
+                "Foreground",
+                "Orphan",
+                "SelectivelyOrphan"
             ],
             "x-enum-varnames": [
-                "ScaleUp",
-                "ScaleDown",
-                "ScaleOut",
-                "ScaleIn",
-                "Reallocation"
+                "DeletePropagationPolicyTypeForeground",
+                "DeletePropagationPolicyTypeOrphan",
+                "DeletePropagationPolicyTypeSelectivelyOrphan"
             ]
         },
-        "models.Resource": {
+        "v1.FeedBackType": {
+            "type": "string",
+            "enum": [
+                "WellKnownStatus",
+                "JSONPaths"
+            ],
+            "x-enum-varnames": [
+                "WellKnownStatusType",
+                "JSONPathsType"
+            ]
+        },
+        "v1.FeedbackRule": {
             "type": "object",
             "properties": {
-                "conditions": {
+                "jsonPaths": {
+                    "description": "JsonPaths defines the json path under status field to be synced.\n+optional",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/v1.Condition"
+                        "$ref": "#/definitions/v1.JsonPath"
                     }
                 },
-                "created_at": {
+                "type": {
+                    "description": "Type defines the option of how status can be returned.\nIt can be jsonPaths or wellKnownStatus.\nIf the type is JSONPaths, user should specify the jsonPaths field\nIf the type is WellKnownStatus, certain common fields of status defined by a rule only\nfor types in in k8s.io/api and open-cluster-management/api will be reported,\nIf these status fields do not exist, no values will be reported.\n+kubebuilder:validation:Required\n+required",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.FeedBackType"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.JsonPath": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Name represents the alias name for this field\n+kubebuilder:validation:Required\n+required",
                     "type": "string"
                 },
-                "id": {
+                "path": {
+                    "description": "Path represents the json path of the field under status.\nThe path must point to a field with single value in the type of integer, bool or string.\nIf the path points to a non-existing field, no value will be returned.\nIf the path points to a structure, map or slice, no value will be returned and the status conddition\nof StatusFeedBackSynced will be set as false.\nRef to https://kubernetes.io/docs/reference/kubectl/jsonpath/ on how to write a jsonPath.\n+kubebuilder:validation:Required\n+required",
                     "type": "string"
                 },
-                "job_id": {
+                "version": {
+                    "description": "Version is the version of the Kubernetes resource.\nIf it is not specified, the resource with the semantically latest version is\nused to resolve the path.\n+optional",
                     "type": "string"
+                }
+            }
+        },
+        "v1.ManifestConfigOption": {
+            "type": "object",
+            "properties": {
+                "feedbackRules": {
+                    "description": "FeedbackRules defines what resource status field should be returned. If it is not set or empty,\nno feedback rules will be honored.\n+optional",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.FeedbackRule"
+                    }
                 },
-                "resource_name": {
-                    "type": "string"
+                "resourceIdentifier": {
+                    "description": "ResourceIdentifier represents the group, resource, name and namespace of a resoure.\niff this refers to a resource not created by this manifest work, the related rules will not be executed.\n+kubebuilder:validation:Required\n+required",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.ResourceIdentifier"
+                        }
+                    ]
                 },
-                "resource_uuid": {
+                "updateStrategy": {
+                    "description": "UpdateStrategy defines the strategy to update this manifest. UpdateStrategy is Update\nif it is not set.\n+optional",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.UpdateStrategy"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.ManifestWorkExecutor": {
+            "type": "object",
+            "properties": {
+                "subject": {
+                    "description": "Subject is the subject identity which the work agent uses to talk to the\nlocal cluster when applying the resources.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.ManifestWorkExecutorSubject"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.ManifestWorkExecutorSubject": {
+            "type": "object",
+            "properties": {
+                "serviceAccount": {
+                    "description": "ServiceAccount is for identifying which service account to use by the work agent.\nOnly required if the type is \"ServiceAccount\".\n+optional",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.ManifestWorkSubjectServiceAccount"
+                        }
+                    ]
+                },
+                "type": {
+                    "description": "Type is the type of the subject identity.\nSupported types are: \"ServiceAccount\".\n+kubebuilder:validation:Enum=ServiceAccount\n+kubebuilder:validation:Required\n+required",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.ManifestWorkExecutorSubjectType"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.ManifestWorkExecutorSubjectType": {
+            "type": "string",
+            "enum": [
+                "ServiceAccount"
+            ],
+            "x-enum-varnames": [
+                "ExecutorSubjectTypeServiceAccount"
+            ]
+        },
+        "v1.ManifestWorkSpec": {
+            "type": "object",
+            "properties": {
+                "deleteOption": {
+                    "description": "DeleteOption represents deletion strategy when the manifestwork is deleted.\nForeground deletion strategy is applied to all the resource in this manifestwork if it is not set.\n+optional",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.DeleteOption"
+                        }
+                    ]
+                },
+                "executor": {
+                    "description": "Executor is the configuration that makes the work agent to perform some pre-request processing/checking.\ne.g. the executor identity tells the work agent to check the executor has sufficient permission to write\nthe workloads to the local managed cluster.\nNote that nil executor is still supported for backward-compatibility which indicates that the work agent\nwill not perform any additional actions before applying resources.\n+optional",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.ManifestWorkExecutor"
+                        }
+                    ]
+                },
+                "manifestConfigs": {
+                    "description": "ManifestConfigs represents the configurations of manifests defined in workload field.\n+optional",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.ManifestConfigOption"
+                    }
+                },
+                "workload": {
+                    "description": "Workload represents the manifest workload to be deployed on a managed cluster.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.ManifestsTemplate"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.ManifestWorkSubjectServiceAccount": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Name is the name of the service account.\n+kubebuilder:validation:Required\n+kubebuilder:validation:MinLength=1\n+kubebuilder:validation:MaxLength=253\n+kubebuilder:validation:Pattern=` + "`" + `^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)$` + "`" + `\n+required",
                     "type": "string"
                 },
-                "updated_at": {
+                "namespace": {
+                    "description": "Namespace is the namespace of the service account.\n+kubebuilder:validation:Required\n+kubebuilder:validation:MinLength=1\n+kubebuilder:validation:MaxLength=253\n+kubebuilder:validation:Pattern=` + "`" + `^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)$` + "`" + `\n+required",
                     "type": "string"
                 }
             }
         },
-        "models.Target": {
+        "v1.ManifestsTemplate": {
             "type": "object",
             "properties": {
-                "cluster_name": {
+                "manifests": {
+                    "description": "Manifests represents a list of kuberenetes resources to be deployed on a managed cluster.\n+optional",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/open-cluster-management_io_api_work_v1.Manifest"
+                    }
+                }
+            }
+        },
+        "v1.OrphaningRule": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "description": "Group is the API Group of the Kubernetes resource,\nempty string indicates it is in core group.\n+optional",
                     "type": "string"
                 },
-                "created_at": {
+                "name": {
+                    "description": "Name is the name of the Kubernetes resource.\n+kubebuilder:validation:Required\n+required",
                     "type": "string"
                 },
-                "id": {
-                    "type": "integer"
-                },
-                "node_name": {
+                "namespace": {
+                    "description": "Name is the namespace of the Kubernetes resource, empty string indicates\nit is a cluster scoped resource.\n+optional",
                     "type": "string"
                 },
-                "orchestrator": {
-                    "$ref": "#/definitions/models.OrchestratorType"
-                },
-                "updated_at": {
+                "resource": {
+                    "description": "Resource is the resource name of the Kubernetes resource.\n+kubebuilder:validation:Required\n+required",
                     "type": "string"
                 }
             }
         },
-        "v1.Condition": {
+        "v1.ResourceIdentifier": {
             "type": "object",
             "properties": {
-                "lastTransitionTime": {
-                    "description": "lastTransitionTime is the last time the condition transitioned from one status to another.\nThis should be when the underlying condition changed.  If that is not known, then using the time when the API field changed is acceptable.\n+required\n+kubebuilder:validation:Required\n+kubebuilder:validation:Type=string\n+kubebuilder:validation:Format=date-time",
+                "group": {
+                    "description": "Group is the API Group of the Kubernetes resource,\nempty string indicates it is in core group.\n+optional",
                     "type": "string"
                 },
-                "message": {
-                    "description": "message is a human readable message indicating details about the transition.\nThis may be an empty string.\n+required\n+kubebuilder:validation:Required\n+kubebuilder:validation:MaxLength=32768",
+                "name": {
+                    "description": "Name is the name of the Kubernetes resource.\n+kubebuilder:validation:Required\n+required",
                     "type": "string"
                 },
-                "observedGeneration": {
-                    "description": "observedGeneration represents the .metadata.generation that the condition was set based upon.\nFor instance, if .metadata.generation is currently 12, but the .status.conditions[x].observedGeneration is 9, the condition is out of date\nwith respect to the current state of the instance.\n+optional\n+kubebuilder:validation:Minimum=0",
-                    "type": "integer"
+                "namespace": {
+                    "description": "Name is the namespace of the Kubernetes resource, empty string indicates\nit is a cluster scoped resource.\n+optional",
+                    "type": "string"
                 },
-                "reason": {
-                    "description": "reason contains a programmatic identifier indicating the reason for the condition's last transition.\nProducers of specific condition types may define expected values and meanings for this field,\nand whether the values are considered a guaranteed API.\nThe value should be a CamelCase string.\nThis field may not be empty.\n+required\n+kubebuilder:validation:Required\n+kubebuilder:validation:MaxLength=1024\n+kubebuilder:validation:MinLength=1\n+kubebuilder:validation:Pattern=` + "`" + `^[A-Za-z]([A-Za-z0-9_,:]*[A-Za-z0-9_])?$` + "`" + `",
+                "resource": {
+                    "description": "Resource is the resource name of the Kubernetes resource.\n+kubebuilder:validation:Required\n+required",
+                    "type": "string"
+                }
+            }
+        },
+        "v1.SelectivelyOrphan": {
+            "type": "object",
+            "properties": {
+                "orphaningRules": {
+                    "description": "orphaningRules defines a slice of orphaningrule.\nEach orphaningrule identifies a single resource included in this manifestwork\n+optional",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.OrphaningRule"
+                    }
+                }
+            }
+        },
+        "v1.ServerSideApplyConfig": {
+            "type": "object",
+            "properties": {
+                "fieldManager": {
+                    "description": "FieldManager is the manager to apply the resource. It is work-agent by default, but can be other name with work-agent\nas the prefix.\n+kubebuilder:default=work-agent\n+kubebuilder:validation:Pattern=` + "`" + `^work-agent` + "`" + `\n+optional",
                     "type": "string"
                 },
-                "status": {
-                    "description": "status of the condition, one of True, False, Unknown.\n+required\n+kubebuilder:validation:Required\n+kubebuilder:validation:Enum=True;False;Unknown",
+                "force": {
+                    "description": "Force represents to force apply the manifest.\n+optional",
+                    "type": "boolean"
+                }
+            }
+        },
+        "v1.UpdateStrategy": {
+            "type": "object",
+            "properties": {
+                "serverSideApply": {
+                    "description": "serverSideApply defines the configuration for server side apply. It is honored only when\ntype of updateStrategy is ServerSideApply\n+optional",
                     "allOf": [
                         {
-                            "$ref": "#/definitions/k8s_io_apimachinery_pkg_apis_meta_v1.ConditionStatus"
+                            "$ref": "#/definitions/v1.ServerSideApplyConfig"
                         }
                     ]
                 },
                 "type": {
-                    "description": "type of condition in CamelCase or in foo.example.com/CamelCase.\n---\nMany .condition.type values are consistent across resources like Available, but because arbitrary conditions can be\nuseful (see .node.status.conditions), the ability to deconflict is important.\nThe regex it matches is (dns1123SubdomainFmt/)?(qualifiedNameFmt)\n+required\n+kubebuilder:validation:Required\n+kubebuilder:validation:Pattern=` + "`" + `^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*/)?(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])$` + "`" + `\n+kubebuilder:validation:MaxLength=316",
-                    "type": "string"
+                    "description": "type defines the strategy to update this manifest, default value is Update.\nUpdate type means to update resource by an update call.\nCreateOnly type means do not update resource based on current manifest.\nServerSideApply type means to update resource using server side apply with work-controller as the field manager.\nIf there is conflict, the related Applied condition of manifest will be in the status of False with the\nreason of ApplyConflict.\n+kubebuilder:default=Update\n+kubebuilder:validation:Enum=Update;CreateOnly;ServerSideApply\n+kubebuilder:validation:Required\n+required",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.UpdateStrategyType"
+                        }
+                    ]
                 }
             }
+        },
+        "v1.UpdateStrategyType": {
+            "type": "string",
+            "enum": [
+                "Update",
+                "CreateOnly",
+                "ServerSideApply"
+            ],
+            "x-enum-varnames": [
+                "UpdateStrategyTypeUpdate",
+                "UpdateStrategyTypeCreateOnly",
+                "UpdateStrategyTypeServerSideApply"
+            ]
         }
     },
     "securityDefinitions": {
@@ -23,6 +23,7 @@ package controllers
 import (
 	"context"
 	"flag"
+	"icos/server/ocm-description-service/models"
 	"icos/server/ocm-description-service/utils/logs"
 	"net/http"
 	"os"
@@ -56,9 +57,20 @@ func (server *Server) Run(addr string) {
 	logs.Logger.Println("Listening to port " + addr + " ...")
 	handler := cors.AllowAll().Handler(server.Router)
 
-	stop := make(chan os.Signal)
+	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt)
 
+	// the clients are shared by every handler and background loop, so they are only built once
+	if err := models.InClusterConfig(); err != nil {
+		logs.Logger.Println("Kubeconfig error occurred:", err)
+	}
+
+	healingCtx, stopHealing := context.WithCancel(context.Background())
+	defer stopHealing()
+	if models.SelfHealingEnabled() {
+		go models.RunSelfHealing(healingCtx)
+	}
+
 	go func() {
 		// init server
 		if err := http.ListenAndServe(addr, handler); err != nil {
@@ -0,0 +1,58 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"icos/server/ocm-description-service/models"
+	"icos/server/ocm-description-service/responses"
+	"icos/server/ocm-description-service/utils/logs"
+	"net/http"
+)
+
+// DiffJob example
+//
+// @Summary		Diff job manifests
+// @Description	compare the ManifestWork of a deployment on the hub with the one the job would produce, ignoring server-populated fields
+// @Tags			jobs
+// @Accept			json
+// @Produce			json
+// @Param			job	body		models.Job	true	"Job to compare, with the resource name of an existing deployment"
+// @Success		200	{object}	models.ManifestDiff
+// @Failure		400	{object}	string	"Bad Request"
+// @Failure		422	{object}	string	"Job cannot be compared"
+// @Router			/deploy-manager/diff [post]
+func (server *Server) DiffJob(w http.ResponseWriter, r *http.Request) {
+	job := models.Job{}
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		logs.Logger.Println("Error unmarshaling job:", err)
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	diff, err := models.DiffJob(&job)
+	if err != nil {
+		logs.Logger.Println("Could not diff job:", err)
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, diff)
+}
@@ -0,0 +1,98 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"icos/server/ocm-description-service/models"
+	"icos/server/ocm-description-service/responses"
+	"icos/server/ocm-description-service/utils/logs"
+	"net/http"
+	"time"
+)
+
+// interval of the comments keeping idle event streams open through proxies
+const eventStreamHeartbeat = 30 * time.Second
+
+// StreamResourceEvents example
+//
+// @Summary		Stream resource status changes
+// @Description	stream, as server-sent events, the condition changes of the resources deployed on the hub, starting with their current conditions
+// @Tags			resources
+// @Produce			text/event-stream
+// @Param			cluster			query		string	false	"Only resources of this cluster"
+// @Param			job_group_id	query		string	false	"Only resources of this job group"
+// @Param			resource_id		query		string	false	"Only the resource with this Job Manager ID"
+// @Param			resource_name	query		string	false	"Only the resource with this name"
+// @Success		200				{object}	models.ResourceEvent	"Stream of events, each holding a resource event as JSON"
+// @Failure		500				{object}	string	"Internal Server Error"
+// @Router			/deploy-manager/resources/events [get]
+func (server *Server) StreamResourceEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		responses.ERROR(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	query := r.URL.Query()
+	filter := models.EventFilter{
+		ClusterName:  query.Get("cluster"),
+		JobGroupID:   query.Get("job_group_id"),
+		ResourceID:   query.Get("resource_id"),
+		ResourceName: query.Get("resource_name"),
+	}
+
+	events, err := models.WatchResources(r.Context(), filter)
+	if err != nil {
+		logs.Logger.Println("Error watching resources:", err)
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				logs.Logger.Println("Error marshaling resource event:", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
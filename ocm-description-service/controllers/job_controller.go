@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"icos/server/ocm-description-service/models"
 	"icos/server/ocm-description-service/responses"
 	"icos/server/ocm-description-service/utils/logs"
@@ -54,10 +55,6 @@ func (server *Server) PullJobs(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	jobs := []models.Job{}
 
-	if err := models.InClusterConfig(); err != nil {
-		logs.Logger.Println("Kubeconfig error occurred:", err)
-	}
-
 	ownerId, err := models.FetchClusterManagerUID("cluster-manager")
 	if err != nil {
 		logs.Logger.Println("Error fetching cluster manager UID:", err)
@@ -86,7 +83,7 @@ func (server *Server) PullJobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	executeJobs(ctx, jobs, w, r, ownerId)
+	executeJobs(ctx, jobs, r, ownerId)
 	responses.JSON(w, http.StatusOK, jobs)
 }
 
@@ -110,7 +107,9 @@ func getExecutableJobs(ctx context.Context, w http.ResponseWriter, r *http.Reque
 	return respJobs, nil
 }
 
-func executeJobs(ctx context.Context, jobs []models.Job, w http.ResponseWriter, r *http.Request, ownerId string) {
+// executeJobs locks and executes each job, reporting its outcome to the Job Manager. Failures are
+// logged per job; the response is left to the caller.
+func executeJobs(ctx context.Context, jobs []models.Job, r *http.Request, ownerId string) {
 	for i := range jobs {
 		job := &jobs[i]
 		logs.Logger.Println("Executing Job:", job.ID)
@@ -123,35 +122,33 @@ func executeJobs(ctx context.Context, jobs []models.Job, w http.ResponseWriter,
 		job.OwnerID = ownerId
 		if err := job.PromoteJob(r.Header.Get("Authorization"), job.OwnerID); err != nil {
 			logs.Logger.Println("Error promoting job:", err)
-			responses.ERROR(w, http.StatusUnprocessableEntity, err)
 			continue
 		}
 
 		executedJob, err := models.Execute(job)
 		if err != nil {
 			logs.Logger.Println("Error executing job:", err)
-			responses.ERROR(w, http.StatusUnprocessableEntity, err)
-			continue
+			// report the failure back so the job does not stay promoted forever
+			job.RecordFailure(err)
+		} else {
+			*job = *executedJob
 		}
 
-		*job = *executedJob
-
-		jobBody, err := json.Marshal(job)
-		if err != nil {
-			logs.Logger.Println("Error marshaling job:", err)
-			continue
+		if err := updateJob(ctx, job, r.Header.Get("Authorization")); err != nil {
+			logs.Logger.Println("Error updating job:", err)
 		}
-
-		updateJob(ctx, job, w, r, jobBody)
 	}
 }
 
-func updateJob(ctx context.Context, job *models.Job, w http.ResponseWriter, r *http.Request, jobBody []byte) {
+// updateJob reports the state of a job to the Job Manager.
+func updateJob(ctx context.Context, job *models.Job, authHeader string) error {
+	jobBody, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("error marshaling job: %v", err)
+	}
 	reqState, err := http.NewRequestWithContext(ctx, "PUT", jobmanagerBaseURL+"jobmanager/jobs", bytes.NewReader(jobBody))
 	if err != nil {
-		logs.Logger.Println("Error creating update job request:", err)
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
-		return
+		return fmt.Errorf("error creating update job request: %v", err)
 	}
 
 	query := reqState.URL.Query()
@@ -159,16 +156,18 @@ func updateJob(ctx context.Context, job *models.Job, w http.ResponseWriter, r *h
 	query.Add("orchestrator", "ocm")
 	reqState.URL.RawQuery = query.Encode()
 
-	reqState.Header.Add("Authorization", r.Header.Get("Authorization"))
+	reqState.Header.Add("Authorization", authHeader)
 
 	client := &http.Client{}
 	resp, err := client.Do(reqState)
 	if err != nil {
-		logs.Logger.Println("Error performing update job request:", err)
-		responses.ERROR(w, http.StatusServiceUnavailable, err)
-		return
+		return fmt.Errorf("error performing update job request: %v", err)
 	}
 	defer resp.Body.Close()
 
 	logs.Logger.Println("Update Job Response:", resp.Status)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("job %s update rejected: %s", job.ID, resp.Status)
+	}
+	return nil
 }
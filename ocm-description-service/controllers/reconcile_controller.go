@@ -0,0 +1,76 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"icos/server/ocm-description-service/models"
+	"icos/server/ocm-description-service/responses"
+	"icos/server/ocm-description-service/utils/logs"
+	"net/http"
+	"strconv"
+)
+
+// Reconcile example
+//
+// @Summary		Reconcile ManifestWorks
+// @Description	report orphaned, missing and drifted ManifestWorks by comparing the hub with the last job applied to each resource, among the jobs given by the caller
+// @Tags			resources
+// @Accept			json
+// @Produce			json
+// @Param			jobs		body		[]models.Job	true	"Jobs of the Job Manager; the last one of each resource is kept"
+// @Param			adopt		query		bool			false	"Keep the live spec of drifted ManifestWorks and relabel them with their last job"
+// @Param			recreate	query		bool			false	"Create missing ManifestWorks and overwrite drifted ones with the desired manifests"
+// @Param			gc			query		bool			false	"Delete orphaned ManifestWorks that carry a resource ID"
+// @Success		200			{object}	models.ReconcileReport
+// @Failure		400			{object}	string	"Bad Request"
+// @Router			/deploy-manager/reconcile [post]
+func (server *Server) Reconcile(w http.ResponseWriter, r *http.Request) {
+	opts := models.ReconcileOptions{}
+	for name, flag := range map[string]*bool{"adopt": &opts.Adopt, "recreate": &opts.Recreate, "gc": &opts.GC} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, fmt.Errorf("invalid %s flag: %v", name, err))
+			return
+		}
+		*flag = parsed
+	}
+
+	jobs := []models.Job{}
+	if err := json.NewDecoder(r.Body).Decode(&jobs); err != nil {
+		logs.Logger.Println("Error unmarshaling jobs:", err)
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := models.Reconcile(models.LastResourceJobs(jobs), opts)
+	if err != nil {
+		logs.Logger.Println("Error during reconciliation:", err)
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, report)
+}
@@ -21,16 +21,17 @@
 package controllers
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"icos/server/ocm-description-service/models"
 	"icos/server/ocm-description-service/responses"
 	"icos/server/ocm-description-service/utils/logs"
 	"net/http"
+	"strconv"
 
-	workv1 "open-cluster-management.io/api/work/v1"
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // GetResourceStatus example
@@ -40,7 +41,7 @@ import (
 // @Tags			resources
 // @Accept			json
 // @Produce			json
-// @Param			uid				path		string	true	"Resource ID"
+// @Param			uid				query		string	true	"Resource ID"
 // @Param			resource_name	query		string	true	"Resource name"
 // @Param			node_target		query		string	true	"Node target"
 // @Success		200				{object}	models.Resource
@@ -48,6 +49,7 @@ import (
 // @Failure		400				{object}	string	"provided UID is different from the retrieved manifest"
 // @Failure		422				{object}	string	"Can not parse UID"
 // @Failure		404				{object}	string	"Can not find Resource"
+// @Failure		500				{object}	string	"Internal Server Error"
 // @Router			/deploy-manager/resource [get]
 func (server *Server) GetResourceStatus(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
@@ -61,15 +63,15 @@ func (server *Server) GetResourceStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var manifestWork *workv1.ManifestWork
-
-	err := models.InClusterConfig()
-	if err != nil {
-		responses.ERROR(w, http.StatusForbidden, err)
-	}
-	manifestWork, err = models.GetManifestWork(stringTarget, stringManifestName)
+	manifestWork, err := models.GetManifestWork(stringTarget, stringManifestName)
 	if err != nil {
 		logs.Logger.Println("Error during Manifest retrieval...", err)
+		status := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		responses.ERROR(w, status, err)
+		return
 	}
 
 	conditions := manifestWork.Status.Conditions
@@ -87,6 +89,98 @@ func (server *Server) GetResourceStatus(w http.ResponseWriter, r *http.Request)
 	responses.JSON(w, http.StatusOK, resource)
 }
 
+// ListResources example
+//
+// @Summary		List resources
+// @Description	list the resources deployed on the hub with their computed state, filtered, sorted and paginated
+// @Tags			resources
+// @Accept			json
+// @Produce			json
+// @Param			cluster			query		string	false	"Only resources of this cluster"
+// @Param			app_name		query		string	false	"Only resources of this application"
+// @Param			component		query		string	false	"Only resources of this component"
+// @Param			job_group_id	query		string	false	"Only resources of this job group"
+// @Param			state			query		string	false	"Only resources in this state"	Enums(Pending, Applied, Progressing, Available, Degraded)
+// @Param			page			query		int		false	"Page, starting at 1"
+// @Param			page_size		query		int		false	"Resources per page, 20 by default and 100 at most"
+// @Param			sort			query		string	false	"Field to sort by"	Enums(name, cluster, app, state, created_at)
+// @Param			order			query		string	false	"Sort order"	Enums(asc, desc)
+// @Success		200				{object}	models.ResourceList
+// @Failure		400				{object}	string	"Bad Request"
+// @Failure		500				{object}	string	"Internal Server Error"
+// @Router			/deploy-manager/resources [get]
+func (server *Server) ListResources(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.ResourceFilter{
+		ClusterName: query.Get("cluster"),
+		AppName:     query.Get("app_name"),
+		Component:   query.Get("component"),
+		JobGroupID:  query.Get("job_group_id"),
+		State:       models.ResourceState(query.Get("state")),
+		SortBy:      query.Get("sort"),
+	}
+	for name, value := range map[string]*int{"page": &filter.Page, "page_size": &filter.PageSize} {
+		if query.Get(name) == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(query.Get(name))
+		if err != nil || parsed <= 0 {
+			responses.ERROR(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %q", name, query.Get(name)))
+			return
+		}
+		*value = parsed
+	}
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		responses.ERROR(w, http.StatusBadRequest, fmt.Errorf("invalid order: %q", query.Get("order")))
+		return
+	}
+
+	list, err := models.ListResources(filter)
+	if errors.Is(err, models.ErrInvalidResourceFilter) {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		logs.Logger.Println("Error listing resources:", err)
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, list)
+}
+
+// GetResourceDetail example
+//
+// @Summary		Get resource detail
+// @Description	get a resource with the kind, name, namespace, conditions, status feedback and application annotations of each of its objects
+// @Tags			resources
+// @Accept			json
+// @Produce			json
+// @Param			cluster	path		string	true	"Cluster of the resource"
+// @Param			name	path		string	true	"Resource name"
+// @Success		200		{object}	models.ResourceDetail
+// @Failure		404		{object}	string	"Can not find Resource"
+// @Failure		500		{object}	string	"Internal Server Error"
+// @Router			/deploy-manager/resources/{cluster}/{name} [get]
+func (server *Server) GetResourceDetail(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	detail, err := models.GetResourceDetail(vars["cluster"], vars["name"])
+	if errors.Is(err, models.ErrResourceNotFound) {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		logs.Logger.Println("Error obtaining resource detail:", err)
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, detail)
+}
+
 // StartSyncUp example
 //
 // @Summary		Start sync-up
@@ -95,48 +189,33 @@ func (server *Server) GetResourceStatus(w http.ResponseWriter, r *http.Request)
 // @Accept			json
 // @Produce			json
 // @Param			Authorization	header		string	true	"Authentication header"
-// @Success		200				{string}	string	"Ok"
+// @Param			job_group_id	query		string	false	"Only sync resources of this job group"
+// @Param			owner_id		query		string	false	"Only sync resources of this owner"
+// @Success		200				{object}	models.SyncSummary	"Synced, failed and skipped ManifestWorks by cluster, the ones that could not be matched to a Job Manager resource, and the status batches sent to the Job Manager"
 // @Failure		500				{object}	string "Internal Server Error"
 // @Router			/deploy-manager/resource/sync [get]
 func (server *Server) StartSyncUp(w http.ResponseWriter, r *http.Request) {
-	var resources []models.Resource
-	err := models.InClusterConfig()
-	if err != nil {
-		logs.Logger.Println("Kubeconfig error occured", err)
+	ownership := labels.Set{}
+	if jobGroupID := r.URL.Query().Get("job_group_id"); jobGroupID != "" {
+		ownership[models.JobGroupIDLabel] = jobGroupID
 	}
-	resources, err = models.ResourceSync()
+	if ownerID := r.URL.Query().Get("owner_id"); ownerID != "" {
+		ownership[models.OwnerIDLabel] = ownerID
+	}
+	result, err := models.ResourceSync(ownership)
 	if err != nil {
 		logs.Logger.Println("Error during resource sync...", err)
-	}
-	for _, resource := range resources {
-		// HTTP PUT to update UUIDs, State into JOB MANAGER -> updateJob call
-		logs.Logger.Println("Creating Status Request for Job Manager...")
-		logs.Logger.Println("Resource Status: ")
-		logs.Logger.Printf("%#v", resource)
-		resourceBody, err := json.Marshal(resource)
-		if err != nil {
-			logs.Logger.Println("Could not unmarshall resource...", err)
-		}
-		reqState, err := http.NewRequest("PUT", jobmanagerBaseURL+"jobmanager/resources/status", bytes.NewReader(resourceBody))
-		if err != nil {
-			logs.Logger.Println("Error creating resource status update request...", err)
+		if len(result.Clusters) == 0 {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
 		}
-		logs.Logger.Println("PUT Request to Job Manager being created: ")
-		logs.Logger.Println(reqState.URL)
-		// query := reqState.URL.Query()
-		// query.Add("uuid", resource.ID)
-		reqState.Header.Add("Authorization", r.Header.Get("Authorization"))
-
-		// do request
-		client2 := &http.Client{}
-		res, err := client2.Do(reqState)
-		if err != nil {
-			logs.Logger.Println("Error occurred during resource status update request, resource ID: " + resource.ID)
-			// keep executing
-		}
-		defer reqState.Body.Close()
-		logs.Logger.Println("Resource status update request sent, resource ID: " + resource.ID)
-		logs.Logger.Println("HTTP Response Status:", res.StatusCode, http.StatusText(res.StatusCode))
 	}
-	responses.JSON(w, http.StatusOK, nil)
+	status := models.ReportResourceStatus(r.Context(), r.Header.Get("Authorization"), result.Resources)
+	logs.Logger.Printf("Resource status reported to Job Manager: %d sent, %d unchanged, in %d batches", status.Sent, status.Unchanged, len(status.Batches))
+	if len(result.Unattributed) > 0 {
+		logs.Logger.Printf("%d ManifestWorks could not be matched to a Job Manager resource", len(result.Unattributed))
+	}
+	summary := result.Summary()
+	summary.Status = status
+	responses.JSON(w, http.StatusOK, summary)
 }
@@ -0,0 +1,201 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"icos/server/ocm-description-service/models"
+	"icos/server/ocm-description-service/responses"
+	"icos/server/ocm-description-service/utils/logs"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ListRevisions example
+//
+// @Summary		List resource revisions
+// @Description	list the specs applied to a resource, oldest first
+// @Tags			resources
+// @Accept			json
+// @Produce			json
+// @Param			resource_name	query		string	true	"Resource name"
+// @Param			node_target		query		string	true	"Node target"
+// @Success		200				{array}		models.Revision
+// @Failure		400				{object}	string	"Bad Request"
+// @Failure		500				{object}	string	"Internal Server Error"
+// @Router			/deploy-manager/resource/revisions [get]
+func (server *Server) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	target, name, err := revisionResource(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	revisions, err := models.ListRevisions(target, name)
+	if err != nil {
+		logs.Logger.Println("Error obtaining revisions:", err)
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, revisions)
+}
+
+// GetRevision example
+//
+// @Summary		Get resource revision
+// @Description	get a revision of a resource with the specs of its ManifestWorks
+// @Tags			resources
+// @Accept			json
+// @Produce			json
+// @Param			revision		path		int		true	"Revision number"
+// @Param			resource_name	query		string	true	"Resource name"
+// @Param			node_target		query		string	true	"Node target"
+// @Success		200				{object}	models.Revision
+// @Failure		400				{object}	string	"Bad Request"
+// @Failure		404				{object}	string	"Revision not found"
+// @Failure		500				{object}	string	"Internal Server Error"
+// @Router			/deploy-manager/resource/revisions/{revision} [get]
+func (server *Server) GetRevision(w http.ResponseWriter, r *http.Request) {
+	target, name, err := revisionResource(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	number, err := strconv.Atoi(mux.Vars(r)["revision"])
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, fmt.Errorf("invalid revision: %v", err))
+		return
+	}
+
+	revision, err := models.GetRevision(target, name, number)
+	if errors.Is(err, models.ErrRevisionNotFound) {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		logs.Logger.Println("Error obtaining revision:", err)
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, revision)
+}
+
+// RollbackResource example
+//
+// @Summary		Roll back a resource
+// @Description	apply a previous revision of the job's resource as a rollback update job, locking and reporting the job like the jobs pulled from the Job Manager
+// @Tags			jobs
+// @Accept			json
+// @Produce			json
+// @Param			Authorization	header		string		true	"Authentication header"
+// @Param			job				body		models.Job	true	"Job Manager job targeting the resource to roll back"
+// @Param			revision		query		int			false	"Revision to restore, defaults to remediation.revision of the job"
+// @Success		200				{object}	models.Job
+// @Failure		400				{object}	string	"Bad Request"
+// @Failure		401				{object}	string	"Unauthorized"
+// @Failure		409				{object}	string	"Job could not be locked"
+// @Failure		422				{object}	string	"Rollback failed"
+// @Failure		500				{object}	string	"Internal Server Error"
+// @Failure		502				{object}	string	"Rollback not reported to the Job Manager"
+// @Router			/deploy-manager/resource/rollback [post]
+func (server *Server) RollbackResource(w http.ResponseWriter, r *http.Request) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("authorization header is required"))
+		return
+	}
+	job := models.Job{}
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		logs.Logger.Println("Error unmarshaling job:", err)
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if job.ID == "" {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("job id is required, rollbacks must be Job Manager jobs"))
+		return
+	}
+	if job.Resource == nil || job.Resource.ResourceName == "" || job.Target.ClusterName == "" {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("job's resource name or target cluster are empty"))
+		return
+	}
+	job.Type = models.UpdateDeployment
+	job.SubType = models.Rollback
+	if job.Remediation == nil {
+		job.Remediation = &models.RemediationParams{}
+	}
+	if value := r.URL.Query().Get("revision"); value != "" {
+		revision, err := strconv.Atoi(value)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, fmt.Errorf("invalid revision: %v", err))
+			return
+		}
+		job.Remediation.Revision = revision
+	}
+
+	ownerId, err := models.FetchClusterManagerUID("cluster-manager")
+	if err != nil {
+		logs.Logger.Println("Error obtaining ownerId:", err)
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	// lock the job like executeJobs does, so a pull cannot run it a second time
+	job.OwnerID = ownerId
+	if err := job.PromoteJob(authHeader, job.OwnerID); err != nil {
+		logs.Logger.Println("Error promoting job:", err)
+		responses.ERROR(w, http.StatusConflict, err)
+		return
+	}
+
+	executedJob, execErr := models.Execute(&job)
+	if execErr != nil {
+		logs.Logger.Println("Error rolling back resource:", execErr)
+		job.RecordFailure(execErr)
+	} else {
+		job = *executedJob
+	}
+
+	updateErr := updateJob(r.Context(), &job, authHeader)
+	if updateErr != nil {
+		logs.Logger.Println("Error updating job:", updateErr)
+	}
+	switch {
+	case execErr != nil:
+		responses.ERROR(w, http.StatusUnprocessableEntity, execErr)
+	case updateErr != nil:
+		responses.ERROR(w, http.StatusBadGateway, fmt.Errorf("rollback applied but not reported to the Job Manager: %v", updateErr))
+	default:
+		responses.JSON(w, http.StatusOK, job)
+	}
+}
+
+// revisionResource reads the cluster and name of the resource whose revisions are requested.
+func revisionResource(r *http.Request) (string, string, error) {
+	target := r.URL.Query().Get("node_target")
+	name := r.URL.Query().Get("resource_name")
+	if target == "" || name == "" {
+		return "", "", errors.New("node_target or resource_name are empty")
+	}
+	return target, name, nil
+}
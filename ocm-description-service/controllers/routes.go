@@ -32,8 +32,24 @@ func (s *Server) initializeRoutes() {
 	s.Router.HandleFunc("/deploy-manager/healthz", s.HealthCheck).Methods("GET")
 	//ocm-descriptor routes
 	s.Router.HandleFunc("/deploy-manager/execute", m.SetMiddlewareLog(s.PullJobs)).Methods("GET")
+	// validate job manifests without deploying them
+	s.Router.HandleFunc("/deploy-manager/validate", m.SetMiddlewareLog(m.SetMiddlewareJSON(s.ValidateJob))).Methods("POST")
+	// compare the deployed manifests with the ones a job would produce
+	s.Router.HandleFunc("/deploy-manager/diff", m.SetMiddlewareLog(m.SetMiddlewareJSON(s.DiffJob))).Methods("POST")
 	// get resource (status)
 	s.Router.HandleFunc("/deploy-manager/resource", m.SetMiddlewareLog(m.SetMiddlewareJSON(s.GetResourceStatus))).Methods("GET")
+	// list, show and roll back to the revisions of a resource
+	s.Router.HandleFunc("/deploy-manager/resource/revisions", m.SetMiddlewareLog(m.SetMiddlewareJSON(s.ListRevisions))).Methods("GET")
+	s.Router.HandleFunc("/deploy-manager/resource/revisions/{revision}", m.SetMiddlewareLog(m.SetMiddlewareJSON(s.GetRevision))).Methods("GET")
+	s.Router.HandleFunc("/deploy-manager/resource/rollback", m.SetMiddlewareLog(m.SetMiddlewareJSON(s.RollbackResource))).Methods("POST")
+	// list resources with filters, sorting and pagination
+	s.Router.HandleFunc("/deploy-manager/resources", m.SetMiddlewareLog(m.SetMiddlewareJSON(s.ListResources))).Methods("GET")
+	// stream resource status changes as server-sent events
+	s.Router.HandleFunc("/deploy-manager/resources/events", m.SetMiddlewareLog(s.StreamResourceEvents)).Methods("GET")
+	// get a resource with the status of each of its objects
+	s.Router.HandleFunc("/deploy-manager/resources/{cluster}/{name}", m.SetMiddlewareLog(m.SetMiddlewareJSON(s.GetResourceDetail))).Methods("GET")
 	// trigger resource syncup
 	s.Router.HandleFunc("/deploy-manager/resource/sync", m.SetMiddlewareLog(m.SetMiddlewareJSON(s.StartSyncUp))).Methods("GET")
+	// report and fix orphaned, missing and drifted resources
+	s.Router.HandleFunc("/deploy-manager/reconcile", m.SetMiddlewareLog(m.SetMiddlewareJSON(s.Reconcile))).Methods("POST")
 }
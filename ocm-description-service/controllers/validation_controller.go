@@ -0,0 +1,70 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"icos/server/ocm-description-service/models"
+	"icos/server/ocm-description-service/responses"
+	"icos/server/ocm-description-service/utils/logs"
+	"net/http"
+)
+
+// ValidateJob example
+//
+// @Summary		Validate job manifests
+// @Description	validate the manifests of a job against the bundled Kubernetes schemas without deploying them
+// @Tags			jobs
+// @Accept			json
+// @Produce			json
+// @Param			job				body		models.Job	true	"Job to validate"
+// @Param			kube_version	query		string		false	"Kubernetes version to validate against, defaults to the version of the job's target cluster"
+// @Success		200				{object}	models.ValidationReport
+// @Failure		400				{object}	string	"Bad Request"
+// @Failure		422				{object}	string	"Invalid Kubernetes version"
+// @Router			/deploy-manager/validate [post]
+func (server *Server) ValidateJob(w http.ResponseWriter, r *http.Request) {
+	job := models.Job{}
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		logs.Logger.Println("Error unmarshaling job:", err)
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if job.Resource == nil {
+		job.Resource = &models.Resource{}
+	}
+
+	kubeVersion := r.URL.Query().Get("kube_version")
+	if kubeVersion == "" && job.Target.ClusterName != "" {
+		version, err := models.FetchClusterKubeVersion(job.Target.ClusterName)
+		if err != nil {
+			logs.Logger.Println("Could not obtain cluster version, validating against the bundled schema:", err)
+		}
+		kubeVersion = version
+	}
+
+	report, err := models.ValidateJob(&job, kubeVersion)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, report)
+}
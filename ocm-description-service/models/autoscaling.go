@@ -0,0 +1,534 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"fmt"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	workv1 "open-cluster-management.io/api/work/v1"
+	yamlEncode "sigs.k8s.io/yaml"
+)
+
+const (
+	HorizontalPodAutoscalerKind = "HorizontalPodAutoscaler"
+	VerticalPodAutoscalerKind   = "VerticalPodAutoscaler"
+
+	// VPAAvailableLabel, as a label or cluster claim set to "true", marks the managed clusters running
+	// the Vertical Pod Autoscaler
+	VPAAvailableLabel = "autoscaling.icos.eu/vpa"
+)
+
+var (
+	vpaGroupVersion = schema.GroupVersion{Group: "autoscaling.k8s.io", Version: "v1"}
+	vpaUpdateModes  = map[string]bool{"Off": true, "Initial": true, "Recreate": true, "Auto": true}
+)
+
+// AutoscalerParams are the thresholds of the autoscaler managed by the AddAutoscaler, UpdateAutoscaler
+// and RemoveAutoscaler remediations. Thresholds left out keep their current value on update.
+type AutoscalerParams struct {
+	// Kind is HorizontalPodAutoscaler, the default, or VerticalPodAutoscaler
+	Kind string `json:"kind,omitempty"`
+	// MinReplicas, MaxReplicas and the average utilization targets, in percent of the requests, of a
+	// HorizontalPodAutoscaler
+	MinReplicas       *int32 `json:"min_replicas,omitempty"`
+	MaxReplicas       *int32 `json:"max_replicas,omitempty"`
+	CPUUtilization    *int32 `json:"cpu_utilization,omitempty"`
+	MemoryUtilization *int32 `json:"memory_utilization,omitempty"`
+	// UpdateMode (Off, Initial, Recreate or Auto) and the bounds of the recommended requests of a
+	// VerticalPodAutoscaler
+	UpdateMode string              `json:"update_mode,omitempty"`
+	MinAllowed corev1.ResourceList `json:"min_allowed,omitempty" swaggertype:"object,string"`
+	MaxAllowed corev1.ResourceList `json:"max_allowed,omitempty" swaggertype:"object,string"`
+}
+
+func (p *AutoscalerParams) kind() string {
+	if p == nil || p.Kind == "" {
+		return HorizontalPodAutoscalerKind
+	}
+	return p.Kind
+}
+
+func (p *AutoscalerParams) validate() error {
+	if p == nil {
+		return nil
+	}
+	switch p.kind() {
+	case HorizontalPodAutoscalerKind:
+		if p.UpdateMode != "" || p.MinAllowed != nil || p.MaxAllowed != nil {
+			return fmt.Errorf("update_mode, min_allowed and max_allowed only apply to a %s", VerticalPodAutoscalerKind)
+		}
+		for _, utilization := range []*int32{p.CPUUtilization, p.MemoryUtilization} {
+			if utilization != nil && *utilization <= 0 {
+				return fmt.Errorf("utilization targets must be positive")
+			}
+		}
+	case VerticalPodAutoscalerKind:
+		if p.MinReplicas != nil || p.MaxReplicas != nil || p.CPUUtilization != nil || p.MemoryUtilization != nil {
+			return fmt.Errorf("min_replicas, max_replicas and utilization targets only apply to a %s", HorizontalPodAutoscalerKind)
+		}
+		if p.UpdateMode != "" && !vpaUpdateModes[p.UpdateMode] {
+			return fmt.Errorf("unknown update mode %q", p.UpdateMode)
+		}
+		for name, min := range p.MinAllowed {
+			if max, ok := p.MaxAllowed[name]; ok && min.Cmp(max) > 0 {
+				return fmt.Errorf("min_allowed %s is above max_allowed", name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown autoscaler kind %q", p.Kind)
+	}
+	return nil
+}
+
+// vpaAvailable reports whether the job's target cluster runs the Vertical Pod Autoscaler.
+func vpaAvailable(j *Job) (bool, error) {
+	cluster, err := j.cluster()
+	if err != nil {
+		return false, err
+	}
+	return cluster.Labels[VPAAvailableLabel] == "true" || cluster.Claims[VPAAvailableLabel] == "true", nil
+}
+
+// autoscalerParams returns the validated autoscaler parameters of a job, which may be nil when removing.
+func autoscalerParams(j *Job) (*AutoscalerParams, error) {
+	if err := j.Remediation.validate(); err != nil {
+		return nil, err
+	}
+	var params *AutoscalerParams
+	if j.Remediation != nil {
+		params = j.Remediation.Autoscaler
+	}
+	if params == nil && j.SubType != RemoveAutoscaler {
+		return nil, fmt.Errorf("remediation.autoscaler is required for %s", j.SubType)
+	}
+	return params, nil
+}
+
+// updateAutoscaler adds, updates or removes, depending on the remediation type, the autoscaler of the
+// targeted Deployments of a deployment. An autoscaler lives in the ManifestWork of its Deployment.
+func updateAutoscaler(j *Job) (*Job, error) {
+	params, err := autoscalerParams(j)
+	if err != nil {
+		logErrorAndSetJobState(fmt.Sprintf("Invalid remediation parameters: %v", err), j, Degraded)
+		return nil, err
+	}
+	if params.kind() == VerticalPodAutoscalerKind && j.SubType != RemoveAutoscaler {
+		available, err := vpaAvailable(j)
+		if err != nil {
+			logErrorAndSetJobState("Error obtaining target cluster", j, Degraded)
+			return nil, err
+		}
+		if !available {
+			logErrorAndSetJobState("Vertical Pod Autoscaler not available", j, Degraded)
+			return nil, fmt.Errorf("the Vertical Pod Autoscaler is not available on cluster %s", j.Target.ClusterName)
+		}
+	}
+	j.Changes = nil
+
+	manifestWork, err := fetchManifestWork(j.Target.ClusterName, j.Resource.ResourceName, nil)
+	if err != nil {
+		logErrorAndSetJobState("Error obtaining applied ManifestWork status", j, Degraded)
+		return nil, err
+	}
+	parts, err := fetchManifestWorkParts(j.Target.ClusterName, manifestWork.Name)
+	if err != nil {
+		logErrorAndSetJobState("Error obtaining ManifestWork parts", j, Degraded)
+		return nil, err
+	}
+
+	works := []*workv1.ManifestWork{manifestWork}
+	for i := range parts {
+		works = append(works, &parts[i])
+	}
+	// all works are changed before any is written, and only the ones with a targeted Deployment are
+	changed, previous := []*workv1.ManifestWork{}, []*workv1.ManifestWork{}
+	changedIndexes := []int{}
+	targets := 0
+	for i, work := range works {
+		original := work.DeepCopy()
+		workTargets, err := updateWorkAutoscalers(work, j, params.kind())
+		if err != nil {
+			logErrorAndSetJobState("Error updating autoscaler", j, Degraded)
+			return nil, err
+		}
+		if workTargets == 0 {
+			continue
+		}
+		targets += workTargets
+		if i == 0 {
+			setOwnershipLabels(work, j)
+		}
+		changed = append(changed, work)
+		previous = append(previous, original)
+		changedIndexes = append(changedIndexes, i)
+	}
+	if targets == 0 {
+		logErrorAndSetJobState("No Deployment to autoscale", j, Degraded)
+		return nil, fmt.Errorf("no Deployment to autoscale in %s", manifestWork.Name)
+	}
+
+	updated, err := updateManifestWorks(changed, previous)
+	if err != nil {
+		logErrorAndSetJobState(fmt.Sprintf("Error updating ManifestWork: %v", err), j, Degraded)
+		return nil, err
+	}
+	updatedManifestWork := manifestWork
+	for k, i := range changedIndexes {
+		if i == 0 {
+			updatedManifestWork = updated[k]
+		} else {
+			parts[i-1] = *updated[k]
+		}
+	}
+	recordRevision(j, j.Target.ClusterName, updatedManifestWork.Name)
+
+	aggregateManifestWorkStatus(updatedManifestWork, parts)
+	j.UpdateJobResource(updatedManifestWork)
+	return j, nil
+}
+
+// updateWorkAutoscalers applies the remediation to the autoscalers of the targeted Deployments of a
+// ManifestWork, returning how many Deployments were targeted. Other manifests are kept as they are.
+func updateWorkAutoscalers(work *workv1.ManifestWork, j *Job, kind string) (int, error) {
+	manifests := append([]workv1.Manifest{}, work.Spec.Workload.Manifests...)
+	objs := make([]runtime.Object, len(manifests))
+	for i, manifest := range manifests {
+		yamlBytes, err := yamlEncode.Marshal(manifest)
+		if err != nil {
+			return 0, fmt.Errorf("error encoding manifest: %v", err)
+		}
+		if objs[i], err = decodeYAMLToObject(string(yamlBytes)); err != nil {
+			return 0, fmt.Errorf("error decoding manifest: %v", err)
+		}
+	}
+
+	var params *AutoscalerParams
+	if j.Remediation != nil {
+		params = j.Remediation.Autoscaler
+	}
+	removed := map[int]bool{}
+	targets := 0
+	for i, obj := range objs {
+		deployment, ok := obj.(*appsv1.Deployment)
+		if !ok || !j.Remediation.targetsComponent(obj) {
+			continue
+		}
+		targets++
+
+		index := -1
+		for k, candidate := range objs {
+			if name, ok := autoscalerTarget(candidate, kind); ok && name == deployment.Name && !removed[k] {
+				index = k
+			}
+		}
+
+		switch j.SubType {
+		case AddAutoscaler:
+			if index >= 0 {
+				return 0, fmt.Errorf("deployment %s already has a %s", deployment.Name, kind)
+			}
+			autoscaler, err := newAutoscaler(kind, deployment, j)
+			if err != nil {
+				return 0, err
+			}
+			if err := applyAutoscalerParams(autoscaler, params); err != nil {
+				return 0, fmt.Errorf("%s %s: %v", kind, deployment.Name, err)
+			}
+			if err := recordAutoscalerChanges(j, nil, autoscaler); err != nil {
+				return 0, err
+			}
+			objs = append(objs, autoscaler)
+			manifests = append(manifests, workv1.Manifest{RawExtension: runtime.RawExtension{Object: autoscaler}})
+
+			// the replica count belongs to the HorizontalPodAutoscaler from now on: the Deployment is applied
+			// server-side without it, so that updates of the ManifestWork do not reset it
+			if kind == HorizontalPodAutoscalerKind {
+				setServerSideApply(work, deployment)
+				if deployment.Spec.Replicas != nil {
+					recorder, err := newChangeRecorder(deployment)
+					if err != nil {
+						return 0, err
+					}
+					recorder.record("spec.replicas", strconv.Itoa(int(*deployment.Spec.Replicas)), unsetValue)
+					j.Changes = append(j.Changes, recorder.changes...)
+					deployment.Spec.Replicas = nil
+					manifests[i] = workv1.Manifest{RawExtension: runtime.RawExtension{Object: deployment}}
+				}
+			}
+		case UpdateAutoscaler:
+			if index < 0 {
+				return 0, fmt.Errorf("deployment %s has no %s", deployment.Name, kind)
+			}
+			previous := objs[index].DeepCopyObject()
+			if err := applyAutoscalerParams(objs[index], params); err != nil {
+				return 0, fmt.Errorf("%s %s: %v", kind, deployment.Name, err)
+			}
+			if err := recordAutoscalerChanges(j, previous, objs[index]); err != nil {
+				return 0, err
+			}
+			manifests[index] = workv1.Manifest{RawExtension: runtime.RawExtension{Object: objs[index]}}
+		case RemoveAutoscaler:
+			if index < 0 {
+				return 0, fmt.Errorf("deployment %s has no %s", deployment.Name, kind)
+			}
+			if err := recordAutoscalerChanges(j, objs[index], nil); err != nil {
+				return 0, err
+			}
+			removed[index] = true
+
+			// the replica count goes back to the Deployment, which is applied like any other manifest again
+			if hpa, ok := objs[index].(*autoscalingv2.HorizontalPodAutoscaler); ok {
+				unsetServerSideApply(work, deployment)
+				replicas := releasedReplicas(hpa, j.Remediation)
+				recorder, err := newChangeRecorder(deployment)
+				if err != nil {
+					return 0, err
+				}
+				recorder.record("spec.replicas", unsetValue, strconv.Itoa(int(replicas)))
+				j.Changes = append(j.Changes, recorder.changes...)
+				deployment.Spec.Replicas = &replicas
+				manifests[i] = workv1.Manifest{RawExtension: runtime.RawExtension{Object: deployment}}
+			}
+		}
+	}
+
+	work.Spec.Workload.Manifests = make([]workv1.Manifest, 0, len(manifests))
+	for i, manifest := range manifests {
+		if !removed[i] {
+			work.Spec.Workload.Manifests = append(work.Spec.Workload.Manifests, manifest)
+		}
+	}
+	return targets, nil
+}
+
+// autoscalerTarget returns the name of the Deployment an autoscaler of the given kind scales.
+func autoscalerTarget(obj runtime.Object, kind string) (string, bool) {
+	switch autoscaler := obj.(type) {
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		target := autoscaler.Spec.ScaleTargetRef
+		return target.Name, kind == HorizontalPodAutoscalerKind && target.Kind == "Deployment"
+	case *unstructured.Unstructured:
+		if kind != VerticalPodAutoscalerKind || autoscaler.GroupVersionKind().GroupKind() != vpaGroupVersion.WithKind(kind).GroupKind() {
+			return "", false
+		}
+		targetKind, _, _ := unstructured.NestedString(autoscaler.Object, "spec", "targetRef", "kind")
+		name, _, _ := unstructured.NestedString(autoscaler.Object, "spec", "targetRef", "name")
+		return name, targetKind == "Deployment"
+	}
+	return "", false
+}
+
+// newAutoscaler generates an autoscaler of the given kind for a Deployment, named after it.
+func newAutoscaler(kind string, deployment *appsv1.Deployment, j *Job) (runtime.Object, error) {
+	var autoscaler runtime.Object
+	if kind == HorizontalPodAutoscalerKind {
+		autoscaler = &autoscalingv2.HorizontalPodAutoscaler{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "autoscaling/v2", Kind: kind},
+			ObjectMeta: metav1.ObjectMeta{Name: deployment.Name},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: deployment.Name},
+			},
+		}
+	} else {
+		vpa := &unstructured.Unstructured{}
+		vpa.SetGroupVersionKind(vpaGroupVersion.WithKind(kind))
+		vpa.SetName(deployment.Name)
+		targetRef := map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": deployment.Name}
+		if err := unstructured.SetNestedMap(vpa.Object, targetRef, "spec", "targetRef"); err != nil {
+			return nil, err
+		}
+		autoscaler = vpa
+	}
+	if err := updateNamespaceAndAnnotations(autoscaler, deployment.Namespace, j.JobGroupName, j.Resource.ResourceName, j.JobGroupID, j.Resource.ID); err != nil {
+		return nil, err
+	}
+	return autoscaler, nil
+}
+
+// applyAutoscalerParams sets the thresholds given in params on an autoscaler.
+func applyAutoscalerParams(obj runtime.Object, params *AutoscalerParams) error {
+	switch autoscaler := obj.(type) {
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		if params.MinReplicas != nil {
+			minReplicas := *params.MinReplicas
+			autoscaler.Spec.MinReplicas = &minReplicas
+		}
+		if params.MaxReplicas != nil {
+			autoscaler.Spec.MaxReplicas = *params.MaxReplicas
+		}
+		if params.CPUUtilization != nil {
+			setUtilizationTarget(autoscaler, corev1.ResourceCPU, *params.CPUUtilization)
+		}
+		if params.MemoryUtilization != nil {
+			setUtilizationTarget(autoscaler, corev1.ResourceMemory, *params.MemoryUtilization)
+		}
+		if autoscaler.Spec.MaxReplicas < 1 {
+			return fmt.Errorf("max_replicas is required and must be at least 1")
+		}
+		if minReplicas := autoscaler.Spec.MinReplicas; minReplicas != nil && (*minReplicas < 1 || *minReplicas > autoscaler.Spec.MaxReplicas) {
+			return fmt.Errorf("min_replicas must be between 1 and max_replicas")
+		}
+	case *unstructured.Unstructured:
+		if params.UpdateMode != "" {
+			if err := unstructured.SetNestedField(autoscaler.Object, params.UpdateMode, "spec", "updatePolicy", "updateMode"); err != nil {
+				return err
+			}
+		}
+		if params.MinAllowed == nil && params.MaxAllowed == nil {
+			return nil
+		}
+		// the bounds apply to all the containers of the Deployment
+		policies, _, err := unstructured.NestedSlice(autoscaler.Object, "spec", "resourcePolicy", "containerPolicies")
+		if err != nil {
+			return err
+		}
+		index := -1
+		for i, policy := range policies {
+			if policy, ok := policy.(map[string]interface{}); ok && policy["containerName"] == "*" {
+				index = i
+			}
+		}
+		if index < 0 {
+			policies = append(policies, map[string]interface{}{"containerName": "*"})
+			index = len(policies) - 1
+		}
+		policy := policies[index].(map[string]interface{})
+		if params.MinAllowed != nil {
+			policy["minAllowed"] = resourceListValue(params.MinAllowed)
+		}
+		if params.MaxAllowed != nil {
+			policy["maxAllowed"] = resourceListValue(params.MaxAllowed)
+		}
+		return unstructured.SetNestedSlice(autoscaler.Object, policies, "spec", "resourcePolicy", "containerPolicies")
+	}
+	return nil
+}
+
+// setUtilizationTarget sets the average utilization target of a resource metric, adding the metric if needed.
+func setUtilizationTarget(hpa *autoscalingv2.HorizontalPodAutoscaler, name corev1.ResourceName, utilization int32) {
+	target := autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &utilization}
+	for i, metric := range hpa.Spec.Metrics {
+		if metric.Type == autoscalingv2.ResourceMetricSourceType && metric.Resource != nil && metric.Resource.Name == name {
+			hpa.Spec.Metrics[i].Resource.Target = target
+			return
+		}
+	}
+	hpa.Spec.Metrics = append(hpa.Spec.Metrics, autoscalingv2.MetricSpec{
+		Type:     autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{Name: name, Target: target},
+	})
+}
+
+func resourceListValue(resources corev1.ResourceList) map[string]interface{} {
+	value := map[string]interface{}{}
+	for name, quantity := range resources {
+		value[string(name)] = quantity.String()
+	}
+	return value
+}
+
+// releasedReplicas returns the replica count a Deployment gets back when its HorizontalPodAutoscaler is
+// removed: the replicas of the job if given, otherwise the minimum of the autoscaler.
+func releasedReplicas(hpa *autoscalingv2.HorizontalPodAutoscaler, params *RemediationParams) int32 {
+	if params != nil && params.Replicas != nil {
+		return *params.Replicas
+	}
+	if hpa.Spec.MinReplicas != nil {
+		return *hpa.Spec.MinReplicas
+	}
+	// the default minimum of a HorizontalPodAutoscaler
+	return 1
+}
+
+// autoscaledDeployments returns the names of the Deployments scaled by a HorizontalPodAutoscaler among objs.
+func autoscaledDeployments(objs []runtime.Object) map[string]bool {
+	names := map[string]bool{}
+	for _, obj := range objs {
+		if name, ok := autoscalerTarget(obj, HorizontalPodAutoscalerKind); ok {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// setServerSideApply makes the work agent apply a Deployment server-side.
+func setServerSideApply(work *workv1.ManifestWork, deployment *appsv1.Deployment) {
+	identifier := workv1.ResourceIdentifier{Group: "apps", Resource: "deployments", Name: deployment.Name, Namespace: deployment.Namespace}
+	strategy := &workv1.UpdateStrategy{Type: workv1.UpdateStrategyTypeServerSideApply}
+	for i, option := range work.Spec.ManifestConfigs {
+		if option.ResourceIdentifier == identifier {
+			work.Spec.ManifestConfigs[i].UpdateStrategy = strategy
+			return
+		}
+	}
+	work.Spec.ManifestConfigs = append(work.Spec.ManifestConfigs, workv1.ManifestConfigOption{ResourceIdentifier: identifier, UpdateStrategy: strategy})
+}
+
+// unsetServerSideApply reverts setServerSideApply, dropping the config of the Deployment unless it has
+// feedback rules.
+func unsetServerSideApply(work *workv1.ManifestWork, deployment *appsv1.Deployment) {
+	identifier := workv1.ResourceIdentifier{Group: "apps", Resource: "deployments", Name: deployment.Name, Namespace: deployment.Namespace}
+	options := work.Spec.ManifestConfigs[:0]
+	for _, option := range work.Spec.ManifestConfigs {
+		if option.ResourceIdentifier == identifier {
+			if len(option.FeedbackRules) == 0 {
+				continue
+			}
+			option.UpdateStrategy = nil
+		}
+		options = append(options, option)
+	}
+	if len(options) == 0 {
+		options = nil
+	}
+	work.Spec.ManifestConfigs = options
+}
+
+// recordAutoscalerChanges records the changes of the spec of an autoscaler, previous or autoscaler being
+// nil when it is added or removed.
+func recordAutoscalerChanges(j *Job, previous, autoscaler runtime.Object) error {
+	specs := []interface{}{nil, nil}
+	var recorder *changeRecorder
+	for i, obj := range []runtime.Object{previous, autoscaler} {
+		if obj == nil {
+			continue
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return fmt.Errorf("error converting autoscaler: %v", err)
+		}
+		specs[i] = content["spec"]
+		if recorder, err = newChangeRecorder(obj); err != nil {
+			return err
+		}
+	}
+	recorder.recordFieldChanges("spec", specs[0], specs[1])
+	j.Changes = append(j.Changes, recorder.changes...)
+	return nil
+}
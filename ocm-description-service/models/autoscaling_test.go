@@ -0,0 +1,166 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAutoscaler(t *testing.T) {
+	workClient := newFakeWorkClient()
+	clusterClient := clusterfake.NewSimpleClientset(&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}})
+	useFakeClients(t, workClient, clusterClient)
+	t.Setenv("MANIFEST_VALIDATION", "false")
+
+	j := MockCreateDeploymentJob()
+	created, err := createManifestWork(&j)
+	assert.NoError(t, err)
+	int32Ptr := func(n int32) *int32 { return &n }
+	remediate := func(subType RemediationType, params *AutoscalerParams) (*Job, *workv1.ManifestWork, error) {
+		update := MockUpdateJob(subType)
+		update.Resource.ResourceName = created.Name
+		update.Remediation = &RemediationParams{Autoscaler: params}
+		_, err := updateDeployment(&update)
+		live, getErr := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, getErr)
+		return &update, live, err
+	}
+	findObject := func(work *workv1.ManifestWork, kind string) runtime.Object {
+		for _, manifest := range work.Spec.Workload.Manifests {
+			if manifest.Object.GetObjectKind().GroupVersionKind().Kind == kind {
+				return manifest.Object
+			}
+		}
+		return nil
+	}
+
+	t.Run("should add a HorizontalPodAutoscaler owning the replica count", func(t *testing.T) {
+		update, live, err := remediate(AddAutoscaler, &AutoscalerParams{MaxReplicas: int32Ptr(5), CPUUtilization: int32Ptr(70)})
+		assert.NoError(t, err)
+
+		hpa, ok := findObject(live, HorizontalPodAutoscalerKind).(*autoscalingv2.HorizontalPodAutoscaler)
+		if !assert.True(t, ok) {
+			return
+		}
+		assert.Equal(t, "nginx", hpa.Name)
+		assert.Equal(t, "icos-test", hpa.Namespace)
+		assert.Equal(t, autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "nginx"}, hpa.Spec.ScaleTargetRef)
+		assert.Equal(t, int32(5), hpa.Spec.MaxReplicas)
+		assert.Equal(t, int32(70), *hpa.Spec.Metrics[0].Resource.Target.AverageUtilization)
+		assert.Equal(t, "9e8d7c6b-5a4f-4e3d-2c1b-0a9f8e7d6c5b", hpa.Annotations[ManifestAnnotation])
+
+		assert.Nil(t, findObject(live, "Deployment").(*appsv1.Deployment).Spec.Replicas)
+		assert.Equal(t, []workv1.ManifestConfigOption{{
+			ResourceIdentifier: workv1.ResourceIdentifier{Group: "apps", Resource: "deployments", Name: "nginx", Namespace: "icos-test"},
+			UpdateStrategy:     &workv1.UpdateStrategy{Type: workv1.UpdateStrategyTypeServerSideApply},
+		}}, live.Spec.ManifestConfigs)
+
+		assert.Contains(t, update.Changes, FieldChange{Kind: HorizontalPodAutoscalerKind, Name: "nginx", Field: "spec.maxReplicas", Old: unsetValue, New: "5"})
+		assert.Contains(t, update.Changes, FieldChange{Kind: "Deployment", Name: "nginx", Field: "spec.replicas", Old: "1", New: unsetValue})
+		assert.Len(t, live.Spec.Workload.Manifests, len(created.Spec.Workload.Manifests)+1)
+
+		_, _, err = remediate(AddAutoscaler, &AutoscalerParams{MaxReplicas: int32Ptr(5)})
+		assert.ErrorContains(t, err, "deployment nginx already has a HorizontalPodAutoscaler")
+	})
+
+	t.Run("should update the thresholds of the autoscaler", func(t *testing.T) {
+		update, live, err := remediate(UpdateAutoscaler, &AutoscalerParams{CPUUtilization: int32Ptr(50)})
+		assert.NoError(t, err)
+		assert.Equal(t, []FieldChange{{
+			Kind: HorizontalPodAutoscalerKind, Name: "nginx", Field: "spec.metrics[0].resource.target.averageUtilization", Old: "70", New: "50",
+		}}, update.Changes)
+		hpa := findObject(live, HorizontalPodAutoscalerKind).(*autoscalingv2.HorizontalPodAutoscaler)
+		assert.Equal(t, int32(5), hpa.Spec.MaxReplicas)
+
+		_, _, err = remediate(UpdateAutoscaler, &AutoscalerParams{MinReplicas: int32Ptr(6)})
+		assert.ErrorContains(t, err, "min_replicas must be between 1 and max_replicas")
+	})
+
+	t.Run("should refuse to scale Deployments owned by an autoscaler", func(t *testing.T) {
+		_, live, err := remediate(ScaleUp, nil)
+		assert.ErrorContains(t, err, "deployment nginx is scaled by a HorizontalPodAutoscaler")
+		assert.Nil(t, findObject(live, "Deployment").(*appsv1.Deployment).Spec.Replicas)
+	})
+
+	t.Run("should remove the autoscaler", func(t *testing.T) {
+		update, live, err := remediate(RemoveAutoscaler, nil)
+		assert.NoError(t, err)
+		assert.Nil(t, findObject(live, HorizontalPodAutoscalerKind))
+		assert.Contains(t, update.Changes, FieldChange{Kind: HorizontalPodAutoscalerKind, Name: "nginx", Field: "spec.maxReplicas", Old: "5", New: unsetValue})
+		assert.NotNil(t, findObject(live, "Service"))
+
+		// the Deployment gets its replica count back, at the minimum of the autoscaler
+		assert.Equal(t, int32(1), *findObject(live, "Deployment").(*appsv1.Deployment).Spec.Replicas)
+		assert.Contains(t, update.Changes, FieldChange{Kind: "Deployment", Name: "nginx", Field: "spec.replicas", Old: unsetValue, New: "1"})
+		assert.Empty(t, live.Spec.ManifestConfigs)
+
+		_, _, err = remediate(RemoveAutoscaler, nil)
+		assert.ErrorContains(t, err, "deployment nginx has no HorizontalPodAutoscaler")
+	})
+
+	t.Run("should only add a VerticalPodAutoscaler where available", func(t *testing.T) {
+		params := &AutoscalerParams{Kind: VerticalPodAutoscalerKind, UpdateMode: "Auto", MaxAllowed: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}}
+		_, _, err := remediate(AddAutoscaler, params)
+		assert.ErrorContains(t, err, "not available on cluster cluster1")
+
+		_, err = clusterClient.ClusterV1().ManagedClusters().Update(context.TODO(), &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster1", Labels: map[string]string{VPAAvailableLabel: "true"}},
+		}, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		update, live, err := remediate(AddAutoscaler, params)
+		assert.NoError(t, err)
+		vpa, ok := findObject(live, VerticalPodAutoscalerKind).(*unstructured.Unstructured)
+		if !assert.True(t, ok) {
+			return
+		}
+		assert.Equal(t, "autoscaling.k8s.io/v1", vpa.GetAPIVersion())
+		mode, _, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode")
+		assert.Equal(t, "Auto", mode)
+		policies, _, _ := unstructured.NestedSlice(vpa.Object, "spec", "resourcePolicy", "containerPolicies")
+		assert.Equal(t, []interface{}{map[string]interface{}{"containerName": "*", "maxAllowed": map[string]interface{}{"cpu": "2"}}}, policies)
+		for _, change := range update.Changes {
+			assert.Equal(t, VerticalPodAutoscalerKind, change.Kind, "a VerticalPodAutoscaler leaves the Deployment alone")
+		}
+	})
+
+	t.Run("should reject thresholds of the other kind", func(t *testing.T) {
+		assert.Error(t, (&AutoscalerParams{Kind: VerticalPodAutoscalerKind, MaxReplicas: int32Ptr(3)}).validate())
+		assert.Error(t, (&AutoscalerParams{UpdateMode: "Auto"}).validate())
+		assert.Error(t, (&AutoscalerParams{Kind: "KEDA"}).validate())
+		_, _, err := remediate(AddAutoscaler, nil)
+		assert.ErrorContains(t, err, "remediation.autoscaler is required")
+	})
+}
@@ -0,0 +1,165 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+// prefixes of the annotations linking an object to its ICOS application and Job Manager resource
+var appAnnotationPrefixes = []string{"app.icos.eu/", "jobmanager.icos.eu/"}
+
+// ErrResourceNotFound is returned when a cluster has no resource with the requested name.
+var ErrResourceNotFound = errors.New("resource not found")
+
+// ResourceDetail is a resource with the status of every object of its ManifestWork and parts.
+type ResourceDetail struct {
+	Resource
+	Manifests []ManifestStatus `json:"manifests"`
+}
+
+// ManifestStatus is the status of an object of a ManifestWork, as reported by the work agent of the
+// managed cluster.
+type ManifestStatus struct {
+	// ManifestWork is the primary ManifestWork or the part holding the object
+	ManifestWork string `json:"manifest_work"`
+	// Ordinal is the index of the object within its ManifestWork
+	Ordinal    int32  `json:"ordinal"`
+	APIVersion string `json:"api_version"`
+	Kind       string `json:"kind"`
+	// Resource is the API resource of the object, once reported by the work agent
+	Resource  string `json:"resource,omitempty"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Annotations are the annotations linking the object to its ICOS application
+	Annotations map[string]string  `json:"annotations,omitempty"`
+	Conditions  []metav1.Condition `json:"conditions,omitempty"`
+	// Feedback are the status feedback values of the object, by name
+	Feedback map[string]interface{} `json:"feedback,omitempty" swaggertype:"object"`
+}
+
+// GetResourceDetail returns a resource of a cluster with the status of each of its objects.
+func GetResourceDetail(cluster, name string) (*ResourceDetail, error) {
+	work, err := clientsetWorkOper.WorkV1().ManifestWorks(cluster).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: no ManifestWork %s in cluster %s", ErrResourceNotFound, name, cluster)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining ManifestWork %s: %v", name, err)
+	}
+	// parts and works the deploy manager did not create are not resources
+	resourceID, jobID, managed := attributeManifestWork(work)
+	if !managed || work.Labels[PartOfLabel] != "" {
+		return nil, fmt.Errorf("%w: ManifestWork %s in cluster %s is not a resource", ErrResourceNotFound, name, cluster)
+	}
+	parts, err := fetchManifestWorkParts(cluster, work.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &ResourceDetail{Manifests: []ManifestStatus{}}
+	for _, w := range append([]workv1.ManifestWork{*work}, parts...) {
+		detail.Manifests = append(detail.Manifests, manifestStatuses(&w)...)
+	}
+
+	aggregateManifestWorkStatus(work, parts)
+	detail.Resource = workResource(work, resourceID, jobID)
+	for _, part := range parts {
+		detail.ResourceParts = append(detail.ResourceParts, part.Name)
+	}
+	return detail, nil
+}
+
+// manifestStatuses joins the objects of a ManifestWork with the status reported for them, by ordinal.
+func manifestStatuses(work *workv1.ManifestWork) []ManifestStatus {
+	reported := map[int32]workv1.ManifestCondition{}
+	for _, condition := range work.Status.ResourceStatus.Manifests {
+		reported[condition.ResourceMeta.Ordinal] = condition
+	}
+
+	statuses := make([]ManifestStatus, 0, len(work.Spec.Workload.Manifests))
+	for i, manifest := range work.Spec.Workload.Manifests {
+		status := ManifestStatus{ManifestWork: work.Name, Ordinal: int32(i)}
+		if obj, err := manifestMetadata(manifest); err == nil {
+			status.APIVersion = obj.APIVersion
+			status.Kind = obj.Kind
+			status.Name = obj.Name
+			status.Namespace = obj.Namespace
+			status.Annotations = appAnnotations(obj.Annotations)
+		}
+		if condition, ok := reported[int32(i)]; ok {
+			resourceMeta := condition.ResourceMeta
+			if status.Kind == "" {
+				status.APIVersion = schema.GroupVersion{Group: resourceMeta.Group, Version: resourceMeta.Version}.String()
+				status.Kind, status.Name, status.Namespace = resourceMeta.Kind, resourceMeta.Name, resourceMeta.Namespace
+			}
+			status.Resource = resourceMeta.Resource
+			status.Conditions = condition.Conditions
+			status.Feedback = feedbackValues(condition.StatusFeedbacks.Values)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func appAnnotations(annotations map[string]string) map[string]string {
+	linked := map[string]string{}
+	for key, value := range annotations {
+		for _, prefix := range appAnnotationPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				linked[key] = value
+			}
+		}
+	}
+	if len(linked) == 0 {
+		return nil
+	}
+	return linked
+}
+
+// feedbackValues maps the status feedback values of an object to their value, whatever their type.
+func feedbackValues(values []workv1.FeedbackValue) map[string]interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	feedback := map[string]interface{}{}
+	for _, value := range values {
+		switch field := value.Value; {
+		case field.Integer != nil:
+			feedback[value.Name] = *field.Integer
+		case field.String != nil:
+			feedback[value.Name] = *field.String
+		case field.Boolean != nil:
+			feedback[value.Name] = *field.Boolean
+		case field.JsonRaw != nil:
+			feedback[value.Name] = *field.JsonRaw
+		}
+	}
+	return feedback
+}
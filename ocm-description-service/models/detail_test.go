@@ -0,0 +1,104 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetResourceDetail(t *testing.T) {
+	workClient := newFakeWorkClient()
+	useFakeClients(t, workClient, clusterfake.NewSimpleClientset(&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}))
+	t.Setenv("MANIFEST_VALIDATION", "false")
+
+	j := MockCreateDeploymentJob()
+	created, err := createManifestWork(&j)
+	assert.NoError(t, err)
+	ordinal := len(created.Spec.Workload.Manifests) - 2
+	readyReplicas := int64(1)
+	created.Status.Conditions = []metav1.Condition{{Type: workv1.WorkDegraded, Status: metav1.ConditionTrue, Reason: "ResourceNotAvailable"}}
+	created.Status.ResourceStatus.Manifests = []workv1.ManifestCondition{{
+		ResourceMeta: workv1.ManifestResourceMeta{Ordinal: int32(ordinal), Group: "apps", Version: "v1", Kind: "Deployment", Resource: "deployments", Name: "nginx", Namespace: "icos-test"},
+		StatusFeedbacks: workv1.StatusFeedbackResult{Values: []workv1.FeedbackValue{
+			{Name: "ReadyReplicas", Value: workv1.FieldValue{Type: workv1.Integer, Integer: &readyReplicas}},
+		}},
+		Conditions: []metav1.Condition{{Type: workv1.ManifestAvailable, Status: metav1.ConditionFalse, Reason: "NotAvailable"}},
+	}}
+	_, err = workClient.WorkV1().ManifestWorks("cluster1").UpdateStatus(context.TODO(), created, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	t.Run("should report the status of every object", func(t *testing.T) {
+		detail, err := GetResourceDetail("cluster1", created.Name)
+		assert.NoError(t, err)
+		assert.Equal(t, created.Name, detail.ResourceName)
+		assert.Equal(t, ResourceDegraded, detail.State)
+		assert.Equal(t, "cluster1", detail.ClusterName)
+		if !assert.Len(t, detail.Manifests, len(created.Spec.Workload.Manifests)) {
+			return
+		}
+
+		deployment := detail.Manifests[ordinal]
+		assert.Equal(t, "apps/v1", deployment.APIVersion)
+		assert.Equal(t, "Deployment", deployment.Kind)
+		assert.Equal(t, "nginx", deployment.Name)
+		assert.Equal(t, "icos-test", deployment.Namespace)
+		assert.Equal(t, "deployments", deployment.Resource)
+		assert.Equal(t, map[string]interface{}{"ReadyReplicas": int64(1)}, deployment.Feedback)
+		assert.Equal(t, "NotAvailable", deployment.Conditions[0].Reason)
+		assert.Equal(t, map[string]string{
+			"app.icos.eu/name":      "nginx-app",
+			"app.icos.eu/component": "nginx",
+			AppInstanceAnnotation:   j.JobGroupID,
+			ManifestAnnotation:      j.Resource.ID,
+		}, deployment.Annotations)
+
+		service := detail.Manifests[ordinal+1]
+		assert.Equal(t, "Service", service.Kind)
+		assert.Empty(t, service.Conditions)
+		assert.Nil(t, service.Feedback)
+	})
+
+	t.Run("should report missing resources", func(t *testing.T) {
+		_, err := GetResourceDetail("cluster1", "missing")
+		assert.ErrorIs(t, err, ErrResourceNotFound)
+	})
+
+	t.Run("should not report parts or foreign works as resources", func(t *testing.T) {
+		for _, work := range []*workv1.ManifestWork{
+			{ObjectMeta: metav1.ObjectMeta{Name: created.Name + "-part-1", Namespace: "cluster1", Labels: map[string]string{PartOfLabel: created.Name, ResourceIDLabel: j.Resource.ID}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "cluster1"}},
+		} {
+			_, err := workClient.WorkV1().ManifestWorks("cluster1").Create(context.TODO(), work, metav1.CreateOptions{})
+			assert.NoError(t, err)
+			_, err = GetResourceDetail("cluster1", work.Name)
+			assert.ErrorIs(t, err, ErrResourceNotFound)
+		}
+	})
+}
@@ -0,0 +1,270 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	workv1 "open-cluster-management.io/api/work/v1"
+	yamlEncode "sigs.k8s.io/yaml"
+)
+
+// metadata fields populated by the API server, ignored when comparing manifests
+var serverMetadataFields = []string{
+	"uid", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp",
+	"deletionGracePeriodSeconds", "managedFields", "selfLink",
+}
+
+// ManifestDiff is the difference between the manifests of a deployment on the hub and the ones a job
+// would produce. Objects are identified as Kind.group/namespace/name, without the group for core kinds
+// and without the namespace when cluster-scoped.
+type ManifestDiff struct {
+	ManifestWork string   `json:"manifest_work"`
+	Added        []string `json:"added"`
+	Removed      []string `json:"removed"`
+	Changed      []string `json:"changed"`
+	// Fields are the fields of the changed objects that differ, from the object root
+	Fields []FieldChange `json:"fields"`
+	// Unified is a unified diff of the objects as YAML
+	Unified string `json:"unified"`
+}
+
+// DiffJob compares the manifests of the job's deployment, across its ManifestWork and parts, with the
+// ones the job would produce, without changing anything.
+func DiffJob(j *Job) (*ManifestDiff, error) {
+	if j.Resource == nil || j.Resource.ResourceName == "" {
+		return nil, fmt.Errorf("the job has no resource to compare with")
+	}
+	manifestWork, err := fetchManifestWork(j.Target.ClusterName, j.Resource.ResourceName, nil)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := fetchManifestWorkParts(j.Target.ClusterName, manifestWork.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	works := []*workv1.ManifestWork{manifestWork}
+	for i := range parts {
+		works = append(works, &parts[i])
+	}
+	live := []workv1.Manifest{}
+	for _, work := range works {
+		live = append(live, work.Spec.Workload.Manifests...)
+	}
+	desired, err := desiredManifests(j, works)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := diffManifests(live, desired)
+	if err != nil {
+		return nil, err
+	}
+	diff.ManifestWork = manifestWork.Name
+	return diff, nil
+}
+
+// desiredManifests returns the manifests the job would leave in the given ManifestWorks, applying its
+// remediation to copies of them.
+func desiredManifests(j *Job, works []*workv1.ManifestWork) ([]workv1.Manifest, error) {
+	switch j.Type {
+	case CreateDeployment, ReplaceDeployment:
+		generated, err := GenerateManifestWorks(j)
+		if err != nil {
+			return nil, fmt.Errorf("error generating manifests: %v", err)
+		}
+		desired := []workv1.Manifest{}
+		for _, work := range generated {
+			desired = append(desired, work.Spec.Workload.Manifests...)
+		}
+		return desired, nil
+	case DeleteDeployment:
+		return nil, nil
+	case UpdateDeployment:
+	default:
+		return nil, fmt.Errorf("job type does not exist: %v", j.Type)
+	}
+
+	job := *j
+	job.Changes = nil
+	desired := []workv1.Manifest{}
+	switch j.SubType {
+	case Reallocation:
+		return nil, nil
+	case Rollback:
+		if job.Remediation == nil || job.Remediation.Revision <= 0 {
+			return nil, fmt.Errorf("invalid remediation parameters: remediation.revision is required")
+		}
+		revision, err := GetRevision(j.Target.ClusterName, j.Resource.ResourceName, job.Remediation.Revision)
+		if err != nil {
+			return nil, err
+		}
+		for _, spec := range revision.Works {
+			desired = append(desired, spec.Workload.Manifests...)
+		}
+	case AddAutoscaler, UpdateAutoscaler, RemoveAutoscaler:
+		params, err := autoscalerParams(&job)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remediation parameters: %v", err)
+		}
+		for _, work := range works {
+			work = work.DeepCopy()
+			if _, err := updateWorkAutoscalers(work, &job, params.kind()); err != nil {
+				return nil, err
+			}
+			desired = append(desired, work.Spec.Workload.Manifests...)
+		}
+	case ScaleUp, ScaleDown, ScaleOut, ScaleIn, SecurityRemediation:
+		if err := job.Remediation.validate(); err != nil {
+			return nil, fmt.Errorf("invalid remediation parameters: %v", err)
+		}
+		for _, work := range works {
+			manifests, err := updateManifestsAttributes(work.DeepCopy().Spec.Workload.Manifests, &job)
+			if err != nil {
+				return nil, err
+			}
+			desired = append(desired, manifests...)
+		}
+	default:
+		return nil, fmt.Errorf("job sub type does not exist: %v", j.SubType)
+	}
+	return desired, nil
+}
+
+// diffManifests compares two sets of manifests object by object, ignoring server-populated fields and
+// the bookkeeping annotations of the deploy manager.
+func diffManifests(live, desired []workv1.Manifest) (*ManifestDiff, error) {
+	liveObjects, err := indexManifests(live)
+	if err != nil {
+		return nil, err
+	}
+	desiredObjects, err := indexManifests(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for key := range liveObjects {
+		keys = append(keys, key)
+	}
+	for key := range desiredObjects {
+		if _, ok := liveObjects[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	diff := &ManifestDiff{Added: []string{}, Removed: []string{}, Changed: []string{}, Fields: []FieldChange{}}
+	var liveYAML, desiredYAML strings.Builder
+	for _, key := range keys {
+		current, inLive := liveObjects[key]
+		wanted, inDesired := desiredObjects[key]
+		current = normalizeObject(current)
+		wanted = normalizeObject(wanted)
+
+		switch {
+		case !inLive:
+			diff.Added = append(diff.Added, key)
+		case !inDesired:
+			diff.Removed = append(diff.Removed, key)
+		case !reflect.DeepEqual(current, wanted):
+			diff.Changed = append(diff.Changed, key)
+			kind, _, _ := strings.Cut(key[:strings.Index(key, "/")], ".")
+			name := key[strings.LastIndex(key, "/")+1:]
+			recorder := &changeRecorder{kind: kind, name: name}
+			recorder.recordFieldChanges("", current, wanted)
+			diff.Fields = append(diff.Fields, recorder.changes...)
+		}
+
+		for _, object := range []struct {
+			builder *strings.Builder
+			value   interface{}
+			present bool
+		}{{&liveYAML, current, inLive}, {&desiredYAML, wanted, inDesired}} {
+			if !object.present {
+				continue
+			}
+			encoded, err := yamlEncode.Marshal(object.value)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding %s: %v", key, err)
+			}
+			fmt.Fprintf(object.builder, "--- # %s\n%s", key, encoded)
+		}
+	}
+
+	unified, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(liveYAML.String()),
+		B:        difflib.SplitLines(desiredYAML.String()),
+		FromFile: "hub",
+		ToFile:   "job",
+		Context:  3,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error computing unified diff: %v", err)
+	}
+	diff.Unified = unified
+	return diff, nil
+}
+
+// normalizeObject drops the status, the server-populated metadata and the null or empty fields of a
+// generic object, which typed and raw manifests do not encode alike.
+func normalizeObject(obj interface{}) interface{} {
+	object, ok := obj.(map[string]interface{})
+	if !ok {
+		return obj
+	}
+	delete(object, "status")
+	if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+		for _, field := range serverMetadataFields {
+			delete(metadata, field)
+		}
+	}
+	return pruneEmpty(object)
+}
+
+func pruneEmpty(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			field = pruneEmpty(field)
+			if field == nil {
+				delete(value, key)
+				continue
+			}
+			value[key] = field
+		}
+		if len(value) == 0 {
+			return nil
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = pruneEmpty(item)
+		}
+		return value
+	}
+	return value
+}
@@ -0,0 +1,92 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiffJob(t *testing.T) {
+	workClient := newFakeWorkClient()
+	clusterClient := clusterfake.NewSimpleClientset(&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}})
+	useFakeClients(t, workClient, clusterClient)
+	t.Setenv("MANIFEST_VALIDATION", "false")
+
+	j := MockCreateDeploymentJob()
+	created, err := createManifestWork(&j)
+	assert.NoError(t, err)
+
+	t.Run("should list the objects and fields a replacement changes", func(t *testing.T) {
+		replace := MockCreateDeploymentJob()
+		replace.Type = ReplaceDeployment
+		replace.Resource.ResourceName = created.Name
+		replace.Manifests = []PlainManifest{
+			{YamlString: strings.Replace(mockDeploymentYaml, "replicas: 1", "replicas: 3", 1)},
+			{YamlString: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: nginx-config\ndata:\n  mode: production"},
+		}
+
+		diff, err := DiffJob(&replace)
+		assert.NoError(t, err)
+		assert.Equal(t, created.Name, diff.ManifestWork)
+		assert.Equal(t, []string{"ConfigMap/icos-test/nginx-config"}, diff.Added)
+		assert.Equal(t, []string{"Service/icos-test/nginx"}, diff.Removed)
+		assert.Equal(t, []string{"Deployment.apps/icos-test/nginx"}, diff.Changed)
+		assert.Equal(t, []FieldChange{{Kind: "Deployment", Name: "nginx", Field: "spec.replicas", Old: "1", New: "3"}}, diff.Fields)
+		assert.Contains(t, diff.Unified, "--- hub\n+++ job\n")
+		assert.Contains(t, diff.Unified, "-  replicas: 1\n+  replicas: 3\n")
+		assert.Contains(t, diff.Unified, "+  mode: production\n")
+		assert.NotContains(t, diff.Unified, "creationTimestamp")
+	})
+
+	t.Run("should preview an update without applying it", func(t *testing.T) {
+		update := MockUpdateJob(ScaleUp)
+		update.Resource.ResourceName = created.Name
+		update.Manifests = nil
+
+		diff, err := DiffJob(&update)
+		assert.NoError(t, err)
+		assert.Empty(t, diff.Added)
+		assert.Empty(t, diff.Removed)
+		assert.Equal(t, []string{"Deployment.apps/icos-test/nginx"}, diff.Changed)
+		assert.Equal(t, []FieldChange{{Kind: "Deployment", Name: "nginx", Field: "spec.replicas", Old: "1", New: "2"}}, diff.Fields)
+
+		live, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, created.Spec.Workload.Manifests, live.Spec.Workload.Manifests)
+		assert.Empty(t, update.Changes)
+	})
+
+	t.Run("should compare nothing without an existing resource", func(t *testing.T) {
+		missing := MockUpdateJob(ScaleUp)
+		missing.Resource.ResourceName = ""
+		_, err := DiffJob(&missing)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,298 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"icos/server/ocm-description-service/utils/logs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	worklisters "open-cluster-management.io/api/client/work/listers/work/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+// types of the events streamed by WatchResources
+const (
+	ResourceAdded    = "ADDED"
+	ResourceModified = "MODIFIED"
+	ResourceDeleted  = "DELETED"
+
+	// how long a new stream waits for the shared ManifestWork cache to be filled
+	eventCacheSyncTimeout = 30 * time.Second
+)
+
+// EventFilter selects the resources whose events are streamed. Empty filters match every resource.
+type EventFilter struct {
+	ClusterName  string
+	JobGroupID   string
+	ResourceID   string
+	ResourceName string
+}
+
+// ResourceEvent is a change of the conditions of a resource, aggregated over its ManifestWork and parts.
+type ResourceEvent struct {
+	Type      string    `json:"type"`
+	Resource  Resource  `json:"resource"`
+	State     JobState  `json:"state"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (f *EventFilter) matches(resource *Resource) bool {
+	return (f.ClusterName == "" || resource.ClusterName == f.ClusterName) &&
+		(f.JobGroupID == "" || resource.JobGroupID == f.JobGroupID) &&
+		(f.ResourceID == "" || resource.ID == f.ResourceID) &&
+		(f.ResourceName == "" || resource.ResourceName == f.ResourceName)
+}
+
+// jobState maps the computed state of a resource to the state of a job, as reported to the Job Manager.
+func jobState(state ResourceState) JobState {
+	switch state {
+	case ResourceDegraded:
+		return Degraded
+	case ResourceAvailable:
+		return Available
+	case ResourceApplied:
+		return Applied
+	default:
+		return Progressing
+	}
+}
+
+// WatchResources streams the condition changes of the resources matching the filter until ctx is done,
+// starting with their current conditions. Every stream is served from the ManifestWork cache shared by
+// resourceEvents, and only fails if the cache cannot be filled.
+func WatchResources(ctx context.Context, filter EventFilter) (<-chan ResourceEvent, error) {
+	watcher := &resourceWatcher{
+		filter: filter,
+		queue:  workqueue.New(),
+		sent:   map[string]Resource{},
+		events: make(chan ResourceEvent),
+	}
+	if err := resourceEvents.subscribe(ctx, watcher); err != nil {
+		watcher.queue.ShutDown()
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		watcher.queue.ShutDown()
+	}()
+	go watcher.run(ctx)
+	return watcher.events, nil
+}
+
+// resourceEvents shares one ManifestWork informer between the event streams, started with the first
+// stream and stopped with the last.
+var resourceEvents = &resourceEventHub{subscribers: map[*resourceWatcher]bool{}}
+
+type resourceEventHub struct {
+	mu          sync.Mutex
+	informer    cache.SharedIndexInformer
+	lister      worklisters.ManifestWorkLister
+	stop        chan struct{}
+	subscribers map[*resourceWatcher]bool
+}
+
+// subscribe queues the current resources of the watcher's cluster once the cache is filled, and the
+// resources of every ManifestWork change from then on.
+func (h *resourceEventHub) subscribe(ctx context.Context, w *resourceWatcher) error {
+	h.mu.Lock()
+	if h.informer == nil {
+		factory := workinformers.NewSharedInformerFactory(clientsetWorkOper, 0)
+		h.informer = factory.Work().V1().ManifestWorks().Informer()
+		h.lister = factory.Work().V1().ManifestWorks().Lister()
+		_, err := h.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    h.notify,
+			UpdateFunc: func(_, obj interface{}) { h.notify(obj) },
+			DeleteFunc: h.notify,
+		})
+		if err != nil {
+			h.informer, h.lister = nil, nil
+			h.mu.Unlock()
+			return fmt.Errorf("error watching ManifestWorks: %v", err)
+		}
+		h.stop = make(chan struct{})
+		go h.informer.Run(h.stop)
+	}
+	h.subscribers[w] = true
+	w.lister = h.lister
+	informer := h.informer
+	h.mu.Unlock()
+
+	syncCtx, cancel := context.WithTimeout(ctx, eventCacheSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		h.unsubscribe(w)
+		return fmt.Errorf("error listing ManifestWorks: cache not synced within %s", eventCacheSyncTimeout)
+	}
+	works, err := w.lister.ManifestWorks(w.filter.ClusterName).List(labels.Everything())
+	if err != nil {
+		h.unsubscribe(w)
+		return fmt.Errorf("error listing ManifestWorks: %v", err)
+	}
+	for _, work := range works {
+		w.queue.Add(resourceKey(work))
+	}
+	return nil
+}
+
+func (h *resourceEventHub) unsubscribe(w *resourceWatcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, w)
+	if len(h.subscribers) == 0 && h.informer != nil {
+		close(h.stop)
+		h.informer, h.lister, h.stop = nil, nil, nil
+	}
+}
+
+// notify queues the resource of a changed ManifestWork on the streams of its cluster. Streams look the
+// resource up in the cache when they get to it, so a slow stream never holds up the others.
+func (h *resourceEventHub) notify(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	work, ok := obj.(*workv1.ManifestWork)
+	if !ok {
+		return
+	}
+	key := resourceKey(work)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.subscribers {
+		if w.filter.ClusterName == "" || w.filter.ClusterName == work.Namespace {
+			w.queue.Add(key)
+		}
+	}
+}
+
+// resourceKey identifies the resource a ManifestWork belongs to: changes of a part are reported on its
+// primary ManifestWork.
+func resourceKey(work *workv1.ManifestWork) string {
+	if primaryName, isPart := work.Labels[PartOfLabel]; isPart {
+		return workKey(work.Namespace, primaryName)
+	}
+	return workKey(work.Namespace, work.Name)
+}
+
+type resourceWatcher struct {
+	filter EventFilter
+	lister worklisters.ManifestWorkLister
+	// keys of the resources to look up, each queued once however often it changes
+	queue *workqueue.Type
+	// resource last sent for each key
+	sent   map[string]Resource
+	events chan ResourceEvent
+}
+
+func (w *resourceWatcher) run(ctx context.Context) {
+	defer close(w.events)
+	defer resourceEvents.unsubscribe(w)
+	for {
+		item, shutdown := w.queue.Get()
+		if shutdown {
+			return
+		}
+		event, changed := w.resourceEvent(item.(string))
+		w.queue.Done(item)
+		if !changed {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case w.events <- event:
+		}
+	}
+}
+
+// resourceEvent builds the event of a resource from the cache, and reports whether its conditions
+// changed since it was last sent.
+func (w *resourceWatcher) resourceEvent(key string) (ResourceEvent, bool) {
+	cluster, name, _ := strings.Cut(key, "/")
+	primary, err := w.lister.ManifestWorks(cluster).Get(name)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logs.Logger.Printf("Error obtaining ManifestWork %s: %v", key, err)
+			return ResourceEvent{}, false
+		}
+		last, known := w.sent[key]
+		if !known {
+			return ResourceEvent{}, false
+		}
+		delete(w.sent, key)
+		return ResourceEvent{Type: ResourceDeleted, Resource: last, State: jobState(last.State), Timestamp: time.Now()}, true
+	}
+
+	resourceID, jobID, managed := attributeManifestWork(primary)
+	if !managed {
+		return ResourceEvent{}, false
+	}
+	parts, err := w.lister.ManifestWorks(cluster).List(labels.SelectorFromSet(labels.Set{PartOfLabel: name}))
+	if err != nil {
+		logs.Logger.Println("Error obtaining ManifestWork parts status:", err)
+	}
+	sort.Slice(parts, func(a, b int) bool {
+		return partIndex(parts[a]) < partIndex(parts[b])
+	})
+	// objects of the cache are shared and must not be modified
+	primary = primary.DeepCopy()
+	partWorks := make([]workv1.ManifestWork, 0, len(parts))
+	for _, part := range parts {
+		partWorks = append(partWorks, *part)
+	}
+	aggregateManifestWorkStatus(primary, partWorks)
+	resource := workResource(primary, resourceID, jobID)
+	for _, part := range parts {
+		resource.ResourceParts = append(resource.ResourceParts, part.Name)
+	}
+	if !w.filter.matches(&resource) {
+		return ResourceEvent{}, false
+	}
+
+	eventType := ResourceAdded
+	if last, known := w.sent[key]; known {
+		if conditionsFingerprint(last.Conditions) == conditionsFingerprint(resource.Conditions) {
+			return ResourceEvent{}, false
+		}
+		eventType = ResourceModified
+	}
+	w.sent[key] = resource
+	return ResourceEvent{Type: eventType, Resource: resource, State: jobState(resource.State), Timestamp: time.Now()}, true
+}
+
+// conditionsFingerprint identifies a set of conditions regardless of their transition times.
+func conditionsFingerprint(conditions []metav1.Condition) string {
+	var fingerprint strings.Builder
+	for _, condition := range conditions {
+		fmt.Fprintf(&fingerprint, "%s=%s/%s/%s/%d;", condition.Type, condition.Status, condition.Reason, condition.Message, condition.ObservedGeneration)
+	}
+	return fingerprint.String()
+}
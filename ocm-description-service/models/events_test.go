@@ -0,0 +1,139 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestWatchResources(t *testing.T) {
+	workClient := newFakeWorkClient()
+	watching := make(chan struct{}, 1)
+	var watches atomic.Int32
+	workClient.PrependWatchReactor("manifestworks", func(action clienttesting.Action) (bool, watch.Interface, error) {
+		watches.Add(1)
+		select {
+		case watching <- struct{}{}:
+		default:
+		}
+		return false, nil, nil
+	})
+	useFakeClients(t, workClient, clusterfake.NewSimpleClientset(&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}))
+	t.Setenv("MANIFEST_VALIDATION", "false")
+
+	j := MockCreateDeploymentJob()
+	created, err := createManifestWork(&j)
+	assert.NoError(t, err)
+	other := MockCreateDeploymentJob()
+	other.JobGroupID = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	other.Resource.ResourceName = "other"
+	_, err = createManifestWork(&other)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := WatchResources(ctx, EventFilter{JobGroupID: j.JobGroupID})
+	assert.NoError(t, err)
+	next := func() ResourceEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no resource event received")
+			return ResourceEvent{}
+		}
+	}
+	setConditions := func(conditions ...metav1.Condition) {
+		work, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		work.Status.Conditions = conditions
+		_, err = workClient.WorkV1().ManifestWorks("cluster1").UpdateStatus(context.TODO(), work, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+	}
+
+	t.Run("should start with the current state of the matching resources", func(t *testing.T) {
+		event := next()
+		assert.Equal(t, ResourceAdded, event.Type)
+		assert.Equal(t, created.Name, event.Resource.ResourceName)
+		assert.Equal(t, j.Resource.ID, event.Resource.ID)
+		assert.Equal(t, Progressing, event.State)
+	})
+
+	t.Run("should stream condition changes only", func(t *testing.T) {
+		<-watching
+		setConditions(metav1.Condition{Type: workv1.WorkAvailable, Status: metav1.ConditionTrue, Reason: "ResourcesAvailable"})
+		event := next()
+		assert.Equal(t, ResourceModified, event.Type)
+		assert.Equal(t, Available, event.State)
+		assert.Equal(t, "ResourcesAvailable", event.Resource.Conditions[0].Reason)
+
+		// a spec change leaves the conditions as they are
+		work, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		work.Annotations = map[string]string{"example.com/touched": "true"}
+		_, err = workClient.WorkV1().ManifestWorks("cluster1").Update(context.TODO(), work, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+
+		setConditions(metav1.Condition{Type: workv1.WorkDegraded, Status: metav1.ConditionTrue, Reason: "ResourceNotAvailable"})
+		event = next()
+		assert.Equal(t, ResourceModified, event.Type)
+		assert.Equal(t, Degraded, event.State)
+	})
+
+	t.Run("should stream deletions", func(t *testing.T) {
+		assert.NoError(t, workClient.WorkV1().ManifestWorks("cluster1").Delete(context.TODO(), created.Name, metav1.DeleteOptions{}))
+		event := next()
+		assert.Equal(t, ResourceDeleted, event.Type)
+		assert.Equal(t, created.Name, event.Resource.ResourceName)
+	})
+
+	t.Run("should share one ManifestWork watch between streams", func(t *testing.T) {
+		others, err := WatchResources(ctx, EventFilter{ClusterName: "cluster1"})
+		assert.NoError(t, err)
+		select {
+		case event := <-others:
+			assert.Equal(t, ResourceAdded, event.Type)
+			assert.Equal(t, other.JobGroupID, event.Resource.JobGroupID)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no resource event received")
+		}
+		assert.Equal(t, int32(1), watches.Load())
+	})
+
+	t.Run("should close the stream once cancelled", func(t *testing.T) {
+		cancel()
+		for range events {
+		}
+	})
+}
@@ -0,0 +1,94 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"icos/server/ocm-description-service/utils/logs"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// HelmChart is a packaged Helm chart carried by a job instead of pre-rendered manifests.
+type HelmChart struct {
+	// Archive is the base64 encoded chart package (.tgz)
+	Archive     string `json:"archive"`
+	Values      string `json:"values,omitempty"`
+	ReleaseName string `json:"release_name,omitempty"`
+}
+
+// renderHelmChart renders the job's chart and decodes the resulting manifests into runtime objects.
+func renderHelmChart(j *Job) ([]runtime.Object, error) {
+	manifest, err := renderHelmManifest(j)
+	if err != nil {
+		return nil, err
+	}
+	return decodeYAMLDocuments(manifest)
+}
+
+// renderHelmManifest renders the job's chart offline, without contacting any cluster,
+// and returns the rendered multi-document manifest.
+func renderHelmManifest(j *Job) (string, error) {
+	archive, err := base64.StdEncoding.DecodeString(j.Chart.Archive)
+	if err != nil {
+		return "", fmt.Errorf("error decoding chart archive: %v", err)
+	}
+
+	chart, err := loader.LoadArchive(bytes.NewReader(archive))
+	if err != nil {
+		return "", fmt.Errorf("error loading chart archive: %v", err)
+	}
+
+	valuesYaml, err := renderTemplate(j, "chart-values", j.Chart.Values)
+	if err != nil {
+		return "", fmt.Errorf("error rendering chart values: %v", err)
+	}
+	values, err := chartutil.ReadValues([]byte(valuesYaml))
+	if err != nil {
+		return "", fmt.Errorf("error parsing chart values: %v", err)
+	}
+
+	releaseName := j.Chart.ReleaseName
+	if releaseName == "" {
+		releaseName = j.Resource.ResourceName
+	}
+
+	install := action.NewInstall(&action.Configuration{})
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.IncludeCRDs = true
+	install.ReleaseName = releaseName
+	install.Namespace = j.Namespace
+
+	release, err := install.Run(chart, values)
+	if err != nil {
+		return "", fmt.Errorf("error rendering chart %s: %v", chart.Name(), err)
+	}
+	logs.Logger.Printf("Rendered chart %s-%s as release %s", chart.Name(), chart.Metadata.Version, releaseName)
+
+	return release.Manifest, nil
+}
@@ -0,0 +1,79 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRenderHelmChart(t *testing.T) {
+	t.Run("should render a packaged chart into the manifest work", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Manifests = nil
+		j.Chart = &HelmChart{Archive: mockChartArchive(t), Values: "replicas: 3", ReleaseName: "demo"}
+
+		manifestWork, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
+		// namespace manifest + rendered configmap
+		assert.Len(t, manifestWork.Spec.Workload.Manifests, 2)
+		configMap, ok := manifestWork.Spec.Workload.Manifests[1].Object.(*corev1.ConfigMap)
+		assert.True(t, ok)
+		assert.Equal(t, "demo-config", configMap.Name)
+		assert.Equal(t, j.Namespace, configMap.Namespace)
+		assert.Equal(t, "3", configMap.Data["replicas"])
+	})
+
+	t.Run("should fail on a broken archive", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Chart = &HelmChart{Archive: "bm90LWEtY2hhcnQ="}
+
+		_, err := GenerateManifestWork(&j)
+		assert.ErrorContains(t, err, "error loading chart archive")
+	})
+}
+
+func mockChartArchive(t *testing.T) string {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "demo", Version: "0.1.0"},
+		Values:   map[string]interface{}{"replicas": 1},
+		Templates: []*chart.File{{
+			Name: "templates/configmap.yaml",
+			Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}-config\ndata:\n  replicas: {{ .Values.replicas | quote }}\n"),
+		}},
+	}
+	path, err := chartutil.Save(c, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(archive)
+}
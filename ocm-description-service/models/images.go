@@ -0,0 +1,189 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/distribution/reference"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	yamlEncode "sigs.k8s.io/yaml"
+)
+
+// ImageRewriteRule rewrites container images to pull them from a registry mirror. Either Registry
+// or Prefix is set: Registry replaces the registry of matching images with Replacement, Prefix
+// replaces the matching prefix of the fully qualified image (e.g. docker.io/library/nginx:1.25).
+type ImageRewriteRule struct {
+	Registry    string `json:"registry,omitempty"`
+	Prefix      string `json:"prefix,omitempty"`
+	Replacement string `json:"replacement"`
+}
+
+// ImageRewrite records an image rewritten in the job's workloads.
+type ImageRewrite struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Container string `json:"container"`
+	Original  string `json:"original"`
+	Rewritten string `json:"rewritten"`
+}
+
+var (
+	imageRewriteRules, imageRewriteRulesErr = loadImageRewriteRules(os.Getenv("IMAGE_REWRITE_RULES"))
+	// images must be pinned to a digest, after rewriting, when REQUIRE_IMAGE_DIGEST is true
+	requireImageDigest = os.Getenv("REQUIRE_IMAGE_DIGEST") == "true"
+)
+
+func loadImageRewriteRules(config string) ([]ImageRewriteRule, error) {
+	rules := []ImageRewriteRule{}
+	if config == "" {
+		return rules, nil
+	}
+	if err := yamlEncode.UnmarshalStrict([]byte(config), &rules); err != nil {
+		return nil, fmt.Errorf("error parsing IMAGE_REWRITE_RULES: %v", err)
+	}
+	for i, rule := range rules {
+		if (rule.Registry == "") == (rule.Prefix == "") || rule.Replacement == "" {
+			return nil, fmt.Errorf("error parsing IMAGE_REWRITE_RULES: rule %d needs a replacement and either a registry or a prefix", i)
+		}
+	}
+	return rules, nil
+}
+
+// workloadPodSpec returns the pod spec of the workload kinds the deploy manager manages, or nil.
+func workloadPodSpec(obj runtime.Object) *corev1.PodSpec {
+	switch workload := obj.(type) {
+	case *appsv1.Deployment:
+		return &workload.Spec.Template.Spec
+	case *appsv1.StatefulSet:
+		return &workload.Spec.Template.Spec
+	case *appsv1.DaemonSet:
+		return &workload.Spec.Template.Spec
+	case *batchv1.Job:
+		return &workload.Spec.Template.Spec
+	case *batchv1.CronJob:
+		return &workload.Spec.JobTemplate.Spec.Template.Spec
+	}
+	return nil
+}
+
+// rewriteImages applies the image rewrite rules to every container and init container of the
+// workloads, records the rewrites in the job and, when required, checks that images are pinned.
+func rewriteImages(j *Job, objs []runtime.Object) error {
+	if imageRewriteRulesErr != nil {
+		return imageRewriteRulesErr
+	}
+	j.ImageRewrites = nil
+
+	var errs []error
+	for i, obj := range objs {
+		podSpec := workloadPodSpec(obj)
+		if podSpec == nil {
+			continue
+		}
+		kind := obj.GetObjectKind().GroupVersionKind().Kind
+		name := ""
+		if metaObj, err := meta.Accessor(obj); err == nil {
+			name = metaObj.GetName()
+		}
+
+		containers := []*corev1.Container{}
+		for c := range podSpec.InitContainers {
+			containers = append(containers, &podSpec.InitContainers[c])
+		}
+		for c := range podSpec.Containers {
+			containers = append(containers, &podSpec.Containers[c])
+		}
+
+		for _, container := range containers {
+			rewritten, err := placeImage(container.Image)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("object %d (%s/%s): container %s: %v", i, kind, name, container.Name, err))
+				continue
+			}
+			if rewritten == container.Image {
+				continue
+			}
+			j.ImageRewrites = append(j.ImageRewrites, ImageRewrite{
+				Kind:      kind,
+				Name:      name,
+				Container: container.Name,
+				Original:  container.Image,
+				Rewritten: rewritten,
+			})
+			container.Image = rewritten
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// placeImage applies the image rewrite rules to an image and, when required, checks that the result
+// is pinned to a digest.
+func placeImage(image string) (string, error) {
+	if imageRewriteRulesErr != nil {
+		return "", imageRewriteRulesErr
+	}
+	rewritten, err := rewriteImage(image, imageRewriteRules)
+	if err == nil && requireImageDigest {
+		err = checkImageDigest(rewritten)
+	}
+	return rewritten, err
+}
+
+// rewriteImage applies the first matching rule to an image, returning it unchanged when none matches.
+func rewriteImage(image string, rules []ImageRewriteRule) (string, error) {
+	if len(rules) == 0 {
+		return image, nil
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image %q: %v", image, err)
+	}
+	qualified := named.String()
+
+	for _, rule := range rules {
+		switch {
+		case rule.Registry != "" && reference.Domain(named) == rule.Registry:
+			return rule.Replacement + strings.TrimPrefix(qualified, rule.Registry), nil
+		case rule.Prefix != "" && strings.HasPrefix(qualified, rule.Prefix):
+			return rule.Replacement + strings.TrimPrefix(qualified, rule.Prefix), nil
+		}
+	}
+	return image, nil
+}
+
+func checkImageDigest(image string) error {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return fmt.Errorf("invalid image %q: %v", image, err)
+	}
+	if _, ok := named.(reference.Digested); !ok {
+		return fmt.Errorf("image %s is not pinned to a digest", image)
+	}
+	return nil
+}
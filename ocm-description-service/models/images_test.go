@@ -0,0 +1,103 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+func TestRewriteImages(t *testing.T) {
+	restoreAfterTest(t, &imageRewriteRules)
+	restoreAfterTest(t, &imageRewriteRulesErr)
+	restoreAfterTest(t, &requireImageDigest)
+	cronJobYaml := `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  schedule: "0 * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: OnFailure
+          initContainers:
+          - name: wait
+            image: ghcr.io/icos/wait@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef
+          containers:
+          - name: backup
+            image: quay.io/icos/backup:2.0`
+
+	t.Run("should rewrite images to the configured mirrors and record it", func(t *testing.T) {
+		imageRewriteRules, imageRewriteRulesErr = loadImageRewriteRules(`
+- registry: docker.io
+  replacement: mirror.edge:5000
+- prefix: ghcr.io/icos/
+  replacement: mirror.edge:5000/icos/`)
+		assert.NoError(t, imageRewriteRulesErr)
+		requireImageDigest = false
+
+		j := MockCreateDeploymentJob()
+		j.Manifests = append(j.Manifests, PlainManifest{YamlString: cronJobYaml})
+		manifests, err := buildJobManifests(&j)
+		assert.NoError(t, err)
+		deployment := manifests[0].Object.(*appsv1.Deployment)
+		assert.Equal(t, "mirror.edge:5000/library/nginx:1.25", deployment.Spec.Template.Spec.Containers[0].Image)
+		cronJob := manifests[2].Object.(*batchv1.CronJob)
+		podSpec := cronJob.Spec.JobTemplate.Spec.Template.Spec
+		assert.Equal(t, "mirror.edge:5000/icos/wait@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", podSpec.InitContainers[0].Image)
+		assert.Equal(t, "quay.io/icos/backup:2.0", podSpec.Containers[0].Image, "images matching no rule are left alone")
+
+		assert.Equal(t, []ImageRewrite{
+			{Kind: "Deployment", Name: "nginx", Container: "nginx", Original: "nginx:1.25", Rewritten: "mirror.edge:5000/library/nginx:1.25"},
+			{Kind: "CronJob", Name: "backup", Container: "wait", Original: "ghcr.io/icos/wait@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", Rewritten: "mirror.edge:5000/icos/wait@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		}, j.ImageRewrites)
+
+		// building the manifests again does not duplicate the record
+		_, err = buildJobManifests(&j)
+		assert.NoError(t, err)
+		assert.Len(t, j.ImageRewrites, 2)
+	})
+
+	t.Run("should require digest pinned images when configured", func(t *testing.T) {
+		imageRewriteRules, imageRewriteRulesErr = loadImageRewriteRules("")
+		requireImageDigest = true
+
+		j := MockCreateDeploymentJob()
+		j.Manifests = append(j.Manifests, PlainManifest{YamlString: cronJobYaml})
+		_, err := buildJobManifests(&j)
+		assert.ErrorContains(t, err, "object 0 (Deployment/nginx): container nginx: image nginx:1.25 is not pinned to a digest")
+		assert.ErrorContains(t, err, "container backup: image quay.io/icos/backup:2.0 is not pinned to a digest")
+		assert.NotContains(t, err.Error(), "container wait")
+	})
+
+	t.Run("should reject invalid rules", func(t *testing.T) {
+		_, err := loadImageRewriteRules(`[{registry: docker.io, prefix: docker.io/library/, replacement: mirror}]`)
+		assert.ErrorContains(t, err, "rule 0")
+		_, err = loadImageRewriteRules(`[{registry: docker.io}]`)
+		assert.ErrorContains(t, err, "rule 0")
+	})
+}
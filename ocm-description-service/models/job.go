@@ -21,28 +21,31 @@
 package models
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"icos/server/ocm-description-service/utils/logs"
+	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/google/uuid"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
@@ -56,7 +59,7 @@ import (
 
 var (
 	jobmanagerBaseURL    = os.Getenv("JOBMANAGER_URL")
-	clientset            *kubernetes.Clientset
+	clientset            kubernetes.Interface
 	clientsetWorkOper    workclient.Interface
 	clientsetClusterOper clusterclient.Interface
 	clientOperator       *clustermanager.OperatorV1Client
@@ -99,9 +102,22 @@ type Job struct {
 	Manifests           []PlainManifest `json:"manifests"`
 	Target              Target          `json:"targets,omitempty"`
 	//Locker              *bool            `json:"locker,omitempty"`
-	Orchestrator OrchestratorType `json:"orchestrator"`
-	Resource     *Resource        `json:"resource,omitempty"`
-	Namespace    string           `json:"namespace,omitempty"`
+	Orchestrator  OrchestratorType  `json:"orchestrator"`
+	Resource      *Resource         `json:"resource,omitempty"`
+	Namespace     string            `json:"namespace,omitempty"`
+	Chart         *HelmChart        `json:"chart,omitempty"`
+	Kustomization *KustomizeOverlay `json:"kustomization,omitempty"`
+	// Templated enables Go templates in the manifests and chart values, see TemplateData
+	Templated  bool              `json:"templated,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// ImageRewrites are the images rewritten to registry mirrors while building the job's manifests
+	ImageRewrites []ImageRewrite `json:"image_rewrites,omitempty"`
+	// Remediation holds the parameters of UpdateDeployment jobs
+	Remediation *RemediationParams `json:"remediation,omitempty"`
+	// Changes are the fields changed by an UpdateDeployment job
+	Changes []FieldChange `json:"changes,omitempty"`
+
+	clusterContext *ClusterContext
 }
 
 type JobState int
@@ -110,10 +126,18 @@ type ConditionStatus string
 
 type Resource struct {
 	BaseUUID
-	JobID        string             `json:"job_id"`
-	ResourceUUID string             `json:"resource_uuid,omitempty"`
-	ResourceName string             `json:"resource_name,omitempty"`
-	Conditions   []metav1.Condition `json:"conditions,omitempty"`
+	JobID        string `json:"job_id"`
+	ResourceUUID string `json:"resource_uuid,omitempty"`
+	ResourceName string `json:"resource_name,omitempty"`
+	// ResourceParts are the additional ManifestWorks holding manifests that did not fit in the primary one
+	ResourceParts []string           `json:"resource_parts,omitempty"`
+	Conditions    []metav1.Condition `json:"conditions,omitempty"`
+	// ClusterName, AppName, Component, JobGroupID and State are filled in resource listings
+	ClusterName string        `json:"cluster_name,omitempty"`
+	AppName     string        `json:"app_name,omitempty"`
+	Component   string        `json:"component,omitempty"`
+	JobGroupID  string        `json:"job_group_id,omitempty"`
+	State       ResourceState `json:"state,omitempty"`
 }
 
 type PlainManifest struct {
@@ -140,13 +164,18 @@ type (
 
 // Constants for OrchestratorType and RemediationType
 const (
-	OCM          OrchestratorType = "ocm"
-	NUVLA        OrchestratorType = "nuvla"
-	ScaleUp      RemediationType  = "scale-up"
-	ScaleDown    RemediationType  = "scale-down"
-	ScaleOut     RemediationType  = "scale-out"
-	ScaleIn      RemediationType  = "scale-in"
-	Reallocation RemediationType  = "reallocation"
+	OCM                 OrchestratorType = "ocm"
+	NUVLA               OrchestratorType = "nuvla"
+	ScaleUp             RemediationType  = "scale-up"
+	ScaleDown           RemediationType  = "scale-down"
+	ScaleOut            RemediationType  = "scale-out"
+	ScaleIn             RemediationType  = "scale-in"
+	Reallocation        RemediationType  = "reallocation"
+	SecurityRemediation RemediationType  = "security-remediation"
+	AddAutoscaler       RemediationType  = "add-autoscaler"
+	UpdateAutoscaler    RemediationType  = "update-autoscaler"
+	RemoveAutoscaler    RemediationType  = "remove-autoscaler"
+	Rollback            RemediationType  = "rollback"
 )
 
 // Constants for State and JobType
@@ -177,16 +206,18 @@ func InClusterConfig() error {
 	// Outside of the cluster for development
 	if err != nil {
 		//panic(err.Error())
-		var kubeconfig *string
 		logs.Logger.Println("The home folder is: ", homedir.HomeDir())
-		if home := homedir.HomeDir(); home != "" {
-			kubeconfig = flag.String("kubeconfig", "/home/mgallardo/.kube/config", "absolute path to the kubeconfig file")
-		} else {
-			kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
+		// the flag can only be defined once, so reuse it if the clients were configured before
+		if flag.Lookup("kubeconfig") == nil {
+			if home := homedir.HomeDir(); home != "" {
+				flag.String("kubeconfig", "/home/mgallardo/.kube/config", "absolute path to the kubeconfig file")
+			} else {
+				flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
+			}
+			flag.Parse()
 		}
-		flag.Parse()
 		// use the current context in kubeconfig
-		config, err = clientcmd.BuildConfigFromFlags("", *kubeconfig)
+		config, err = clientcmd.BuildConfigFromFlags("", flag.Lookup("kubeconfig").Value.String())
 		if err != nil {
 			panic(err.Error())
 		}
@@ -272,6 +303,12 @@ func createAndApplyManifestWork(j *Job) (*Job, error) {
 			return nil, err
 		}
 
+		parts, err := fetchManifestWorkParts(namespace, mw.Name)
+		if err != nil {
+			logs.Logger.Println("Error obtaining ManifestWork parts status:", err)
+		}
+		aggregateManifestWorkStatus(appliedManifestWork, parts)
+
 		// Update job with new resource details
 		//j.ResourceUID = resUUID
 		j.UpdateJobResource(appliedManifestWork)
@@ -284,6 +321,11 @@ func createAndApplyManifestWork(j *Job) (*Job, error) {
 func replaceDeployment(j *Job) (*Job, error) {
 	logs.Logger.Println("Replacing Work for Job:", j.ID)
 
+	if err := preflightValidation(j); err != nil {
+		logErrorAndSetJobState("Manifest validation failed", j, Degraded)
+		return nil, err
+	}
+
 	// Fetch old deployment
 	oldManifestWork, err := fetchManifestWork(j.Target.ClusterName, j.Resource.ResourceName, nil)
 	if err != nil {
@@ -291,31 +333,32 @@ func replaceDeployment(j *Job) (*Job, error) {
 		return nil, err
 	}
 
-	updatedManifests := []workv1.Manifest{}
-
-	for _, stringManifest := range j.Manifests {
-		obj, err := decodeYAMLToObject(stringManifest.YamlString)
-		if err != nil {
-			logs.Logger.Println("Error unmarshaling manifest:", err)
-			continue
-		}
-		updateNamespaceAndAnnotations(obj, j.Namespace, j.JobGroupName, j.Resource.ResourceName, j.JobGroupID, j.Resource.ID)
-		rawExtension := runtime.RawExtension{Object: obj}
-		manifest := workv1.Manifest{RawExtension: rawExtension}
-		// usar para el replace deployment
-		updatedManifests = append(updatedManifests, manifest)
+	works, err := GenerateManifestWorks(j)
+	if err != nil {
+		logErrorAndSetJobState("Error decoding job manifests", j, Degraded)
+		return nil, err
 	}
 
-	oldManifestWork.Spec.Workload.Manifests = updatedManifests
+	oldManifestWork.Spec.Workload.Manifests = works[0].Spec.Workload.Manifests
+	setOwnershipLabels(oldManifestWork, j)
 
-	updatedManifestWork, err := clientsetWorkOper.WorkV1().ManifestWorks(j.Target.ClusterName).Update(context.TODO(), oldManifestWork, metav1.UpdateOptions{})
+	updatedManifestWork, err := appliedWorks.update(oldManifestWork)
 
 	if err != nil {
 		logErrorAndSetJobState("Error updating ManifestWork", j, Degraded)
 		return nil, err
 	}
 
+	partNames, err := applyManifestWorkParts(j.Target.ClusterName, updatedManifestWork.Name, works[1:])
+	if err != nil {
+		logErrorAndSetJobState("Error updating ManifestWork parts", j, Degraded)
+		return nil, err
+	}
+
+	recordRevision(j, j.Target.ClusterName, updatedManifestWork.Name)
+
 	j.UpdateJobResource(updatedManifestWork)
+	j.Resource.ResourceParts = partNames
 
 	return j, nil
 }
@@ -326,8 +369,12 @@ func updateDeployment(j *Job) (*Job, error) {
 	logs.Logger.Println("Updating work for Job:", j.ID)
 	switch j.SubType {
 
-	case ScaleUp, ScaleDown, ScaleOut, ScaleIn:
+	case ScaleUp, ScaleDown, ScaleOut, ScaleIn, SecurityRemediation:
 		return updateDeploymentAttributes(j)
+	case AddAutoscaler, UpdateAutoscaler, RemoveAutoscaler:
+		return updateAutoscaler(j)
+	case Rollback:
+		return rollbackDeployment(j)
 	case Reallocation:
 		return deleteDeployment(j)
 	default:
@@ -340,12 +387,19 @@ func updateDeployment(j *Job) (*Job, error) {
 func deleteDeployment(j *Job) (*Job, error) {
 	logs.Logger.Println("Deleting deployment for Job:", j.ID)
 
-	err := clientsetWorkOper.WorkV1().ManifestWorks(j.Target.ClusterName).Delete(context.TODO(), j.Resource.ResourceName, metav1.DeleteOptions{})
+	err := appliedWorks.delete(j.Target.ClusterName, j.Resource.ResourceName)
 	if err != nil {
 		logErrorAndSetJobState("Error obtaining applied ManifestWork status", j, Degraded)
 		return nil, err
 	}
 
+	if err := deleteManifestWorkParts(j.Target.ClusterName, j.Resource.ResourceName, 0); err != nil {
+		logErrorAndSetJobState("Error deleting ManifestWork parts", j, Degraded)
+		return nil, err
+	}
+	j.Resource.ResourceParts = nil
+	deleteRevisions(j.Target.ClusterName, j.Resource.ResourceName)
+
 	logs.Logger.Printf("Successfully deleted deployment for Job: %s\n", j.ID)
 	j.State = Applied
 	return j, nil
@@ -355,12 +409,37 @@ func deleteDeployment(j *Job) (*Job, error) {
 // ------------------------------------------------
 // createManifestWork creates a manifest work for the given job in the specified cluster.
 func createManifestWork(j *Job) (*workv1.ManifestWork, error) {
-	manifestWork := GenerateManifestWork(j)
-	createdManifestWork, err := clientsetWorkOper.WorkV1().ManifestWorks(j.Target.ClusterName).Create(context.TODO(), manifestWork, metav1.CreateOptions{})
+	if err := preflightValidation(j); err != nil {
+		logErrorAndSetJobState("Manifest validation failed", j, Degraded)
+		return nil, err
+	}
+
+	works, err := GenerateManifestWorks(j)
+	if err != nil {
+		logErrorAndSetJobState("Error generating ManifestWork", j, Degraded)
+		return nil, err
+	}
+	createdManifestWork, err := appliedWorks.create(works[0])
 	if err != nil {
 		logErrorAndSetJobState("Error creating ManifestWork", j, Degraded)
 		return nil, fmt.Errorf("error creating ManifestWork: %v", err)
 	}
+
+	partNames, err := applyManifestWorkParts(j.Target.ClusterName, createdManifestWork.Name, works[1:])
+	if err != nil {
+		// do not leave a partial deployment behind
+		if cleanupErr := appliedWorks.delete(j.Target.ClusterName, createdManifestWork.Name); cleanupErr != nil {
+			logs.Logger.Println("Error deleting ManifestWork:", cleanupErr)
+		}
+		if cleanupErr := deleteManifestWorkParts(j.Target.ClusterName, createdManifestWork.Name, 0); cleanupErr != nil {
+			logs.Logger.Println("Error deleting ManifestWork parts:", cleanupErr)
+		}
+		logErrorAndSetJobState("Error creating ManifestWork parts", j, Degraded)
+		return nil, err
+	}
+	j.Resource.ResourceParts = partNames
+	recordRevision(j, j.Target.ClusterName, createdManifestWork.Name)
+
 	return createdManifestWork, nil
 }
 
@@ -402,7 +481,7 @@ func waitForAppliedManifestWork(namespace, name string, ctx context.Context) (*w
 }
 
 // GenerateManifestWork generates a manifest work object for the given job.
-func GenerateManifestWork(j *Job) *workv1.ManifestWork {
+func GenerateManifestWork(j *Job) (*workv1.ManifestWork, error) {
 	work := workv1.ManifestWork{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ManifestWork",
@@ -411,106 +490,198 @@ func GenerateManifestWork(j *Job) *workv1.ManifestWork {
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: j.Resource.ResourceName + "-",
 			Namespace:    j.Target.ClusterName,
+			Labels:       ownershipLabels(j),
 		},
 		Spec: workv1.ManifestWorkSpec{
 			Workload: workv1.ManifestsTemplate{},
 		},
 	}
 
-	namespaceManifest, err := generateNamespaceManifest(j.Namespace)
+	namespaceManifests, err := generateNamespaceManifests(j.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	work.Spec.Workload.Manifests = append(work.Spec.Workload.Manifests, namespaceManifests...)
+
+	manifests, err := buildJobManifests(j)
 	if err != nil {
-		logs.Logger.Println("Error generating namespace manifest:", err)
+		return nil, err
 	}
-	work.Spec.Workload.Manifests = append(work.Spec.Workload.Manifests, namespaceManifest)
+	work.Spec.Workload.Manifests = append(work.Spec.Workload.Manifests, manifests...)
+	return &work, nil
+}
 
-	for _, stringManifest := range j.Manifests {
-		obj, err := decodeYAMLToObject(stringManifest.YamlString)
+// buildJobManifests decodes every document of every job manifest, in order, renders the
+// job's Helm chart if any, applies the job's kustomization on top, rewrites the images and
+// wraps the resulting objects as ManifestWork manifests.
+func buildJobManifests(j *Job) ([]workv1.Manifest, error) {
+	objs := []runtime.Object{}
+	var errs []error
+
+	for i, stringManifest := range j.Manifests {
+		yamlString, err := renderTemplate(j, fmt.Sprintf("manifest-%d", i), stringManifest.YamlString)
 		if err != nil {
-			logs.Logger.Println("Error unmarshaling manifest:", err)
+			errs = append(errs, fmt.Errorf("manifest %d: %v", i, err))
 			continue
 		}
-		updateNamespaceAndAnnotations(obj, j.Namespace, j.JobGroupName, j.Resource.ResourceName, j.JobGroupID, j.Resource.ID)
-		rawExtension := runtime.RawExtension{Object: obj}
-		manifest := workv1.Manifest{RawExtension: rawExtension}
-		logs.Logger.Print("------Inside GenerateManifestWork----------")
-		logs.Logger.Print("Manifest Kind: ", manifest.RawExtension.Object.GetObjectKind().GroupVersionKind().Kind)
-		logs.Logger.Print("------Inside GenerateManifestWork----------")
-		// usar para el replace deployment
-		work.Spec.Workload.Manifests = append(work.Spec.Workload.Manifests, manifest)
+		documents, err := decodeYAMLDocuments(yamlString)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("manifest %d: %v", i, err))
+			continue
+		}
+		objs = append(objs, documents...)
 	}
-	return &work
-}
 
-// generateNamespaceManifest generates a namespace manifest for the given namespace.
-func generateNamespaceManifest(namespace string) (workv1.Manifest, error) {
-	yamlTemplate := `apiVersion: v1
-	kind: Namespace
-	metadata:
- 	name: {{ .NamespaceName }}`
+	if j.Chart != nil {
+		rendered, err := renderHelmChart(j)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		objs = append(objs, rendered...)
+	}
 
-	bodyStringTrimmed := strings.ReplaceAll(yamlTemplate, "\t", "")
-	tmpl, err := template.New("namespace").Parse(bodyStringTrimmed)
-	if err != nil {
-		return workv1.Manifest{}, fmt.Errorf("error parsing template: %v", err)
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		logs.Logger.Println("Error unmarshaling manifests:", err)
+		return nil, err
 	}
 
-	namespacePayload := struct {
-		NamespaceName string
-	}{
-		NamespaceName: namespace,
+	if j.Kustomization != nil {
+		var err error
+		objs, err = applyKustomizeOverlay(j.Kustomization, objs)
+		if err != nil {
+			logs.Logger.Println("Error applying kustomization:", err)
+			return nil, err
+		}
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, namespacePayload); err != nil {
-		return workv1.Manifest{}, fmt.Errorf("error executing template: %v", err)
+	if err := rewriteImages(j, objs); err != nil {
+		logs.Logger.Println("Error rewriting images:", err)
+		return nil, err
 	}
 
-	var manifest workv1.Manifest
-	if err := yaml.Unmarshal(buf.Bytes(), &manifest); err != nil {
-		return workv1.Manifest{}, fmt.Errorf("error unmarshaling manifest: %v", err)
+	manifests := make([]workv1.Manifest, 0, len(objs))
+	for i, obj := range objs {
+		if err := updateNamespaceAndAnnotations(obj, j.Namespace, j.JobGroupName, j.Resource.ResourceName, j.JobGroupID, j.Resource.ID); err != nil {
+			errs = append(errs, fmt.Errorf("object %d (%s): %v", i, obj.GetObjectKind().GroupVersionKind().Kind, err))
+			continue
+		}
+		rawExtension := runtime.RawExtension{Object: obj}
+		manifest := workv1.Manifest{RawExtension: rawExtension}
+		logs.Logger.Print("Manifest Kind: ", obj.GetObjectKind().GroupVersionKind().Kind)
+		manifests = append(manifests, manifest)
+	}
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		logs.Logger.Println("Error placing manifests:", err)
+		return nil, err
 	}
-	return manifest, nil
+	return manifests, nil
 }
 
 // UpdateDeploymentAttributes updates the attributes of the manifests for a deployment based on the remediation type.
 func updateDeploymentAttributes(j *Job) (*Job, error) {
+	if err := j.Remediation.validate(); err != nil {
+		logErrorAndSetJobState(fmt.Sprintf("Invalid remediation parameters: %v", err), j, Degraded)
+		return nil, err
+	}
+	j.Changes = nil
 
-	subType := j.SubType
 	manifestWork, err := fetchManifestWork(j.Target.ClusterName, j.Resource.ResourceName, nil)
 	if err != nil {
 		logErrorAndSetJobState("Error obtaining applied ManifestWork status", j, Degraded)
 		return nil, err
 	}
 
-	manifests := manifestWork.Spec.Workload.Manifests
+	parts, err := fetchManifestWorkParts(j.Target.ClusterName, manifestWork.Name)
+	if err != nil {
+		logErrorAndSetJobState("Error obtaining ManifestWork parts", j, Degraded)
+		return nil, err
+	}
 
-	updatedManifests := make([]workv1.Manifest, 0, len(manifests))
+	// the manifests to update may live in any of the additional ManifestWorks as well; all of them
+	// are changed before any is written
+	works := []*workv1.ManifestWork{manifestWork}
+	previous := []*workv1.ManifestWork{manifestWork.DeepCopy()}
+	for i := range parts {
+		works = append(works, &parts[i])
+		previous = append(previous, parts[i].DeepCopy())
+	}
+	for _, work := range works {
+		work.Spec.Workload.Manifests, err = updateManifestsAttributes(work.Spec.Workload.Manifests, j)
+		if err != nil {
+			logErrorAndSetJobState(fmt.Sprintf("Error updating manifests of ManifestWork %s: %v", work.Name, err), j, Degraded)
+			return nil, err
+		}
+	}
+	setOwnershipLabels(manifestWork, j)
 
-	//Since we are creating a new slice of manifests, we need to set up the namespace as well
-	namespaceManifest, err := generateNamespaceManifest(j.Namespace)
+	updated, err := updateManifestWorks(works, previous)
 	if err != nil {
-		logErrorAndSetJobState("Error generating namespace manifest", j, Degraded)
+		logErrorAndSetJobState(fmt.Sprintf("Error updating ManifestWork: %v", err), j, Degraded)
 		return nil, err
 	}
-	updatedManifests = append(updatedManifests, namespaceManifest)
+	updatedManifestWork := updated[0]
+	for i := range parts {
+		parts[i] = *updated[i+1]
+	}
+
+	// ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// defer cancel()
 
-	for _, manifest := range manifests {
+	// appliedManifestWork, err := waitForAppliedManifestWork(updatedManifestWork.Namespace, updatedManifestWork.Name, ctx)
+	// if err != nil {
+	// 	logs.Logger.Println("Error obtaining applied ManifestWork status:", err)
+	// 	j.State = Degraded
+	// 	return nil, err
+	// }
+
+	recordRevision(j, j.Target.ClusterName, updatedManifestWork.Name)
+
+	aggregateManifestWorkStatus(updatedManifestWork, parts)
+	j.UpdateJobResource(updatedManifestWork)
 
+	return j, nil
+}
+
+// updateManifestsAttributes applies the remediation type of the job to every manifest of a ManifestWork,
+// recording the changed fields in the job. Manifests the remediation does not apply to are kept as they are.
+func updateManifestsAttributes(manifests []workv1.Manifest, j *Job) ([]workv1.Manifest, error) {
+	subType := j.SubType
+	updatedManifests := make([]workv1.Manifest, 0, len(manifests))
+
+	objs := make([]runtime.Object, len(manifests))
+	for i, manifest := range manifests {
 		yamlBytes, err := yamlEncode.Marshal(manifest)
 		if err != nil {
 			return nil, fmt.Errorf("error encoding manifest: %v", err)
 		}
-		obj, err := decodeYAMLToObject(string(yamlBytes))
-		if err != nil {
+		if objs[i], err = decodeYAMLToObject(string(yamlBytes)); err != nil {
 			return nil, fmt.Errorf("error decoding manifest: %v", err)
 		}
+	}
+	// the replica count of these Deployments belongs to their HorizontalPodAutoscaler
+	autoscaled := autoscaledDeployments(objs)
+
+	for i, manifest := range manifests {
+		obj := objs[i]
+		if !j.Remediation.targetsComponent(obj) {
+			updatedManifests = append(updatedManifests, manifest)
+			continue
+		}
 
 		var updatedManifest *workv1.Manifest
+		var changes []FieldChange
+		var err error
 		switch subType {
 		case ScaleUp, ScaleDown:
-			updatedManifest, err = updateReplicaCount(obj, subType)
+			if deployment, ok := obj.(*appsv1.Deployment); ok && autoscaled[deployment.Name] {
+				err = fmt.Errorf("deployment %s is scaled by a %s, update the autoscaler instead", deployment.Name, HorizontalPodAutoscalerKind)
+				break
+			}
+			updatedManifest, changes, err = updateReplicaCount(obj, j)
 		case ScaleOut, ScaleIn:
-			updatedManifest, err = updateResourceRequirements(obj, subType)
+			updatedManifest, changes, err = updateResourceRequirements(obj, j)
+		case SecurityRemediation:
+			updatedManifest, changes, err = hardenWorkload(obj, j.Remediation)
 		default:
 			err = fmt.Errorf("unsupported subType: %v", subType)
 		}
@@ -518,46 +689,25 @@ func updateDeploymentAttributes(j *Job) (*Job, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error updating manifest: %v", err)
 		}
+		j.Changes = append(j.Changes, changes...)
 
-		if updatedManifest != nil {
-			updatedManifests = append(updatedManifests, *updatedManifest)
+		if updatedManifest == nil {
+			updatedManifest = &manifest
 		}
+		updatedManifests = append(updatedManifests, *updatedManifest)
 	}
-
-	manifestWork.Spec.Workload.Manifests = updatedManifests
-	logs.Logger.Print("-----BEFORE UPDATE---------------")
-	logs.Logger.Printf("Updated CPU: %v, Memory: %v\n", manifestWork.Spec.Workload.Manifests[1].RawExtension.Object.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU], manifestWork.Spec.Workload.Manifests[1].RawExtension.Object.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceMemory])
-	logs.Logger.Print("--------------------")
-
-	updatedManifestWork, err := clientsetWorkOper.WorkV1().ManifestWorks(j.Target.ClusterName).Update(context.TODO(), manifestWork, metav1.UpdateOptions{})
-
-	if err != nil {
-		logErrorAndSetJobState("Error updating ManifestWork", j, Degraded)
-		return nil, err
-	}
-
-	// ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	// defer cancel()
-
-	// appliedManifestWork, err := waitForAppliedManifestWork(updatedManifestWork.Namespace, updatedManifestWork.Name, ctx)
-	// if err != nil {
-	// 	logs.Logger.Println("Error obtaining applied ManifestWork status:", err)
-	// 	j.State = Degraded
-	// 	return nil, err
-	// }
-
-	j.UpdateJobResource(updatedManifestWork)
-
-	return j, nil
+	return updatedManifests, nil
 }
 
 // updateNamespaceAndAnnotations updates the namespace and annotations of a Manifest Work object.
-func updateNamespaceAndAnnotations(obj runtime.Object, namespace, appName, componentName, instanceID, manifestID string) {
+func updateNamespaceAndAnnotations(obj runtime.Object, namespace, appName, componentName, instanceID, manifestID string) error {
+	if err := setObjectNamespace(obj, namespace); err != nil {
+		return err
+	}
 	metaObj, err := meta.Accessor(obj)
 	if err != nil {
-		logs.Logger.Fatalf("Failed to get metadata accessor: %v", err)
+		return fmt.Errorf("error accessing object metadata: %v", err)
 	}
-	metaObj.SetNamespace(namespace)
 
 	annotations := metaObj.GetAnnotations()
 	if annotations == nil {
@@ -565,44 +715,91 @@ func updateNamespaceAndAnnotations(obj runtime.Object, namespace, appName, compo
 	}
 	annotations["app.icos.eu/name"] = appName
 	annotations["app.icos.eu/component"] = componentName
-	annotations["app.icos.eu/instance"] = instanceID
-	annotations["jobmanager.icos.eu/manifest"] = manifestID
+	annotations[AppInstanceAnnotation] = instanceID
+	annotations[ManifestAnnotation] = manifestID
 	metaObj.SetAnnotations(annotations)
+	return nil
 }
 
 // Deployment Attribute Updates
 // ------------------------------------------------
 
-// updateReplicaCount updates the replica count of the deployment based on the remediation type.
-func updateReplicaCount(obj runtime.Object, subType RemediationType) (*workv1.Manifest, error) {
-	deployment, ok := obj.(*appsv1.Deployment)
-	if !ok {
-		return nil, nil
+// updateReplicaCount updates the replica count of a scalable object based on the remediation type, or
+// to the replica count of the job, within the replica bounds of the object, recording the change.
+func updateReplicaCount(obj runtime.Object, j *Job) (*workv1.Manifest, []FieldChange, error) {
+	if scalableKindsErr != nil {
+		return nil, nil, scalableKindsErr
+	}
+	replicaNumber, field, ok, err := getReplicas(obj)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+	recorder, err := newChangeRecorder(obj)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	replicaNumber := *deployment.Spec.Replicas
-	horizontalPodAutoscaling(subType, &replicaNumber)
-	deployment.Spec.Replicas = &replicaNumber
+	bounds, err := workloadReplicaBounds(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	previous := replicaNumber
+	if j.Remediation != nil && j.Remediation.Replicas != nil {
+		replicaNumber = *j.Remediation.Replicas
+		// the target must agree with the direction of the job
+		if (j.SubType == ScaleUp && replicaNumber < previous) || (j.SubType == ScaleDown && replicaNumber > previous) {
+			return nil, nil, fmt.Errorf("%s cannot change %s of %s %s from %d to %d",
+				j.SubType, field, recorder.kind, recorder.name, previous, replicaNumber)
+		}
+	} else {
+		horizontalPodAutoscaling(j.SubType, &replicaNumber)
+	}
+	replicaNumber = bounds.clamp(replicaNumber)
+	if err := setReplicas(obj, replicaNumber); err != nil {
+		return nil, nil, err
+	}
+	recorder.record(field, strconv.Itoa(int(previous)), strconv.Itoa(int(replicaNumber)))
 
 	rawExtension := runtime.RawExtension{Object: obj}
-	return &workv1.Manifest{RawExtension: rawExtension}, nil
+	return &workv1.Manifest{RawExtension: rawExtension}, recorder.changes, nil
 }
 
-// updateResourceRequirements updates the resource requirements of the deployment based on the remediation type.
-func updateResourceRequirements(obj runtime.Object, subType RemediationType) (*workv1.Manifest, error) {
-	deployment, ok := obj.(*appsv1.Deployment)
-	if !ok {
-		return nil, nil
+// updateResourceRequirements updates the resource requirements of the targeted containers of a workload
+// based on the remediation type, recording the changed requests and limits.
+func updateResourceRequirements(obj runtime.Object, j *Job) (*workv1.Manifest, []FieldChange, error) {
+	podSpec := workloadPodSpec(obj)
+	if podSpec == nil {
+		return nil, nil, nil
+	}
+	if verticalScalingErr != nil {
+		return nil, nil, verticalScalingErr
 	}
+	recorder, err := newChangeRecorder(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	settings := verticalScaling.forJob(j.Remediation)
 
-	resources := deployment.Spec.Template.Spec.Containers[0].Resources
-	logs.Logger.Print("------Inside updateResourceRequirements----------")
-	logs.Logger.Printf("Current CPU: %v, Memory: %v\n", resources.Requests[corev1.ResourceCPU], resources.Requests[corev1.ResourceMemory])
-	verticalPodAutoscaling(subType, &resources)
-	deployment.Spec.Template.Spec.Containers[0].Resources = resources
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if !j.Remediation.targetsContainer(container.Name) {
+			continue
+		}
+		previous := container.Resources.DeepCopy()
+		if err := verticalPodAutoscaling(j.SubType, &container.Resources, settings); err != nil {
+			return nil, nil, fmt.Errorf("container %s: %v", container.Name, err)
+		}
+
+		prefix := fmt.Sprintf("containers[%s].resources", container.Name)
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			recorder.record(fmt.Sprintf("%s.requests.%s", prefix, name), quantityValue(previous.Requests, name), quantityValue(container.Resources.Requests, name))
+			recorder.record(fmt.Sprintf("%s.limits.%s", prefix, name), quantityValue(previous.Limits, name), quantityValue(container.Resources.Limits, name))
+		}
+	}
 
 	rawExtension := runtime.RawExtension{Object: obj}
-	return &workv1.Manifest{RawExtension: rawExtension}, nil
+	return &workv1.Manifest{RawExtension: rawExtension}, recorder.changes, nil
 }
 
 // horizontalPodAutoscaling adjusts the replica count for scale up or scale down operations.
@@ -615,35 +812,60 @@ func horizontalPodAutoscaling(subType RemediationType, replicas *int32) {
 	}
 }
 
-// verticalPodAutoscaling adjusts the resource requirements for scale out or scale in operations.
-func verticalPodAutoscaling(subType RemediationType, resources *corev1.ResourceRequirements) {
-	// who is responsible for specifying the resource amount?
-	cpuAdjustment := int64(1000)                  // in millicores
-	memoryAdjustment := int64(1000 * 1024 * 1024) // in bytes (1000 MiB)
-	switch subType {
-	case ScaleOut:
-		adjustResource(corev1.ResourceCPU, resources, cpuAdjustment)
-		adjustResource(corev1.ResourceMemory, resources, memoryAdjustment)
-	case ScaleIn:
-		adjustResource(corev1.ResourceCPU, resources, -cpuAdjustment)
-		adjustResource(corev1.ResourceMemory, resources, -memoryAdjustment)
+// verticalPodAutoscaling adjusts the CPU and memory requirements for scale out or scale in operations.
+func verticalPodAutoscaling(subType RemediationType, resources *corev1.ResourceRequirements, settings VerticalScaling) error {
+	if subType != ScaleOut && subType != ScaleIn {
+		return nil
 	}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if err := adjustResource(name, resources, settings, subType == ScaleIn); err != nil {
+			return fmt.Errorf("%s %v", subType, err)
+		}
+	}
+	return nil
 }
 
-// adjustResource adjusts the resource quantity based on the resource name and adjustment value.
-func adjustResource(resourceName corev1.ResourceName, resources *corev1.ResourceRequirements, adjustment int64) {
-	currentQuantity := resources.Requests[resourceName]
-	newQuantity := currentQuantity.DeepCopy()
+// adjustResource adjusts, or sets, the request of a resource within the configured bounds, and shifts its limit,
+// if any, by the same amount without letting it fall below the request.
+func adjustResource(resourceName corev1.ResourceName, resources *corev1.ResourceRequirements, settings VerticalScaling, decrease bool) error {
+	// like the API server, a missing request defaults to the limit
+	currentQuantity, requested := resources.Requests[resourceName]
+	if !requested {
+		currentQuantity, requested = resources.Limits[resourceName]
+	}
+	newQuantity, ok, err := settings.request(resourceName, currentQuantity, requested, decrease)
+	if err != nil || !ok {
+		return err
+	}
+	newQuantity = settings.clamp(resourceName, newQuantity)
+	if newQuantity.Sign() < 0 {
+		return nil
+	}
+
+	delta := newQuantity.DeepCopy()
+	delta.Sub(currentQuantity)
+	if resources.Requests == nil {
+		resources.Requests = corev1.ResourceList{}
+	}
+	resources.Requests[resourceName] = newQuantity
 
-	if resourceName == corev1.ResourceCPU {
-		newQuantity.Add(*resource.NewMilliQuantity(adjustment, resource.DecimalSI))
-	} else if resourceName == corev1.ResourceMemory {
-		newQuantity.Add(*resource.NewQuantity(adjustment, resource.BinarySI))
+	if limit, ok := resources.Limits[resourceName]; ok {
+		newLimit := limit.DeepCopy()
+		newLimit.Add(delta)
+		if newLimit.Cmp(newQuantity) < 0 {
+			newLimit = newQuantity.DeepCopy()
+		}
+		resources.Limits[resourceName] = newLimit
 	}
+	return nil
+}
 
-	if newQuantity.Sign() >= 0 {
-		resources.Requests[resourceName] = newQuantity
+func quantityValue(resources corev1.ResourceList, resourceName corev1.ResourceName) string {
+	quantity, ok := resources[resourceName]
+	if !ok {
+		return unsetValue
 	}
+	return quantity.String()
 }
 
 // Utility Functions
@@ -651,14 +873,17 @@ func adjustResource(resourceName corev1.ResourceName, resources *corev1.Resource
 
 // decodeYAMLToObject decodes a YAML string into a runtime object.
 func decodeYAMLToObject(yamlString string) (runtime.Object, error) {
-	scheme := runtime.NewScheme()
-	appsv1.AddToScheme(scheme)
-	corev1.AddToScheme(scheme)
-
-	codecFactory := serializer.NewCodecFactory(scheme)
-	decoder := codecFactory.UniversalDeserializer()
+	decoder := scheme.Codecs.UniversalDeserializer()
 
 	obj, _, err := decoder.Decode([]byte(yamlString), nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		// custom resources, whose API groups are unknown to the scheme, are kept unstructured
+		custom, customErr := decodeYAMLToUnstructured(yamlString)
+		if customErr != nil || scheme.Scheme.IsVersionRegistered(custom.GetObjectKind().GroupVersionKind().GroupVersion()) {
+			return nil, err
+		}
+		return custom, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -666,6 +891,81 @@ func decodeYAMLToObject(yamlString string) (runtime.Object, error) {
 	return obj, nil
 }
 
+func decodeYAMLToUnstructured(yamlString string) (runtime.Object, error) {
+	jsonBytes, err := yamlEncode.YAMLToJSON([]byte(yamlString))
+	if err != nil {
+		return nil, err
+	}
+	obj, _, err := unstructured.UnstructuredJSONScheme.Decode(jsonBytes, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// decodeYAMLDocuments splits a multi-document YAML string on "---" separators and
+// decodes each non-empty document into a runtime object, keeping the document order.
+// Every document is decoded on its own; failures are reported with the document index.
+func decodeYAMLDocuments(yamlString string) ([]runtime.Object, error) {
+	documents, err := splitYAMLDocuments(yamlString)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := []runtime.Object{}
+	var errs []error
+	for index, document := range documents {
+		if document == nil {
+			continue
+		}
+		obj, err := decodeYAMLToObject(string(document))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("document %d: %v", index, err))
+			continue
+		}
+		objs = append(objs, obj)
+	}
+
+	if len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("no objects found in manifest")
+	}
+	return objs, nil
+}
+
+// splitYAMLDocuments splits a multi-document YAML string on "---" separators.
+// Empty documents are kept as nil entries so that indexes match the original string.
+func splitYAMLDocuments(yamlString string) ([][]byte, error) {
+	reader := yaml.NewYAMLReader(bufio.NewReader(strings.NewReader(yamlString)))
+	documents := [][]byte{}
+
+	for index := 0; ; index++ {
+		document, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %v", index, err)
+		}
+		if isEmptyYAMLDocument(document) {
+			document = nil
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+// isEmptyYAMLDocument reports whether a YAML document holds no object, e.g. only comments.
+func isEmptyYAMLDocument(document []byte) bool {
+	var content map[string]interface{}
+	if err := yamlEncode.Unmarshal(document, &content); err != nil {
+		return false
+	}
+	return len(content) == 0
+}
+
 // logErrorAndSetJobState logs an error message and sets the job state to the specified state.
 func logErrorAndSetJobState(message string, j *Job, state JobState) {
 	logs.Logger.Println(message)
@@ -733,9 +1033,28 @@ func (j *Job) PromoteJob(authHeader string, OwnerID string) error {
 	defer resp.Body.Close()
 
 	logs.Logger.Println("GET Lock Response", resp.Status)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("job %s could not be locked: %s", j.ID, resp.Status)
+	}
 	return nil
 }
 
+// RecordFailure marks the job as degraded and keeps the error message as a resource condition
+// so that Job Manager can show why the job failed.
+func (j *Job) RecordFailure(err error) {
+	j.State = Degraded
+	if j.Resource == nil {
+		j.Resource = &Resource{}
+	}
+	j.Resource.Conditions = append(j.Resource.Conditions, metav1.Condition{
+		Type:               "Degraded",
+		Status:             metav1.ConditionTrue,
+		Reason:             "JobExecutionFailed",
+		Message:            err.Error(),
+		LastTransitionTime: metav1.Now(),
+	})
+}
+
 func (j *Job) StateMapper(state workv1.ManifestWorkStatus) {
 	offset := len(state.Conditions)
 	if offset >= 1 {
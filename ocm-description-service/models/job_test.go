@@ -23,26 +23,53 @@ package models
 import (
 	"context"
 	"icos/server/ocm-description-service/utils/logs"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	clusterclient "open-cluster-management.io/api/client/cluster/clientset/versioned"
+	workclient "open-cluster-management.io/api/client/work/clientset/versioned"
 	workfake "open-cluster-management.io/api/client/work/clientset/versioned/fake"
 	workv1 "open-cluster-management.io/api/work/v1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clienttesting "k8s.io/client-go/testing"
 )
 
 func TestExecuteJob(t *testing.T) {
 	t.Run("should generate a manifest work", func(t *testing.T) {
 		j := MockCreateDeploymentJob()
-		manifestWork := GenerateManifestWork(&j)
+		manifestWork, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
 		assert.NotNil(t, manifestWork)
 	})
 
+	t.Run("should split multi-document manifests", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Manifests = []PlainManifest{{YamlString: "---\n" + mockDeploymentYaml + "\n---\n# comment only\n---\n" + mockServiceYaml}}
+		manifestWork, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
+		// namespace manifest + deployment + service, in document order
+		assert.Len(t, manifestWork.Spec.Workload.Manifests, 3)
+		assert.Equal(t, "Deployment", manifestWork.Spec.Workload.Manifests[1].Object.GetObjectKind().GroupVersionKind().Kind)
+		assert.Equal(t, "Service", manifestWork.Spec.Workload.Manifests[2].Object.GetObjectKind().GroupVersionKind().Kind)
+	})
+
+	t.Run("should report the index of an invalid document", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Manifests = []PlainManifest{{YamlString: mockDeploymentYaml + "\n---\napiVersion: v1\nkind: Unknown\nmetadata:\n  name: broken"}}
+		_, err := GenerateManifestWork(&j)
+		assert.ErrorContains(t, err, "manifest 0: document 1")
+	})
+
 	t.Run("should create a new deployment", func(t *testing.T) {
 		j := MockCreateDeploymentJob()
-		manifestWork := GenerateManifestWork(&j)
+		manifestWork, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
 		jobClient := workfake.NewSimpleClientset()
 
 		namespace := j.Namespace
@@ -67,7 +94,8 @@ func TestExecuteJob(t *testing.T) {
 		for _, tt := range updateTests {
 			t.Run(tt.name, func(t *testing.T) {
 				j := MockUpdateJob(tt.subType)
-				manifestWork := GenerateManifestWork(&j)
+				manifestWork, err := GenerateManifestWork(&j)
+				assert.NoError(t, err)
 				jobClient := workfake.NewSimpleClientset()
 				namespace := j.Namespace
 
@@ -92,6 +120,28 @@ func TestExecuteJob(t *testing.T) {
 	})
 }
 
+func TestPromoteJob(t *testing.T) {
+	status := http.StatusOK
+	jobManager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "/jobmanager/jobs/promote/job-1", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.WriteHeader(status)
+	}))
+	defer jobManager.Close()
+	setForTest(t, &jobmanagerBaseURL, jobManager.URL+"/")
+	job := Job{BaseUUID: BaseUUID{ID: "job-1"}}
+
+	t.Run("should lock the job", func(t *testing.T) {
+		assert.NoError(t, job.PromoteJob("Bearer token", "owner"))
+	})
+
+	t.Run("should fail when Job Manager refuses the lock", func(t *testing.T) {
+		status = http.StatusConflict
+		assert.ErrorContains(t, job.PromoteJob("Bearer token", "owner"), "job job-1 could not be locked: 409 Conflict")
+	})
+}
+
 func MockGetManifestWork(jobClient *workfake.Clientset, namespace, name string) (*workv1.ManifestWork, error) {
 	manifestWork, err := jobClient.WorkV1().ManifestWorks(namespace).Get(context.TODO(), name, metav1.GetOptions{})
 	if err != nil {
@@ -101,6 +151,7 @@ func MockGetManifestWork(jobClient *workfake.Clientset, namespace, name string)
 }
 
 func MockCreateNewDeployment(jobClient *workfake.Clientset, namespace string, manifestWork *workv1.ManifestWork) (*workv1.ManifestWork, error) {
+	manifestWork.Namespace = namespace
 	return jobClient.WorkV1().ManifestWorks(namespace).Create(context.TODO(), manifestWork, metav1.CreateOptions{})
 }
 
@@ -109,11 +160,98 @@ func MockUpdateManifestWork(jobClient *workfake.Clientset, manifestWork *workv1.
 	return err
 }
 
+const mockDeploymentYaml = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: nginx
+  template:
+    metadata:
+      labels:
+        app: nginx
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.25
+        resources:
+          requests:
+            cpu: 500m
+            memory: 256Mi`
+
+const mockServiceYaml = `apiVersion: v1
+kind: Service
+metadata:
+  name: nginx
+spec:
+  selector:
+    app: nginx
+  ports:
+  - port: 80`
+
 func MockUpdateJob(subType RemediationType) Job {
-	panic("not implemented")
+	j := MockCreateDeploymentJob()
+	j.Type = UpdateDeployment
+	j.SubType = subType
+	return j
 }
 
 func MockCreateDeploymentJob() Job {
-	panic("not implemented")
+	return Job{
+		BaseUUID:     BaseUUID{ID: "0b8c1a3e-3a4c-4a53-9d3f-6f0b3f2c1a10"},
+		JobGroupID:   "4f1a2c3e-7b6d-4c2e-8f9a-1d2e3f4a5b6c",
+		JobGroupName: "nginx-app",
+		Type:         CreateDeployment,
+		Manifests:    []PlainManifest{{YamlString: mockDeploymentYaml}, {YamlString: mockServiceYaml}},
+		Target:       Target{ClusterName: "cluster1", NodeName: "cluster1"},
+		Orchestrator: OCM,
+		Resource:     &Resource{BaseUUID: BaseUUID{ID: "9e8d7c6b-5a4f-4e3d-2c1b-0a9f8e7d6c5b"}, ResourceName: "nginx"},
+		Namespace:    "icos-test",
+	}
+}
+
+// newFakeWorkClient returns a fake ManifestWork client that names works created with generateName,
+// which the fake clientset does not honour.
+func newFakeWorkClient(objects ...runtime.Object) *workfake.Clientset {
+	workClient := workfake.NewSimpleClientset(objects...)
+	workClient.PrependReactor("create", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		work := action.(clienttesting.CreateAction).GetObject().(*workv1.ManifestWork)
+		if work.Name == "" {
+			work.Name = work.GenerateName + "abcde"
+		}
+		return false, nil, nil
+	})
+	return workClient
+}
+
+// useFakeClients points the hub clients at the given fakes until the test ends.
+func useFakeClients(t *testing.T, workClient workclient.Interface, clusterClient clusterclient.Interface) {
+	t.Helper()
+	setForTest(t, &clientsetWorkOper, workClient)
+	setForTest(t, &clientsetClusterOper, clusterClient)
+	// the applied works belong to the hub they were written to
+	setForTest(t, &appliedWorks, newAppliedWorkStore())
+}
+
+// useFakeKubeClient points the hub Kubernetes client at the given fake until the test ends.
+func useFakeKubeClient(t *testing.T, kubeClient kubernetes.Interface) {
+	t.Helper()
+	setForTest(t, &clientset, kubeClient)
+}
+
+// setForTest sets a package variable until the test ends.
+func setForTest[T any](t *testing.T, variable *T, value T) {
+	t.Helper()
+	restoreAfterTest(t, variable)
+	*variable = value
+}
 
+// restoreAfterTest restores a package variable to its current value once the test ends.
+func restoreAfterTest[T any](t *testing.T, variable *T) {
+	t.Helper()
+	previous := *variable
+	t.Cleanup(func() { *variable = previous })
 }
@@ -0,0 +1,108 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	yamlEncode "sigs.k8s.io/yaml"
+)
+
+const kustomizeRoot = "/kustomization"
+
+// KustomizeOverlay is a kustomization applied on top of the job's manifests, which act as its base.
+// Patches must be inline, patch files cannot be referenced.
+type KustomizeOverlay struct {
+	NamePrefix   string            `json:"name_prefix,omitempty"`
+	NameSuffix   string            `json:"name_suffix,omitempty"`
+	CommonLabels map[string]string `json:"common_labels,omitempty"`
+	Patches      []types.Patch     `json:"patches,omitempty"`
+	Images       []types.Image     `json:"images,omitempty"`
+}
+
+// applyKustomizeOverlay builds the overlay on top of the base objects and decodes the resulting objects.
+func applyKustomizeOverlay(overlay *KustomizeOverlay, base []runtime.Object) ([]runtime.Object, error) {
+	output, err := buildKustomizeOverlay(overlay, base)
+	if err != nil {
+		return nil, err
+	}
+	return decodeYAMLDocuments(string(output))
+}
+
+// buildKustomizeOverlay writes the base objects and the overlay to an in-memory filesystem
+// and builds the kustomization, returning the resulting multi-document YAML.
+func buildKustomizeOverlay(overlay *KustomizeOverlay, base []runtime.Object) ([]byte, error) {
+	fSys := filesys.MakeFsInMemory()
+
+	kustomization := types.Kustomization{
+		TypeMeta: types.TypeMeta{
+			APIVersion: types.KustomizationVersion,
+			Kind:       types.KustomizationKind,
+		},
+		NamePrefix:   overlay.NamePrefix,
+		NameSuffix:   overlay.NameSuffix,
+		CommonLabels: overlay.CommonLabels,
+		Images:       overlay.Images,
+	}
+
+	for i, patch := range overlay.Patches {
+		if patch.Path != "" {
+			return nil, fmt.Errorf("patch %d: patch files are not supported, provide the patch inline", i)
+		}
+		kustomization.Patches = append(kustomization.Patches, patch)
+	}
+
+	for i, obj := range base {
+		yamlBytes, err := yamlEncode.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding base object %d: %v", i, err)
+		}
+		fileName := fmt.Sprintf("resource-%d.yaml", i)
+		if err := fSys.WriteFile(filepath.Join(kustomizeRoot, fileName), yamlBytes); err != nil {
+			return nil, err
+		}
+		kustomization.Resources = append(kustomization.Resources, fileName)
+	}
+
+	kustomizationBytes, err := yamlEncode.Marshal(kustomization)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding kustomization: %v", err)
+	}
+	if err := fSys.WriteFile(filepath.Join(kustomizeRoot, "kustomization.yaml"), kustomizationBytes); err != nil {
+		return nil, err
+	}
+
+	resMap, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, kustomizeRoot)
+	if err != nil {
+		return nil, fmt.Errorf("error building kustomization: %v", err)
+	}
+
+	output, err := resMap.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding kustomization output: %v", err)
+	}
+	return output, nil
+}
@@ -0,0 +1,69 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+func TestApplyKustomizeOverlay(t *testing.T) {
+	t.Run("should build the overlay on top of the job manifests", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Kustomization = &KustomizeOverlay{
+			NamePrefix:   "edge-",
+			CommonLabels: map[string]string{"site": "edge-1"},
+			Images:       []types.Image{{Name: "nginx", NewTag: "1.27"}},
+			Patches: []types.Patch{{
+				Patch:  "- op: replace\n  path: /spec/replicas\n  value: 2",
+				Target: &types.Selector{ResId: resid.ResId{Gvk: resid.Gvk{Kind: "Deployment"}, Name: "nginx"}},
+			}},
+		}
+
+		manifestWork, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
+		assert.Len(t, manifestWork.Spec.Workload.Manifests, 3)
+		deployment, ok := manifestWork.Spec.Workload.Manifests[1].Object.(*appsv1.Deployment)
+		assert.True(t, ok)
+		assert.Equal(t, "edge-nginx", deployment.Name)
+		assert.Equal(t, j.Namespace, deployment.Namespace)
+		assert.Equal(t, "edge-1", deployment.Labels["site"])
+		assert.Equal(t, int32(2), *deployment.Spec.Replicas)
+		assert.Equal(t, "nginx:1.27", deployment.Spec.Template.Spec.Containers[0].Image)
+
+		report, err := ValidateJob(&j, "")
+		assert.NoError(t, err)
+		assert.True(t, report.Valid, "%v", report.Errors)
+	})
+
+	t.Run("should reject patch files", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Kustomization = &KustomizeOverlay{Patches: []types.Patch{{Path: "patch.yaml"}}}
+
+		_, err := GenerateManifestWork(&j)
+		assert.ErrorContains(t, err, "patch 0")
+	})
+}
@@ -0,0 +1,265 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+// States of a resource, computed from the conditions of its ManifestWorks.
+const (
+	ResourcePending     ResourceState = "Pending"
+	ResourceApplied     ResourceState = "Applied"
+	ResourceProgressing ResourceState = "Progressing"
+	ResourceAvailable   ResourceState = "Available"
+	ResourceDegraded    ResourceState = "Degraded"
+
+	defaultResourcePageSize = 20
+	maxResourcePageSize     = 100
+)
+
+// fields resources can be sorted by
+const (
+	SortByName      = "name"
+	SortByCluster   = "cluster"
+	SortByApp       = "app"
+	SortByState     = "state"
+	SortByCreatedAt = "created_at"
+)
+
+// ResourceFilter selects, orders and paginates the resources returned by ListResources. Empty
+// filters match every resource.
+type ResourceFilter struct {
+	ClusterName string
+	AppName     string
+	Component   string
+	JobGroupID  string
+	State       ResourceState
+	// Page starts at 1
+	Page     int
+	PageSize int
+	SortBy   string
+	// Descending reverses the order of SortBy
+	Descending bool
+}
+
+// ErrInvalidResourceFilter is returned by ListResources when the filter cannot be applied.
+var ErrInvalidResourceFilter = errors.New("invalid resource filter")
+
+// ResourceList is a page of resources, out of Total resources matching the filter.
+type ResourceList struct {
+	Resources []Resource `json:"resources"`
+	Total     int        `json:"total"`
+	Page      int        `json:"page"`
+	PageSize  int        `json:"page_size"`
+}
+
+// validate checks the filter and fills in the default page, page size and order.
+func (f *ResourceFilter) validate() error {
+	if f.Page == 0 {
+		f.Page = 1
+	}
+	if f.PageSize == 0 {
+		f.PageSize = defaultResourcePageSize
+	}
+	if f.Page < 0 {
+		return fmt.Errorf("page must be positive")
+	}
+	if f.PageSize < 0 || f.PageSize > maxResourcePageSize {
+		return fmt.Errorf("page_size must be between 1 and %d", maxResourcePageSize)
+	}
+	if f.SortBy == "" {
+		f.SortBy = SortByName
+	}
+	switch f.SortBy {
+	case SortByName, SortByCluster, SortByApp, SortByState, SortByCreatedAt:
+	default:
+		return fmt.Errorf("cannot sort by %q", f.SortBy)
+	}
+	if f.State != "" {
+		state, ok := parseResourceState(string(f.State))
+		if !ok {
+			return fmt.Errorf("unknown state %q", f.State)
+		}
+		f.State = state
+	}
+	return nil
+}
+
+func parseResourceState(value string) (ResourceState, bool) {
+	for _, state := range []ResourceState{ResourcePending, ResourceApplied, ResourceProgressing, ResourceAvailable, ResourceDegraded} {
+		if strings.EqualFold(value, string(state)) {
+			return state, true
+		}
+	}
+	return "", false
+}
+
+// ListResources lists the resources deployed by the deploy manager on the hub, with the status of their
+// ManifestWork and parts, filtered, sorted and paginated as given.
+func ListResources(filter ResourceFilter) (*ResourceList, error) {
+	if err := filter.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResourceFilter, err)
+	}
+
+	clusters := []string{filter.ClusterName}
+	if filter.ClusterName == "" {
+		managedClusters, err := clientsetClusterOper.ClusterV1().ManagedClusters().List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error obtaining managed clusters: %v", err)
+		}
+		clusters = clusters[:0]
+		for _, managedCluster := range managedClusters.Items {
+			clusters = append(clusters, managedCluster.Name)
+		}
+	}
+
+	ownership := labels.Set{}
+	if filter.JobGroupID != "" {
+		ownership[JobGroupIDLabel] = filter.JobGroupID
+	}
+	resources := []Resource{}
+	for _, cluster := range clusters {
+		// works created before the ownership labels only have the job group in the annotations of
+		// their objects, so it is matched again after listing
+		works, err := listManagedManifestWorks(cluster, ownership, true)
+		if err != nil {
+			return nil, fmt.Errorf("error listing ManifestWorks of cluster %s: %v", cluster, err)
+		}
+		partsOf, err := listManifestWorkParts(cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range works {
+			work := &works[i]
+			resourceID, jobID, managed := attributeManifestWork(work)
+			if !managed {
+				continue
+			}
+			aggregateManifestWorkStatus(work, partsOf[work.Name])
+			resource := workResource(work, resourceID, jobID)
+			for _, part := range partsOf[work.Name] {
+				resource.ResourceParts = append(resource.ResourceParts, part.Name)
+			}
+			if filter.matches(&resource) {
+				resources = append(resources, resource)
+			}
+		}
+	}
+
+	sortResources(resources, filter.SortBy, filter.Descending)
+	list := &ResourceList{Resources: []Resource{}, Total: len(resources), Page: filter.Page, PageSize: filter.PageSize}
+	start := (filter.Page - 1) * filter.PageSize
+	if start < len(resources) {
+		list.Resources = resources[start:min(start+filter.PageSize, len(resources))]
+	}
+	return list, nil
+}
+
+// workResource describes a primary ManifestWork as a resource, taking the application and component
+// from the annotations of its objects.
+func workResource(work *workv1.ManifestWork, resourceID, jobID string) Resource {
+	resource := Resource{
+		BaseUUID:     BaseUUID{ID: resourceID, Metadata: Metadata{CreatedAt: work.CreationTimestamp.Time}},
+		JobID:        jobID,
+		ResourceUUID: string(work.UID),
+		ResourceName: work.Name,
+		Conditions:   work.Status.Conditions,
+		ClusterName:  work.Namespace,
+		JobGroupID:   work.Labels[JobGroupIDLabel],
+		State:        resourceState(work.Status.Conditions),
+	}
+	for _, manifest := range work.Spec.Workload.Manifests {
+		annotations := manifestAnnotations(manifest)
+		if resource.AppName == "" {
+			resource.AppName = annotations["app.icos.eu/name"]
+		}
+		if resource.Component == "" {
+			resource.Component = annotations["app.icos.eu/component"]
+		}
+		if resource.JobGroupID == "" {
+			resource.JobGroupID = annotations[AppInstanceAnnotation]
+		}
+	}
+	return resource
+}
+
+// resourceState computes the state of a resource from the conditions of its ManifestWork: the first
+// of Degraded, Progressing, Available and Applied that holds, Pending when none does.
+func resourceState(conditions []metav1.Condition) ResourceState {
+	switch {
+	case meta.IsStatusConditionTrue(conditions, workv1.WorkDegraded):
+		return ResourceDegraded
+	case meta.IsStatusConditionTrue(conditions, workv1.WorkProgressing):
+		return ResourceProgressing
+	case meta.IsStatusConditionTrue(conditions, workv1.WorkAvailable):
+		return ResourceAvailable
+	case meta.IsStatusConditionTrue(conditions, workv1.WorkApplied):
+		return ResourceApplied
+	default:
+		return ResourcePending
+	}
+}
+
+func (f *ResourceFilter) matches(resource *Resource) bool {
+	return (f.AppName == "" || resource.AppName == f.AppName) &&
+		(f.Component == "" || resource.Component == f.Component) &&
+		(f.JobGroupID == "" || resource.JobGroupID == f.JobGroupID) &&
+		(f.State == "" || resource.State == f.State)
+}
+
+// sortResources orders resources by the given field, then by cluster and name so that pages are stable.
+func sortResources(resources []Resource, sortBy string, descending bool) {
+	key := func(resource *Resource) string {
+		switch sortBy {
+		case SortByCluster:
+			return resource.ClusterName
+		case SortByApp:
+			return resource.AppName
+		case SortByState:
+			return string(resource.State)
+		case SortByCreatedAt:
+			return resource.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000000")
+		default:
+			return resource.ResourceName
+		}
+	}
+	sort.SliceStable(resources, func(a, b int) bool {
+		keyA, keyB := key(&resources[a]), key(&resources[b])
+		if keyA != keyB {
+			return (keyA < keyB) != descending
+		}
+		if resources[a].ClusterName != resources[b].ClusterName {
+			return resources[a].ClusterName < resources[b].ClusterName
+		}
+		return resources[a].ResourceName < resources[b].ResourceName
+	})
+}
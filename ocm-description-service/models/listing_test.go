@@ -0,0 +1,146 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestListResources(t *testing.T) {
+	workClient := newFakeWorkClient()
+	useFakeClients(t, workClient, clusterfake.NewSimpleClientset(
+		&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+		&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster2"}},
+	))
+	t.Setenv("MANIFEST_VALIDATION", "false")
+
+	deploy := func(cluster, name, app, jobGroup string, conditions ...metav1.Condition) {
+		j := MockCreateDeploymentJob()
+		j.Target.ClusterName = cluster
+		j.Resource.ResourceName = name
+		j.JobGroupName = app
+		j.JobGroupID = jobGroup
+		created, err := createManifestWork(&j)
+		assert.NoError(t, err)
+		created.Status.Conditions = conditions
+		_, err = workClient.WorkV1().ManifestWorks(cluster).UpdateStatus(context.TODO(), created, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+	}
+	available := metav1.Condition{Type: workv1.WorkAvailable, Status: metav1.ConditionTrue}
+	degraded := metav1.Condition{Type: workv1.WorkDegraded, Status: metav1.ConditionTrue}
+	deploy("cluster1", "web", "shop", "4f1a2c3e-7b6d-4c2e-8f9a-1d2e3f4a5b6c", available)
+	deploy("cluster1", "db", "shop", "4f1a2c3e-7b6d-4c2e-8f9a-1d2e3f4a5b6c", available, degraded)
+	deploy("cluster2", "api", "blog", "7c9e6679-7425-40de-944b-e07fc1f90ae7")
+	// works not created by the deploy manager are not listed
+	_, err := workClient.WorkV1().ManifestWorks("cluster2").Create(context.TODO(), &workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "cluster2"}}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	names := func(list *ResourceList) []string {
+		names := []string{}
+		for _, resource := range list.Resources {
+			names = append(names, resource.ResourceName)
+		}
+		return names
+	}
+
+	t.Run("should list every resource with its computed state", func(t *testing.T) {
+		list, err := ListResources(ResourceFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, list.Total)
+		assert.Equal(t, []string{"api-abcde", "db-abcde", "web-abcde"}, names(list))
+
+		api := list.Resources[0]
+		assert.Equal(t, "cluster2", api.ClusterName)
+		assert.Equal(t, "blog", api.AppName)
+		assert.Equal(t, "api", api.Component)
+		assert.Equal(t, "7c9e6679-7425-40de-944b-e07fc1f90ae7", api.JobGroupID)
+		assert.Equal(t, "9e8d7c6b-5a4f-4e3d-2c1b-0a9f8e7d6c5b", api.ID)
+		assert.Equal(t, ResourcePending, api.State)
+		assert.Equal(t, ResourceDegraded, list.Resources[1].State)
+		assert.Equal(t, ResourceAvailable, list.Resources[2].State)
+	})
+
+	t.Run("should filter resources", func(t *testing.T) {
+		for _, tc := range []struct {
+			filter ResourceFilter
+			names  []string
+		}{
+			{ResourceFilter{ClusterName: "cluster1"}, []string{"db-abcde", "web-abcde"}},
+			{ResourceFilter{AppName: "blog"}, []string{"api-abcde"}},
+			{ResourceFilter{Component: "web"}, []string{"web-abcde"}},
+			{ResourceFilter{JobGroupID: "4f1a2c3e-7b6d-4c2e-8f9a-1d2e3f4a5b6c"}, []string{"db-abcde", "web-abcde"}},
+			{ResourceFilter{State: "degraded"}, []string{"db-abcde"}},
+			{ResourceFilter{ClusterName: "cluster2", State: ResourceAvailable}, []string{}},
+		} {
+			list, err := ListResources(tc.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.names, names(list))
+		}
+	})
+
+	t.Run("should sort and paginate resources", func(t *testing.T) {
+		list, err := ListResources(ResourceFilter{SortBy: SortByCluster, Descending: true, PageSize: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"api-abcde", "db-abcde"}, names(list))
+		assert.Equal(t, 3, list.Total)
+
+		list, err = ListResources(ResourceFilter{SortBy: SortByCluster, Descending: true, PageSize: 2, Page: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"web-abcde"}, names(list))
+
+		list, err = ListResources(ResourceFilter{Page: 3, PageSize: 2})
+		assert.NoError(t, err)
+		assert.Empty(t, list.Resources)
+		assert.Equal(t, 3, list.Total)
+	})
+
+	t.Run("should reject invalid filters", func(t *testing.T) {
+		for _, filter := range []ResourceFilter{{SortBy: "size"}, {State: "Running"}, {PageSize: 1000}, {Page: -1}} {
+			_, err := ListResources(filter)
+			assert.ErrorIs(t, err, ErrInvalidResourceFilter)
+		}
+	})
+
+	t.Run("should filter works created before the ownership labels by job group", func(t *testing.T) {
+		legacyDeployment := []byte(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"legacy","annotations":{"app.icos.eu/instance":"legacy-group","jobmanager.icos.eu/manifest":"3c2b1a0f-9e8d-4c7b-a6f5-e4d3c2b1a0f9"}}}`)
+		_, err := workClient.WorkV1().ManifestWorks("cluster2").Create(context.TODO(), &workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{Name: "legacy-xyz", Namespace: "cluster2"},
+			Spec: workv1.ManifestWorkSpec{Workload: workv1.ManifestsTemplate{Manifests: []workv1.Manifest{
+				{RawExtension: runtime.RawExtension{Raw: legacyDeployment}},
+			}}},
+		}, metav1.CreateOptions{})
+		assert.NoError(t, err)
+
+		list, err := ListResources(ResourceFilter{JobGroupID: "legacy-group"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"legacy-xyz"}, names(list))
+	})
+}
@@ -0,0 +1,123 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	workv1 "open-cluster-management.io/api/work/v1"
+	yamlEncode "sigs.k8s.io/yaml"
+)
+
+const (
+	namespaceQuotaName         = "icos-quota"
+	namespaceLimitRangeName    = "icos-limits"
+	namespaceNetworkPolicyName = "icos-default-deny"
+)
+
+// NamespaceProvisioning describes, besides the Namespace itself, the objects every job namespace is
+// provisioned with. It is read as YAML or JSON from the NAMESPACE_PROVISIONING environment variable.
+type NamespaceProvisioning struct {
+	// Labels of the Namespace, e.g. the Pod Security admission levels
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// ResourceQuota, when set, is created as the icos-quota ResourceQuota
+	ResourceQuota *corev1.ResourceQuotaSpec `json:"resource_quota,omitempty"`
+	// LimitRange, when set, is created as the icos-limits LimitRange
+	LimitRange *corev1.LimitRangeSpec `json:"limit_range,omitempty"`
+	// DefaultDeny lists the policy types (Ingress, Egress) denied by default through the
+	// icos-default-deny NetworkPolicy, no policy is created when empty
+	DefaultDeny []networkingv1.PolicyType `json:"default_deny,omitempty"`
+}
+
+var namespaceProvisioning, namespaceProvisioningErr = loadNamespaceProvisioning(os.Getenv("NAMESPACE_PROVISIONING"))
+
+func loadNamespaceProvisioning(config string) (*NamespaceProvisioning, error) {
+	provisioning := &NamespaceProvisioning{}
+	if config == "" {
+		return provisioning, nil
+	}
+	if err := yamlEncode.UnmarshalStrict([]byte(config), provisioning); err != nil {
+		return nil, fmt.Errorf("error parsing NAMESPACE_PROVISIONING: %v", err)
+	}
+	for _, policyType := range provisioning.DefaultDeny {
+		if policyType != networkingv1.PolicyTypeIngress && policyType != networkingv1.PolicyTypeEgress {
+			return nil, fmt.Errorf("error parsing NAMESPACE_PROVISIONING: unknown default deny policy type %q", policyType)
+		}
+	}
+	return provisioning, nil
+}
+
+// generateNamespaceManifests generates the manifests of the given namespace: the Namespace and the
+// objects configured in NAMESPACE_PROVISIONING.
+func generateNamespaceManifests(namespace string) ([]workv1.Manifest, error) {
+	if namespaceProvisioningErr != nil {
+		return nil, namespaceProvisioningErr
+	}
+	provisioning := namespaceProvisioning
+
+	namespaceObj := &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        namespace,
+			Labels:      provisioning.Labels,
+			Annotations: provisioning.Annotations,
+		},
+	}
+	objects := []runtime.Object{namespaceObj.DeepCopy()}
+
+	if provisioning.ResourceQuota != nil {
+		objects = append(objects, &corev1.ResourceQuota{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ResourceQuota"},
+			ObjectMeta: metav1.ObjectMeta{Name: namespaceQuotaName, Namespace: namespace},
+			Spec:       *provisioning.ResourceQuota.DeepCopy(),
+		})
+	}
+	if provisioning.LimitRange != nil {
+		objects = append(objects, &corev1.LimitRange{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "LimitRange"},
+			ObjectMeta: metav1.ObjectMeta{Name: namespaceLimitRangeName, Namespace: namespace},
+			Spec:       *provisioning.LimitRange.DeepCopy(),
+		})
+	}
+	if len(provisioning.DefaultDeny) > 0 {
+		// an empty pod selector and no rules deny all traffic of the listed types
+		objects = append(objects, &networkingv1.NetworkPolicy{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+			ObjectMeta: metav1.ObjectMeta{Name: namespaceNetworkPolicyName, Namespace: namespace},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: append([]networkingv1.PolicyType{}, provisioning.DefaultDeny...),
+			},
+		})
+	}
+
+	manifests := make([]workv1.Manifest, 0, len(objects))
+	for _, obj := range objects {
+		manifests = append(manifests, workv1.Manifest{RawExtension: runtime.RawExtension{Object: obj}})
+	}
+	return manifests, nil
+}
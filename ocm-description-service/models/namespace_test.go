@@ -0,0 +1,88 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestNamespaceProvisioning(t *testing.T) {
+	restoreAfterTest(t, &namespaceProvisioning)
+	restoreAfterTest(t, &namespaceProvisioningErr)
+
+	t.Run("should provision the namespace as configured", func(t *testing.T) {
+		namespaceProvisioning, namespaceProvisioningErr = loadNamespaceProvisioning(`
+labels:
+  pod-security.kubernetes.io/enforce: restricted
+resource_quota:
+  hard:
+    pods: "20"
+limit_range:
+  limits:
+  - type: Container
+    default:
+      cpu: 500m
+default_deny: [Ingress, Egress]`)
+		assert.NoError(t, namespaceProvisioningErr)
+
+		j := MockCreateDeploymentJob()
+		manifestWork, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
+		kinds := []string{}
+		for _, manifest := range manifestWork.Spec.Workload.Manifests {
+			kinds = append(kinds, manifest.Object.GetObjectKind().GroupVersionKind().Kind)
+		}
+		assert.Equal(t, []string{"Namespace", "ResourceQuota", "LimitRange", "NetworkPolicy", "Deployment", "Service"}, kinds)
+
+		namespace := manifestWork.Spec.Workload.Manifests[0].Object.(*corev1.Namespace)
+		assert.Equal(t, "icos-test", namespace.Name)
+		assert.Equal(t, "restricted", namespace.Labels["pod-security.kubernetes.io/enforce"])
+		quota := manifestWork.Spec.Workload.Manifests[1].Object.(*corev1.ResourceQuota)
+		assert.Equal(t, "icos-test", quota.Namespace)
+		assert.Equal(t, "20", quota.Spec.Hard.Pods().String())
+		policy := manifestWork.Spec.Workload.Manifests[3].Object.(*networkingv1.NetworkPolicy)
+		assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}, policy.Spec.PolicyTypes)
+		assert.Empty(t, policy.Spec.Ingress)
+	})
+
+	t.Run("should only create the namespace by default", func(t *testing.T) {
+		namespaceProvisioning, namespaceProvisioningErr = loadNamespaceProvisioning("")
+		manifests, err := generateNamespaceManifests("icos-test")
+		assert.NoError(t, err)
+		assert.Len(t, manifests, 1)
+	})
+
+	t.Run("should fail jobs when the configuration is invalid", func(t *testing.T) {
+		namespaceProvisioning, namespaceProvisioningErr = loadNamespaceProvisioning("default_deny: [Everything]")
+		assert.ErrorContains(t, namespaceProvisioningErr, "unknown default deny policy type")
+		_, err := loadNamespaceProvisioning("resource_quotas: {}")
+		assert.Error(t, err)
+
+		j := MockCreateDeploymentJob()
+		_, err = GenerateManifestWork(&j)
+		assert.ErrorContains(t, err, "NAMESPACE_PROVISIONING")
+	})
+}
@@ -0,0 +1,200 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"encoding/json"
+	"icos/server/ocm-description-service/utils/logs"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// JobIDLabel holds the ID of the last job applied to a ManifestWork
+	JobIDLabel = "jobmanager.icos.eu/job"
+	// ResourceIDLabel holds the Job Manager ID of the resource a ManifestWork belongs to
+	ResourceIDLabel = "jobmanager.icos.eu/resource"
+	// JobGroupIDLabel holds the ID of the job group (application) a ManifestWork belongs to
+	JobGroupIDLabel = "jobmanager.icos.eu/job-group"
+	// OwnerIDLabel holds the ID of the owner of the job that created a ManifestWork
+	OwnerIDLabel = "jobmanager.icos.eu/owner"
+
+	// ManifestAnnotation holds, on every object of a ManifestWork, the Job Manager ID of its resource
+	ManifestAnnotation = "jobmanager.icos.eu/manifest"
+	// AppInstanceAnnotation holds, on every object of a ManifestWork, the ID of its job group
+	AppInstanceAnnotation = "app.icos.eu/instance"
+)
+
+// UnattributedWork is a ManifestWork found during sync that cannot be matched to a Job Manager resource.
+type UnattributedWork struct {
+	ClusterName string             `json:"cluster_name"`
+	Name        string             `json:"name"`
+	UID         string             `json:"uid"`
+	JobID       string             `json:"job_id,omitempty"`
+	Reason      string             `json:"reason"`
+	Conditions  []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ownershipLabels returns the labels linking a ManifestWork to the given job. IDs that are empty
+// or are not valid label values are left out.
+func ownershipLabels(j *Job) map[string]string {
+	ids := map[string]string{
+		JobIDLabel:      j.ID,
+		JobGroupIDLabel: j.JobGroupID,
+		OwnerIDLabel:    j.OwnerID,
+	}
+	if j.Resource != nil {
+		ids[ResourceIDLabel] = j.Resource.ID
+	}
+
+	ownership := map[string]string{}
+	for key, value := range ids {
+		if value == "" {
+			continue
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			logs.Logger.Printf("Skipping label %s on ManifestWork of Job %s: %s", key, j.ID, strings.Join(errs, ", "))
+			continue
+		}
+		ownership[key] = value
+	}
+	return ownership
+}
+
+// setOwnershipLabels stamps the ownership labels of the given job on a ManifestWork, replacing the
+// ones of previous jobs.
+func setOwnershipLabels(work *workv1.ManifestWork, j *Job) {
+	workLabels := work.GetLabels()
+	if workLabels == nil {
+		workLabels = map[string]string{}
+	}
+	for _, key := range []string{JobIDLabel, ResourceIDLabel, JobGroupIDLabel, OwnerIDLabel} {
+		delete(workLabels, key)
+	}
+	for key, value := range ownershipLabels(j) {
+		workLabels[key] = value
+	}
+	work.SetLabels(workLabels)
+}
+
+// managedManifestWorkSelector selects the primary ManifestWorks carrying the ownership labels,
+// restricted to the given ownership label values.
+func managedManifestWorkSelector(ownership labels.Set) string {
+	primary, _ := labels.NewRequirement(PartOfLabel, selection.DoesNotExist, nil)
+	labelled, _ := labels.NewRequirement(JobIDLabel, selection.Exists, nil)
+	selector := labels.NewSelector().Add(*primary, *labelled)
+
+	for key, value := range ownership {
+		requirement, err := labels.NewRequirement(key, selection.Equals, []string{value})
+		if err != nil {
+			logs.Logger.Printf("Ignoring invalid ManifestWork filter %s=%s: %v", key, value, err)
+			continue
+		}
+		selector = selector.Add(*requirement)
+	}
+	return selector.String()
+}
+
+// legacyManifestWorkSelector selects the primary ManifestWorks without ownership labels, among which
+// the ones created by the deploy manager before the labels existed.
+func legacyManifestWorkSelector() string {
+	primary, _ := labels.NewRequirement(PartOfLabel, selection.DoesNotExist, nil)
+	unlabelled, _ := labels.NewRequirement(JobIDLabel, selection.DoesNotExist, nil)
+	return labels.NewSelector().Add(*primary, *unlabelled).String()
+}
+
+// listManagedManifestWorks lists the primary ManifestWorks of a cluster carrying the ownership
+// labels, restricted to the given ownership label values. With legacy, the ones without ownership
+// labels are listed as well; attributeManifestWork tells which of them the deploy manager created.
+func listManagedManifestWorks(cluster string, ownership labels.Set, legacy bool) ([]workv1.ManifestWork, error) {
+	works, err := ListManifestWork(cluster, managedManifestWorkSelector(ownership))
+	if err != nil {
+		return nil, err
+	}
+	if !legacy {
+		return works.Items, nil
+	}
+	legacyWorks, err := ListManifestWork(cluster, legacyManifestWorkSelector())
+	if err != nil {
+		return nil, err
+	}
+	return append(works.Items, legacyWorks.Items...), nil
+}
+
+// attributeManifestWork recovers the Job Manager resource and job IDs of a ManifestWork from its
+// ownership labels, falling back to the annotations of its objects for works created before the
+// labels existed. managed reports whether the work was created by the deploy manager at all.
+func attributeManifestWork(work *workv1.ManifestWork) (resourceID, jobID string, managed bool) {
+	resourceID = work.Labels[ResourceIDLabel]
+	jobID = work.Labels[JobIDLabel]
+	managed = resourceID != "" || jobID != ""
+	if resourceID != "" {
+		return resourceID, jobID, managed
+	}
+
+	for _, manifest := range work.Spec.Workload.Manifests {
+		annotations := manifestAnnotations(manifest)
+		if _, ok := annotations[AppInstanceAnnotation]; ok {
+			managed = true
+		}
+		if id := annotations[ManifestAnnotation]; id != "" {
+			return id, jobID, true
+		}
+	}
+	return "", jobID, managed
+}
+
+// manifestAnnotations returns the annotations of the object held by a manifest.
+func manifestAnnotations(manifest workv1.Manifest) map[string]string {
+	obj, err := manifestMetadata(manifest)
+	if err != nil {
+		return nil
+	}
+	return obj.Annotations
+}
+
+// manifestMetadata returns the kind and metadata of the object held by a manifest.
+func manifestMetadata(manifest workv1.Manifest) (*metav1.PartialObjectMetadata, error) {
+	if manifest.Object != nil {
+		metaObj, err := meta.Accessor(manifest.Object)
+		if err != nil {
+			return nil, err
+		}
+		obj := &metav1.PartialObjectMetadata{}
+		obj.APIVersion, obj.Kind = manifest.Object.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+		obj.Name = metaObj.GetName()
+		obj.Namespace = metaObj.GetNamespace()
+		obj.Labels = metaObj.GetLabels()
+		obj.Annotations = metaObj.GetAnnotations()
+		return obj, nil
+	}
+	var obj metav1.PartialObjectMetadata
+	if err := json.Unmarshal(manifest.Raw, &obj); err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
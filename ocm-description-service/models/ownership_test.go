@@ -0,0 +1,125 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	workfake "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestOwnershipLabels(t *testing.T) {
+	t.Run("should stamp the job ownership on the ManifestWork", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.OwnerID = "owner@icos"
+
+		manifestWork, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			JobIDLabel:      j.ID,
+			ResourceIDLabel: j.Resource.ID,
+			JobGroupIDLabel: j.JobGroupID,
+		}, manifestWork.Labels, "owner IDs that are not valid label values are left out")
+
+		update := MockUpdateJob(ScaleUp)
+		update.ID = "5d4c3b2a-1f0e-4d9c-8b7a-6f5e4d3c2b1a"
+		update.OwnerID = "7c6b5a4f-3e2d-4c1b-9a8f-7e6d5c4b3a2f"
+		setOwnershipLabels(manifestWork, &update)
+		assert.Equal(t, update.ID, manifestWork.Labels[JobIDLabel])
+		assert.Equal(t, update.OwnerID, manifestWork.Labels[OwnerIDLabel])
+	})
+
+	t.Run("should only sync ManifestWorks managed by the deploy manager", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		legacyDeployment := []byte(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"legacy","annotations":{"app.icos.eu/instance":"legacy-group","jobmanager.icos.eu/manifest":"3c2b1a0f-9e8d-4c7b-a6f5-e4d3c2b1a0f9"}}}`)
+		useFakeClients(t, workfake.NewSimpleClientset(
+			&workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "nginx-abcde", Namespace: "cluster1", UID: "uid-nginx", Labels: ownershipLabels(&j)}},
+			&workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "nginx-abcde-part-1", Namespace: "cluster1", Labels: map[string]string{
+				JobIDLabel: j.ID, PartOfLabel: "nginx-abcde", PartLabel: "1",
+			}}},
+			&workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "cluster1"}},
+			&workv1.ManifestWork{
+				ObjectMeta: metav1.ObjectMeta{Name: "legacy-xyz", Namespace: "cluster1"},
+				Spec: workv1.ManifestWorkSpec{Workload: workv1.ManifestsTemplate{Manifests: []workv1.Manifest{
+					{RawExtension: runtime.RawExtension{Raw: legacyDeployment}},
+				}}},
+			},
+			&workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "other-app", Namespace: "cluster1", Labels: map[string]string{
+				JobIDLabel: "1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d", JobGroupIDLabel: "another-group",
+			}}},
+		), clusterfake.NewSimpleClientset(&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}))
+
+		result, err := ResourceSync(nil)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []Resource{
+			{BaseUUID: BaseUUID{ID: j.Resource.ID}, JobID: j.ID, ResourceUUID: "uid-nginx", ResourceName: "nginx-abcde", ResourceParts: []string{"nginx-abcde-part-1"}},
+			{BaseUUID: BaseUUID{ID: "3c2b1a0f-9e8d-4c7b-a6f5-e4d3c2b1a0f9"}, ResourceName: "legacy-xyz"},
+		}, result.Resources)
+		assert.Len(t, result.Unattributed, 1, "works without a resource ID are reported separately")
+		assert.Equal(t, "other-app", result.Unattributed[0].Name)
+		assert.Equal(t, "1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d", result.Unattributed[0].JobID)
+
+		result, err = ResourceSync(map[string]string{JobGroupIDLabel: j.JobGroupID})
+		assert.NoError(t, err)
+		assert.Len(t, result.Resources, 1)
+		assert.Equal(t, "nginx-abcde", result.Resources[0].ResourceName)
+		assert.Empty(t, result.Unattributed)
+	})
+
+	t.Run("should select labelled ManifestWorks on the hub and only list unlabelled ones for legacy works", func(t *testing.T) {
+		workClient := workfake.NewSimpleClientset()
+		useFakeClients(t, workClient, clusterfake.NewSimpleClientset(&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}))
+		listed := func() []string {
+			selectors := []string{}
+			for _, action := range workClient.Actions() {
+				if list, ok := action.(clienttesting.ListAction); ok {
+					selectors = append(selectors, list.GetListRestrictions().Labels.String())
+				}
+			}
+			workClient.ClearActions()
+			return selectors
+		}
+
+		_, err := ResourceSync(nil)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"!" + PartOfLabel + "," + JobIDLabel,
+			"!" + PartOfLabel + ",!" + JobIDLabel,
+			PartOfLabel,
+		}, listed())
+
+		_, err = ResourceSync(map[string]string{JobGroupIDLabel: "4f1a2c3e-7b6d-4c2e-8f9a-1d2e3f4a5b6c"})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"!" + PartOfLabel + "," + JobIDLabel + "," + JobGroupIDLabel + "=4f1a2c3e-7b6d-4c2e-8f9a-1d2e3f4a5b6c",
+			PartOfLabel,
+		}, listed(), "works without ownership labels cannot match an ownership filter")
+	})
+}
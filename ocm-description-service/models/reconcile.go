@@ -0,0 +1,337 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"icos/server/ocm-description-service/utils/logs"
+	"reflect"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+// Actions taken on the entries of a reconciliation report
+const (
+	ActionAdopted   = "adopted"
+	ActionRecreated = "recreated"
+	ActionDeleted   = "deleted"
+)
+
+// ReconcileOptions selects the corrective actions taken while reconciling.
+type ReconcileOptions struct {
+	// Adopt keeps the live spec of drifted ManifestWorks and relabels them with their last job
+	Adopt bool
+	// Recreate creates missing ManifestWorks and overwrites drifted ones with the desired manifests
+	Recreate bool
+	// GC deletes orphaned ManifestWorks that carry a resource ID
+	GC bool
+}
+
+// ReconcileEntry is a ManifestWork, or a missing one, found while reconciling.
+type ReconcileEntry struct {
+	ClusterName string   `json:"cluster_name"`
+	Name        string   `json:"name,omitempty"`
+	ResourceID  string   `json:"resource_id,omitempty"`
+	JobID       string   `json:"job_id,omitempty"`
+	Details     []string `json:"details,omitempty"`
+	Action      string   `json:"action,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// ReconcileReport compares the ManifestWorks on the hub with the resources known to the Job Manager.
+type ReconcileReport struct {
+	// Orphaned ManifestWorks were created by the deploy manager but belong to none of the known resources
+	Orphaned []ReconcileEntry `json:"orphaned"`
+	// Missing resources have no ManifestWork on their target cluster
+	Missing []ReconcileEntry `json:"missing"`
+	// Drifted ManifestWorks no longer match the manifests of the last job applied to them
+	Drifted []ReconcileEntry `json:"drifted"`
+	// Unchecked ManifestWorks were last changed by an update job, whose desired manifests are unknown,
+	// so they were not checked for drift
+	Unchecked []ReconcileEntry `json:"unchecked"`
+	// Unreachable clusters could not be listed; their resources are neither reported missing nor
+	// recreated
+	Unreachable []ReconcileEntry `json:"unreachable"`
+}
+
+// LastResourceJobs keeps the last job applied to each resource of the given jobs, as listed by the
+// Job Manager. Resources whose last job deleted them are left out.
+func LastResourceJobs(jobs []Job) []Job {
+	last := map[string]Job{}
+	for _, j := range jobs {
+		if j.Orchestrator != OCM || j.Resource == nil || j.Resource.ID == "" {
+			continue
+		}
+		if current, ok := last[j.Resource.ID]; ok && !j.UpdatedAt.After(current.UpdatedAt) {
+			continue
+		}
+		last[j.Resource.ID] = j
+	}
+	resourceJobs := []Job{}
+	for _, j := range last {
+		if j.Type != DeleteDeployment {
+			resourceJobs = append(resourceJobs, j)
+		}
+	}
+	sort.Slice(resourceJobs, func(a, b int) bool { return resourceJobs[a].Resource.ID < resourceJobs[b].Resource.ID })
+	return resourceJobs
+}
+
+// Reconcile compares the ManifestWorks on the hub with the given jobs, the last job applied to each
+// resource known to the Job Manager, and takes the corrective actions selected in opts. A cluster
+// whose ManifestWorks cannot be listed is reported without stopping the others.
+func Reconcile(jobs []Job, opts ReconcileOptions) (*ReconcileReport, error) {
+	if opts.Adopt && opts.Recreate {
+		return nil, fmt.Errorf("adopt and recreate cannot be requested together")
+	}
+	if opts.GC && len(jobs) == 0 {
+		// an empty set would make every ManifestWork on the hub an orphan
+		return nil, fmt.Errorf("refusing to garbage collect: no resources are known to the Job Manager")
+	}
+
+	jobsByResource := map[string]*Job{}
+	for i := range jobs {
+		j := &jobs[i]
+		if j.Resource == nil || j.Resource.ID == "" {
+			return nil, fmt.Errorf("job %s: resource ID is required", j.ID)
+		}
+		jobsByResource[j.Resource.ID] = j
+	}
+
+	managedClusters, err := clientsetClusterOper.ClusterV1().ManagedClusters().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining managed clusters: %v", err)
+	}
+
+	report := &ReconcileReport{
+		Orphaned:    []ReconcileEntry{},
+		Missing:     []ReconcileEntry{},
+		Drifted:     []ReconcileEntry{},
+		Unchecked:   []ReconcileEntry{},
+		Unreachable: []ReconcileEntry{},
+	}
+	found := map[string]bool{}
+	unreachable := map[string]bool{}
+	for _, managedCluster := range managedClusters.Items {
+		works, err := listManagedManifestWorks(managedCluster.Name, nil, true)
+		if err != nil {
+			logs.Logger.Println("Error during reconciliation:", err)
+			unreachable[managedCluster.Name] = true
+			report.Unreachable = append(report.Unreachable, ReconcileEntry{ClusterName: managedCluster.Name, Error: err.Error()})
+			continue
+		}
+
+		for i := range works {
+			work := &works[i]
+			resourceID, jobID, managed := attributeManifestWork(work)
+			if !managed {
+				continue
+			}
+			entry := ReconcileEntry{ClusterName: work.Namespace, Name: work.Name, ResourceID: resourceID, JobID: jobID}
+
+			j, known := jobsByResource[resourceID]
+			if !known || j.Target.ClusterName != work.Namespace {
+				switch {
+				case resourceID == "":
+					// works that cannot be matched to a resource are never deleted
+					entry.Details = []string{"no resource ID, not garbage collected"}
+				case opts.GC:
+					recordAction(&entry, ActionDeleted, deleteManifestWorkSet(work.Namespace, work.Name))
+				}
+				report.Orphaned = append(report.Orphaned, entry)
+				continue
+			}
+			found[resourceID] = true
+
+			entry.JobID = j.ID
+			if !describesManifests(j) {
+				entry.Details = []string{"last changed by an update job, drift not checked"}
+				report.Unchecked = append(report.Unchecked, entry)
+				continue
+			}
+			drift, err := detectDrift(j, work)
+			if err != nil {
+				entry.Error = err.Error()
+				report.Drifted = append(report.Drifted, entry)
+				continue
+			}
+			if len(drift) == 0 {
+				continue
+			}
+			entry.Details = drift
+			switch {
+			case opts.Adopt:
+				setOwnershipLabels(work, j)
+				_, err := appliedWorks.update(work)
+				recordAction(&entry, ActionAdopted, err)
+			case opts.Recreate:
+				j.Resource.ResourceName = work.Name
+				_, err := replaceDeployment(j)
+				recordAction(&entry, ActionRecreated, err)
+			}
+			report.Drifted = append(report.Drifted, entry)
+		}
+	}
+
+	for i := range jobs {
+		j := &jobs[i]
+		if found[j.Resource.ID] || unreachable[j.Target.ClusterName] {
+			continue
+		}
+		entry := ReconcileEntry{ClusterName: j.Target.ClusterName, Name: j.Resource.ResourceName, ResourceID: j.Resource.ID, JobID: j.ID}
+		if opts.Recreate {
+			work, err := createManifestWork(j)
+			if err == nil {
+				entry.Name = work.Name
+			}
+			recordAction(&entry, ActionRecreated, err)
+		}
+		report.Missing = append(report.Missing, entry)
+	}
+
+	logs.Logger.Printf("Reconciliation found %d orphaned, %d missing and %d drifted ManifestWorks, %d were not checked and %d clusters could not be listed",
+		len(report.Orphaned), len(report.Missing), len(report.Drifted), len(report.Unchecked), len(report.Unreachable))
+	return report, nil
+}
+
+func recordAction(entry *ReconcileEntry, action string, err error) {
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+	entry.Action = action
+}
+
+// deleteManifestWorkSet deletes a primary ManifestWork together with its additional ManifestWorks and
+// its revisions.
+func deleteManifestWorkSet(namespace, name string) error {
+	err := appliedWorks.delete(namespace, name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting ManifestWork %s: %v", name, err)
+	}
+	if err := deleteManifestWorkParts(namespace, name, 0); err != nil {
+		return err
+	}
+	deleteRevisions(namespace, name)
+	return nil
+}
+
+// describesManifests tells whether the job holds the desired manifests of its resource. Remediation jobs
+// change the live manifests, they do not describe the desired ones.
+func describesManifests(j *Job) bool {
+	return j.Type == CreateDeployment || j.Type == ReplaceDeployment
+}
+
+// detectDrift compares the manifests generated from the job with the ones of the ManifestWork and its
+// parts, and returns a description of every object that was added, removed or changed.
+func detectDrift(j *Job, work *workv1.ManifestWork) ([]string, error) {
+	desiredWork, err := GenerateManifestWork(j)
+	if err != nil {
+		return nil, fmt.Errorf("error generating desired manifests: %v", err)
+	}
+	parts, err := fetchManifestWorkParts(work.Namespace, work.Name)
+	if err != nil {
+		return nil, err
+	}
+	live := append([]workv1.Manifest{}, work.Spec.Workload.Manifests...)
+	for _, part := range parts {
+		live = append(live, part.Spec.Workload.Manifests...)
+	}
+
+	desiredObjects, err := indexManifests(desiredWork.Spec.Workload.Manifests)
+	if err != nil {
+		return nil, err
+	}
+	liveObjects, err := indexManifests(live)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := []string{}
+	for key, desired := range desiredObjects {
+		current, ok := liveObjects[key]
+		switch {
+		case !ok:
+			drift = append(drift, key+" removed")
+		// typed and raw manifests do not encode empty fields alike
+		case !reflect.DeepEqual(normalizeObject(desired), normalizeObject(current)):
+			drift = append(drift, key+" changed")
+		}
+	}
+	for key := range liveObjects {
+		if _, ok := desiredObjects[key]; !ok {
+			drift = append(drift, key+" added")
+		}
+	}
+	sort.Strings(drift)
+	return drift, nil
+}
+
+// bookkeeping annotations written by the deploy manager, derived from job fields that change once the
+// resource exists (e.g. the resource name becomes the ManifestWork name), so they are not compared
+var bookkeepingAnnotations = []string{"app.icos.eu/name", "app.icos.eu/component", AppInstanceAnnotation, ManifestAnnotation}
+
+// indexManifests decodes manifests into generic objects indexed by kind, API group, namespace and name,
+// e.g. Deployment.apps/default/nginx, so that manifests holding a typed object and manifests holding
+// raw JSON can be compared.
+func indexManifests(manifests []workv1.Manifest) (map[string]interface{}, error) {
+	objects := map[string]interface{}{}
+	for i, manifest := range manifests {
+		encoded, err := json.Marshal(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding manifest %d: %v", i, err)
+		}
+		var obj metav1.PartialObjectMetadata
+		if err := json.Unmarshal(encoded, &obj); err != nil {
+			return nil, fmt.Errorf("error decoding manifest %d: %v", i, err)
+		}
+		var generic map[string]interface{}
+		if err := json.Unmarshal(encoded, &generic); err != nil {
+			return nil, fmt.Errorf("error decoding manifest %d: %v", i, err)
+		}
+		if metadata, ok := generic["metadata"].(map[string]interface{}); ok {
+			if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+				for _, key := range bookkeepingAnnotations {
+					delete(annotations, key)
+				}
+			}
+		}
+		kind := obj.Kind
+		// kinds of different groups may share a name, e.g. Ingress
+		if group := obj.GroupVersionKind().Group; group != "" {
+			kind += "." + group
+		}
+		key := kind + "/" + obj.Name
+		if obj.Namespace != "" {
+			key = kind + "/" + obj.Namespace + "/" + obj.Name
+		}
+		if _, duplicate := objects[key]; duplicate {
+			return nil, fmt.Errorf("manifest %d: %s is defined more than once", i, key)
+		}
+		objects[key] = generic
+	}
+	return objects, nil
+}
@@ -0,0 +1,219 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestReconcile(t *testing.T) {
+	workClient := newFakeWorkClient(&workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{
+		Name: "removed-app-xyz", Namespace: "cluster1", Labels: map[string]string{
+			JobIDLabel: "1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d", ResourceIDLabel: "2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e",
+		},
+	}}, &workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{
+		Name: "unattributed-xyz", Namespace: "cluster1", Labels: map[string]string{JobIDLabel: "3c2b1a0f-9e8d-4c7b-a6f5-e4d3c2b1a0f9"},
+	}})
+	useFakeClients(t, workClient, clusterfake.NewSimpleClientset(&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}))
+	t.Setenv("MANIFEST_VALIDATION", "false")
+
+	deployed := MockCreateDeploymentJob()
+	created, err := createManifestWork(&deployed)
+	assert.NoError(t, err)
+	// someone edits the ManifestWork directly
+	created.Spec.Workload.Manifests[1].Object.(*appsv1.Deployment).Spec.Replicas = nil
+	_, err = workClient.WorkV1().ManifestWorks("cluster1").Update(context.TODO(), created, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	notDeployed := MockCreateDeploymentJob()
+	notDeployed.ID = "5d4c3b2a-1f0e-4d9c-8b7a-6f5e4d3c2b1a"
+	notDeployed.Resource = &Resource{BaseUUID: BaseUUID{ID: "8f7e6d5c-4b3a-4f2e-9d1c-0b9a8f7e6d5c"}, ResourceName: "redis"}
+
+	jobs := func() []Job {
+		a, b := deployed, notDeployed
+		a.Resource, b.Resource = &Resource{BaseUUID: deployed.Resource.BaseUUID, ResourceName: "nginx"}, &Resource{BaseUUID: notDeployed.Resource.BaseUUID, ResourceName: "redis"}
+		return []Job{a, b}
+	}
+
+	t.Run("should report orphaned, missing and drifted works", func(t *testing.T) {
+		report, err := Reconcile(jobs(), ReconcileOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, report.Orphaned, 2)
+		assert.Equal(t, "removed-app-xyz", report.Orphaned[0].Name)
+		assert.Equal(t, "unattributed-xyz", report.Orphaned[1].Name)
+		assert.Len(t, report.Missing, 1)
+		assert.Equal(t, notDeployed.Resource.ID, report.Missing[0].ResourceID)
+		assert.Len(t, report.Drifted, 1)
+		assert.Equal(t, created.Name, report.Drifted[0].Name)
+		assert.Equal(t, []string{"Deployment.apps/icos-test/nginx changed"}, report.Drifted[0].Details)
+		assert.Empty(t, report.Drifted[0].Action)
+	})
+
+	t.Run("should report works last changed by an update job as unchecked", func(t *testing.T) {
+		updated := jobs()
+		updated[0].Type = UpdateDeployment
+
+		report, err := Reconcile(updated, ReconcileOptions{Adopt: true})
+		assert.NoError(t, err)
+		assert.Empty(t, report.Drifted)
+		assert.Len(t, report.Unchecked, 1)
+		assert.Equal(t, created.Name, report.Unchecked[0].Name)
+		assert.Empty(t, report.Unchecked[0].Action)
+	})
+
+	t.Run("should refuse to garbage collect without known resources", func(t *testing.T) {
+		_, err := Reconcile([]Job{}, ReconcileOptions{GC: true})
+		assert.ErrorContains(t, err, "refusing to garbage collect")
+		_, err = workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), "removed-app-xyz", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should fix every case when asked to", func(t *testing.T) {
+		_, err := Reconcile(jobs(), ReconcileOptions{Adopt: true, Recreate: true})
+		assert.Error(t, err)
+
+		report, err := Reconcile(jobs(), ReconcileOptions{Recreate: true, GC: true})
+		assert.NoError(t, err)
+		assert.Equal(t, ActionDeleted, report.Orphaned[0].Action)
+		assert.Empty(t, report.Orphaned[1].Action, "works without a resource ID are never deleted")
+		assert.Equal(t, ActionRecreated, report.Missing[0].Action)
+		assert.Equal(t, ActionRecreated, report.Drifted[0].Action)
+
+		report, err = Reconcile(jobs(), ReconcileOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, report.Orphaned, 1)
+		assert.Equal(t, "unattributed-xyz", report.Orphaned[0].Name)
+		assert.Empty(t, report.Missing)
+		assert.Empty(t, report.Drifted)
+	})
+
+	t.Run("should not report drift between raw and typed manifests of the same objects", func(t *testing.T) {
+		j := jobs()[0]
+		work, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
+		// the hub returns manifests as raw JSON, without the empty fields typed objects encode
+		live := work.DeepCopy()
+		for i, manifest := range live.Spec.Workload.Manifests {
+			encoded, err := json.Marshal(manifest)
+			assert.NoError(t, err)
+			var object map[string]interface{}
+			assert.NoError(t, json.Unmarshal(encoded, &object))
+			delete(object, "status")
+			delete(object["metadata"].(map[string]interface{}), "creationTimestamp")
+			encoded, err = json.Marshal(object)
+			assert.NoError(t, err)
+			live.Spec.Workload.Manifests[i] = workv1.Manifest{RawExtension: runtime.RawExtension{Raw: encoded}}
+		}
+
+		drift, err := detectDrift(&j, live)
+		assert.NoError(t, err)
+		assert.Empty(t, drift)
+	})
+
+	t.Run("should report clusters that cannot be listed and reconcile the others", func(t *testing.T) {
+		failingClient := newFakeWorkClient()
+		failingClient.PrependReactor("list", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if action.GetNamespace() == "cluster2" {
+				return true, nil, errors.New("connection refused")
+			}
+			return false, nil, nil
+		})
+		useFakeClients(t, failingClient, clusterfake.NewSimpleClientset(
+			&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+			&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster2"}},
+		))
+		elsewhere := jobs()[1]
+		elsewhere.Target = Target{ClusterName: "cluster2", NodeName: "cluster2"}
+
+		report, err := Reconcile([]Job{jobs()[0], elsewhere}, ReconcileOptions{Recreate: true})
+		assert.NoError(t, err)
+		assert.Len(t, report.Unreachable, 1)
+		assert.Equal(t, "cluster2", report.Unreachable[0].ClusterName)
+		assert.Contains(t, report.Unreachable[0].Error, "connection refused")
+		assert.Len(t, report.Missing, 1, "resources of unreachable clusters are not reported missing")
+		assert.Equal(t, "cluster1", report.Missing[0].ClusterName)
+		assert.Equal(t, ActionRecreated, report.Missing[0].Action)
+	})
+}
+
+func TestLastResourceJobs(t *testing.T) {
+	jobAt := func(id, resourceID string, jobType JobType, updatedAt time.Time) Job {
+		j := MockCreateDeploymentJob()
+		j.ID, j.Type, j.UpdatedAt = id, jobType, updatedAt
+		j.Resource = &Resource{BaseUUID: BaseUUID{ID: resourceID}}
+		return j
+	}
+	now := time.Now()
+	nuvla := jobAt("6e5d4c3b-2a1f-4e0d-9c8b-7a6f5e4d3c2b", "7f6e5d4c-3b2a-4f1e-8d9c-0b1a2f3e4d5c", CreateDeployment, now)
+	nuvla.Orchestrator = NUVLA
+	jobs := []Job{
+		jobAt("1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d", "9e8d7c6b-5a4f-4e3d-2c1b-0a9f8e7d6c5b", CreateDeployment, now.Add(-time.Hour)),
+		jobAt("2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e", "9e8d7c6b-5a4f-4e3d-2c1b-0a9f8e7d6c5b", UpdateDeployment, now),
+		jobAt("3c2b1a0f-9e8d-4c7b-a6f5-e4d3c2b1a0f9", "8f7e6d5c-4b3a-4f2e-9d1c-0b9a8f7e6d5c", CreateDeployment, now.Add(-time.Hour)),
+		jobAt("4d3c2b1a-0f9e-4d8c-b7a6-f5e4d3c2b1a0", "8f7e6d5c-4b3a-4f2e-9d1c-0b9a8f7e6d5c", DeleteDeployment, now),
+		nuvla,
+	}
+
+	t.Run("should keep the last job of every existing resource", func(t *testing.T) {
+		resourceJobs := LastResourceJobs(jobs)
+		assert.Len(t, resourceJobs, 1)
+		assert.Equal(t, "2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e", resourceJobs[0].ID)
+	})
+}
+
+func TestIndexManifests(t *testing.T) {
+	manifest := func(raw string) workv1.Manifest {
+		return workv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte(raw)}}
+	}
+	current := manifest(`{"apiVersion":"networking.k8s.io/v1","kind":"Ingress","metadata":{"name":"web","namespace":"icos-test"}}`)
+	legacy := manifest(`{"apiVersion":"extensions/v1beta1","kind":"Ingress","metadata":{"name":"web","namespace":"icos-test"}}`)
+	namespace := manifest(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"icos-test"}}`)
+
+	t.Run("should tell apart same-named kinds of different groups", func(t *testing.T) {
+		objects, err := indexManifests([]workv1.Manifest{current, legacy, namespace})
+		assert.NoError(t, err)
+		keys := []string{}
+		for key := range objects {
+			keys = append(keys, key)
+		}
+		assert.ElementsMatch(t, []string{"Ingress.networking.k8s.io/icos-test/web", "Ingress.extensions/icos-test/web", "Namespace/icos-test"}, keys)
+	})
+
+	t.Run("should reject objects defined more than once", func(t *testing.T) {
+		_, err := indexManifests([]workv1.Manifest{current, current})
+		assert.ErrorContains(t, err, "manifest 1: Ingress.networking.k8s.io/icos-test/web is defined more than once")
+	})
+}
@@ -0,0 +1,215 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const unsetValue = "<unset>"
+
+// reference.TagRegexp is not anchored
+var anchoredTagRegexp = regexp.MustCompile(`^` + reference.TagRegexp.String() + `$`)
+
+// RemediationParams are the parameters of an UpdateDeployment job.
+type RemediationParams struct {
+	// Component limits the remediation to the objects of that name, all of them when empty
+	Component string `json:"component,omitempty"`
+	// Containers limits the remediation to the named containers, all of them when empty
+	Containers []string `json:"containers,omitempty"`
+	// Replicas, for ScaleUp and ScaleDown, is the replica count to scale to and, for RemoveAutoscaler,
+	// the one Deployments get back from their HorizontalPodAutoscaler
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Resources, for ScaleOut and ScaleIn, are the CPU and memory requests to scale to
+	Resources corev1.ResourceList `json:"resources,omitempty" swaggertype:"object,string"`
+	// Autoscaler, for AddAutoscaler, UpdateAutoscaler and RemoveAutoscaler, describes the autoscaler
+	Autoscaler *AutoscalerParams `json:"autoscaler,omitempty"`
+	// ImageTag or ImageDigest, for SecurityRemediation, replace the tag or digest of the images of the
+	// named Containers
+	ImageTag    string `json:"image_tag,omitempty"`
+	ImageDigest string `json:"image_digest,omitempty"`
+	// CPUStep, MemoryStep or Percent, for ScaleOut and ScaleIn, override the ones of VERTICAL_SCALING
+	CPUStep    *resource.Quantity `json:"cpu_step,omitempty" swaggertype:"string"`
+	MemoryStep *resource.Quantity `json:"memory_step,omitempty" swaggertype:"string"`
+	Percent    int64              `json:"percent,omitempty"`
+	// Revision, for Rollback, is the revision of the resource to restore
+	Revision int `json:"revision,omitempty"`
+}
+
+// FieldChange records a field changed by a remediation. Field is the path of the field within the
+// object, or within its pod spec for the fields of the pod and its containers, which are identified by name.
+type FieldChange struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// targetsComponent reports whether the remediation applies to an object.
+func (p *RemediationParams) targetsComponent(obj runtime.Object) bool {
+	if p == nil || p.Component == "" {
+		return true
+	}
+	metaObj, err := meta.Accessor(obj)
+	return err == nil && metaObj.GetName() == p.Component
+}
+
+// targetsContainer reports whether the remediation applies to the named container.
+func (p *RemediationParams) targetsContainer(name string) bool {
+	if p == nil || len(p.Containers) == 0 {
+		return true
+	}
+	for _, container := range p.Containers {
+		if container == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RemediationParams) validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Replicas != nil && *p.Replicas < 0 {
+		return fmt.Errorf("replicas cannot be negative")
+	}
+	for name, quantity := range p.Resources {
+		if name != corev1.ResourceCPU && name != corev1.ResourceMemory {
+			return fmt.Errorf("only cpu and memory resources can be scaled, not %s", name)
+		}
+		if quantity.Sign() < 0 {
+			return fmt.Errorf("%s cannot be negative", name)
+		}
+	}
+	if err := p.Autoscaler.validate(); err != nil {
+		return err
+	}
+	if p.Revision < 0 {
+		return fmt.Errorf("revision cannot be negative")
+	}
+	if p.Percent < 0 {
+		return fmt.Errorf("percent cannot be negative")
+	}
+	if (p.CPUStep != nil && p.CPUStep.Sign() < 0) || (p.MemoryStep != nil && p.MemoryStep.Sign() < 0) {
+		return fmt.Errorf("steps cannot be negative")
+	}
+	if p.ImageTag != "" && p.ImageDigest != "" {
+		return fmt.Errorf("image_tag and image_digest cannot be set together")
+	}
+	if (p.ImageTag != "" || p.ImageDigest != "") && len(p.Containers) == 0 {
+		// a single image would replace the one of every container, sidecars included
+		return fmt.Errorf("containers is required to bump images")
+	}
+	if p.ImageTag != "" && !anchoredTagRegexp.MatchString(p.ImageTag) {
+		return fmt.Errorf("invalid image tag %q", p.ImageTag)
+	}
+	if p.ImageDigest != "" {
+		if _, err := digest.Parse(p.ImageDigest); err != nil {
+			return fmt.Errorf("invalid image digest %q: %v", p.ImageDigest, err)
+		}
+	}
+	return nil
+}
+
+// changeRecorder records the changes made to a single object.
+type changeRecorder struct {
+	kind    string
+	name    string
+	changes []FieldChange
+}
+
+func newChangeRecorder(obj runtime.Object) (*changeRecorder, error) {
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing object metadata: %v", err)
+	}
+	return &changeRecorder{kind: obj.GetObjectKind().GroupVersionKind().Kind, name: metaObj.GetName()}, nil
+}
+
+func (r *changeRecorder) record(field, old, new string) {
+	if old == new {
+		return
+	}
+	r.changes = append(r.changes, FieldChange{Kind: r.kind, Name: r.name, Field: field, Old: old, New: new})
+}
+
+// recordFieldChanges records every field of value, under the given path, that differs from old. A nil
+// value or old records the addition or removal of all the fields of the other.
+func (r *changeRecorder) recordFieldChanges(path string, old, value interface{}) {
+	oldFields := map[string]string{}
+	newFields := map[string]string{}
+	flattenFields(path, old, oldFields)
+	flattenFields(path, value, newFields)
+
+	paths := []string{}
+	for field := range oldFields {
+		paths = append(paths, field)
+	}
+	for field := range newFields {
+		if _, ok := oldFields[field]; !ok {
+			paths = append(paths, field)
+		}
+	}
+	sort.Strings(paths)
+	for _, field := range paths {
+		oldValue, ok := oldFields[field]
+		if !ok {
+			oldValue = unsetValue
+		}
+		newValue, ok := newFields[field]
+		if !ok {
+			newValue = unsetValue
+		}
+		r.record(field, oldValue, newValue)
+	}
+}
+
+// flattenFields maps the path of every scalar of a generic JSON value to its value.
+func flattenFields(path string, value interface{}, fields map[string]string) {
+	switch value := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		for key, field := range value {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			flattenFields(fieldPath, field, fields)
+		}
+	case []interface{}:
+		for i, item := range value {
+			flattenFields(fmt.Sprintf("%s[%d]", path, i), item, fields)
+		}
+	default:
+		fields[path] = fmt.Sprint(value)
+	}
+}
@@ -24,14 +24,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"icos/server/ocm-description-service/utils/logs"
+	"os"
+	"sort"
 	"strconv"
+	"sync"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes/scheme"
 	workv1 "open-cluster-management.io/api/work/v1"
 )
 
+// default number of clusters synced at the same time
+const defaultSyncConcurrency = 4
+
 // type Resource struct {
 // 	ID           string             `json:"resource_uuid"`
 // 	JobID        string             `json:"job_id" validate:"omitempty,uuid4"`
@@ -83,7 +92,7 @@ func GetManifestWork(namespace string, manifestWorkName string) (*workv1.Manifes
 	manifest, err := clientsetWorkOper.WorkV1().ManifestWorks(namespace).
 		Get(context.TODO(), manifestWorkName, metav1.GetOptions{})
 	// log.Debug("ExistsManifestWork: " + manifestWorkName + " " + strconv.FormatBool(err == nil)) //err.Error())
-	if err == nil {
+	if err != nil {
 		fmt.Println("ERROR: ", err)
 	}
 	return manifest, err
@@ -124,50 +133,165 @@ func DeleteManifestWork(namespace string, manifestWorkName string) bool {
 		return false
 	}
 	//err := clientset.CoreV1().Services(namespace).Delete(context.TODO(), serviceName, metav1.DeleteOptions{})
-	err := clientsetWorkOper.WorkV1().ManifestWorks(namespace).Delete(context.TODO(), manifestWorkName, metav1.DeleteOptions{})
+	err := appliedWorks.delete(namespace, manifestWorkName)
 	fmt.Println("DeleteManifestWork: " + manifestWorkName + " " + strconv.FormatBool(err == nil))
 	return err == nil
 }
 
-func ListManifestWork(namespace string) *workv1.ManifestWorkList {
-	manifestlist, err := clientsetWorkOper.WorkV1().ManifestWorks(namespace).List(context.TODO(), metav1.ListOptions{})
+func ListManifestWork(namespace string, labelSelector string) (*workv1.ManifestWorkList, error) {
+	manifestlist, err := clientsetWorkOper.WorkV1().ManifestWorks(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: labelSelector})
 	if err != nil {
-		fmt.Println("Error obtaining ManifestWorkList")
+		return nil, fmt.Errorf("error obtaining ManifestWorkList of cluster %s: %v", namespace, err)
 	}
-	return manifestlist
+	return manifestlist, nil
+}
+
+// SyncResult holds the status of the ManifestWorks collected during sync: the ones matched to a
+// Job Manager resource, the ones created by the deploy manager that could not be matched, and a
+// summary for each cluster.
+type SyncResult struct {
+	Resources    []Resource         `json:"resources"`
+	Unattributed []UnattributedWork `json:"unattributed"`
+	Clusters     []ClusterSync      `json:"clusters"`
+}
+
+// SyncSummary is the outcome of a sync reported to its caller.
+type SyncSummary struct {
+	Clusters     []ClusterSync      `json:"clusters"`
+	Unattributed []UnattributedWork `json:"unattributed"`
+	// Status is the outcome of reporting the synced resources to the Job Manager
+	Status *StatusReport `json:"status,omitempty"`
+}
+
+// ClusterSync counts the ManifestWorks of a cluster by sync outcome. Synced works were matched to a
+// Job Manager resource, failed ones were created by the deploy manager but could not be matched, and
+// skipped ones were not created by the deploy manager. Error is set when the cluster could not be
+// synced at all.
+type ClusterSync struct {
+	ClusterName string `json:"cluster_name"`
+	Synced      int    `json:"synced"`
+	Failed      int    `json:"failed"`
+	Skipped     int    `json:"skipped"`
+	Error       string `json:"error,omitempty"`
 }
 
-func ResourceSync() ([]Resource, error) {
-	var err error
-	var resources []Resource
-	// get all manifestworks
-	var manifestStatus *workv1.ManifestWorkStatus
-	// var managedClusters clusterv1.ManagedClusterList
+// Summary returns the per-cluster counts and unattributed ManifestWorks of the sync.
+func (r *SyncResult) Summary() SyncSummary {
+	return SyncSummary{Clusters: r.Clusters, Unattributed: r.Unattributed}
+}
+
+func getSyncConcurrency() int {
+	concurrency, err := strconv.Atoi(os.Getenv("SYNC_CONCURRENCY"))
+	if err != nil || concurrency <= 0 {
+		return defaultSyncConcurrency
+	}
+	return concurrency
+}
+
+// ResourceSync collects the status of the ManifestWorks managed by the deploy manager across all
+// managed clusters, optionally restricted to the given ownership labels. Clusters are synced in
+// parallel, up to SYNC_CONCURRENCY at a time; a cluster that cannot be synced is reported in its
+// summary and in the returned error, without stopping the others. The result is never nil.
+func ResourceSync(ownership labels.Set) (*SyncResult, error) {
+	result := &SyncResult{Resources: []Resource{}, Unattributed: []UnattributedWork{}, Clusters: []ClusterSync{}}
 	managedClusters, err := clientsetClusterOper.ClusterV1().ManagedClusters().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		fmt.Println("Error obtaining managed clusters")
+		return result, fmt.Errorf("error obtaining managed clusters: %v", err)
+	}
+
+	syncs := make([]clusterSyncResult, len(managedClusters.Items))
+	slots := make(chan struct{}, getSyncConcurrency())
+	var wg sync.WaitGroup
+	for i := range managedClusters.Items {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			syncs[i] = syncCluster(managedClusters.Items[i].Name, ownership)
+		}(i)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, clusterSync := range syncs {
+		result.Resources = append(result.Resources, clusterSync.resources...)
+		result.Unattributed = append(result.Unattributed, clusterSync.unattributed...)
+		result.Clusters = append(result.Clusters, clusterSync.summary)
+		if clusterSync.err != nil {
+			errs = append(errs, clusterSync.err)
+		}
+	}
+	sort.Slice(result.Clusters, func(a, b int) bool {
+		return result.Clusters[a].ClusterName < result.Clusters[b].ClusterName
+	})
+	return result, utilerrors.NewAggregate(errs)
+}
+
+type clusterSyncResult struct {
+	summary      ClusterSync
+	resources    []Resource
+	unattributed []UnattributedWork
+	err          error
+}
+
+// syncCluster collects the status of the ManifestWorks of a cluster matching the ownership labels.
+// Without restrictions, works created before the ownership labels existed are collected as well.
+func syncCluster(cluster string, ownership labels.Set) clusterSyncResult {
+	clusterSync := clusterSyncResult{summary: ClusterSync{ClusterName: cluster}}
+	allManifestWorks, err := listManagedManifestWorks(cluster, ownership, len(ownership) == 0)
+	if err != nil {
+		logs.Logger.Println("Error during resource sync:", err)
+		clusterSync.summary.Error = err.Error()
+		clusterSync.err = err
+		return clusterSync
+	}
+	if len(allManifestWorks) == 0 {
+		logs.Logger.Println("No Resources were found during sync up process for cluster: " + cluster)
+	}
+	partsOf, err := listManifestWorkParts(cluster)
+	if err != nil {
+		logs.Logger.Println("Error during resource sync:", err)
+		clusterSync.summary.Error = err.Error()
+		clusterSync.err = err
+		return clusterSync
 	}
-	for _, managedCluster := range managedClusters.Items {
-		allManifestWorks := ListManifestWork(managedCluster.Name)
-		// for each manifestwork
-		if len(allManifestWorks.Items) > 0 {
-			for _, manifestWork := range allManifestWorks.Items {
-				// get status
-				manifestStatus = &manifestWork.Status
-				// find job with the corresponding UID, should I assume it exists?
-				// manifestUID := uuid.MustParse(string(string(manifestWork.UID)))
-				resource := Resource{
-					ResourceUUID: string(manifestWork.UID),
-					ResourceName: manifestWork.Name,
-					Conditions:   manifestStatus.Conditions,
-				}
-				resources = append(resources, resource)
-			}
-		} else {
-			fmt.Println("No Resources were found during sync up process for cluster: " + managedCluster.Name)
+	for i := range allManifestWorks {
+		manifestWork := &allManifestWorks[i]
+		resourceID, jobID, managed := attributeManifestWork(manifestWork)
+		if !managed {
+			clusterSync.summary.Skipped++
+			continue
+		}
+		if resourceID == "" {
+			logs.Logger.Printf("ManifestWork %s/%s cannot be matched to a Job Manager resource", manifestWork.Namespace, manifestWork.Name)
+			clusterSync.summary.Failed++
+			clusterSync.unattributed = append(clusterSync.unattributed, UnattributedWork{
+				ClusterName: cluster,
+				Name:        manifestWork.Name,
+				UID:         string(manifestWork.UID),
+				JobID:       jobID,
+				Reason:      "no resource ID in ManifestWork labels or manifest annotations",
+				Conditions:  manifestWork.Status.Conditions,
+			})
+			continue
+		}
+		// a split resource is only as healthy as its least healthy part
+		aggregateManifestWorkStatus(manifestWork, partsOf[manifestWork.Name])
+		resource := Resource{
+			BaseUUID:     BaseUUID{ID: resourceID},
+			JobID:        jobID,
+			ResourceUUID: string(manifestWork.UID),
+			ResourceName: manifestWork.Name,
+			Conditions:   manifestWork.Status.Conditions,
+		}
+		for _, part := range partsOf[manifestWork.Name] {
+			resource.ResourceParts = append(resource.ResourceParts, part.Name)
 		}
+		clusterSync.summary.Synced++
+		clusterSync.resources = append(clusterSync.resources, resource)
 	}
-	return resources, err
+	return clusterSync
 }
 
 func PatchManifestWork(namespace string, manifestWorkName string, manifestWork workv1.ManifestWork) bool {
@@ -176,7 +300,7 @@ func PatchManifestWork(namespace string, manifestWorkName string, manifestWork w
 	//}
 	//_, err := clientset.CoreV1().Services(namespace).Get(context.TODO(), serviceName, metav1.GetOptions{})
 
-	_, err := clientsetWorkOper.WorkV1().ManifestWorks(namespace).Update(context.TODO(), &manifestWork, metav1.UpdateOptions{})
+	_, err := appliedWorks.update(&manifestWork)
 	// log.Debug("ExistsManifestWork: " + manifestWorkName + " " + strconv.FormatBool(err == nil)) //err.Error())
 	//if err == nil {
 	//	setManifestWorkCache(namespace, manifestWorkName)
@@ -0,0 +1,99 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	workfake "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestResourceSync(t *testing.T) {
+	j := MockCreateDeploymentJob()
+	managedCluster := func(name string) *clusterv1.ManagedCluster {
+		return &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	clusterClient := clusterfake.NewSimpleClientset(managedCluster("cluster1"), managedCluster("cluster2"), managedCluster("cluster3"))
+	workClient := workfake.NewSimpleClientset(
+		&workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{Name: "nginx-abcde", Namespace: "cluster1", UID: "uid-nginx", Labels: ownershipLabels(&j)},
+			Status:     workv1.ManifestWorkStatus{Conditions: []metav1.Condition{{Type: workv1.WorkAvailable, Status: metav1.ConditionTrue}}},
+		},
+		&workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{Name: "nginx-abcde-part-1", Namespace: "cluster1", Labels: map[string]string{PartOfLabel: "nginx-abcde", PartLabel: "1"}},
+			Status:     workv1.ManifestWorkStatus{Conditions: []metav1.Condition{{Type: workv1.WorkAvailable, Status: metav1.ConditionFalse, Message: "waiting"}}},
+		},
+		&workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "cluster1"}},
+		&workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "other-app", Namespace: "cluster3", Labels: map[string]string{
+			JobIDLabel: "1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d",
+		}}},
+	)
+	workClient.PrependReactor("list", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "cluster2" {
+			return true, nil, apierrors.NewServiceUnavailable("cluster2 is unreachable")
+		}
+		return false, nil, nil
+	})
+	useFakeClients(t, workClient, clusterClient)
+	t.Setenv("SYNC_CONCURRENCY", "2")
+
+	t.Run("should go on syncing after a cluster fails", func(t *testing.T) {
+		result, err := ResourceSync(nil)
+		assert.ErrorContains(t, err, "cluster2")
+		assert.Len(t, result.Resources, 1)
+		assert.Equal(t, "nginx-abcde", result.Resources[0].ResourceName)
+		assert.Equal(t, []string{"nginx-abcde-part-1"}, result.Resources[0].ResourceParts)
+		available := meta.FindStatusCondition(result.Resources[0].Conditions, workv1.WorkAvailable)
+		assert.Equal(t, metav1.ConditionFalse, available.Status, "the conditions of the parts are aggregated")
+		assert.Equal(t, "nginx-abcde-part-1: waiting", available.Message)
+		assert.Len(t, result.Unattributed, 1)
+		assert.Equal(t, "other-app", result.Unattributed[0].Name)
+
+		assert.Len(t, result.Clusters, 3)
+		assert.Equal(t, ClusterSync{ClusterName: "cluster1", Synced: 1, Skipped: 1}, result.Clusters[0])
+		assert.Equal(t, "cluster2", result.Clusters[1].ClusterName)
+		assert.Contains(t, result.Clusters[1].Error, "cluster2 is unreachable")
+		assert.Equal(t, ClusterSync{ClusterName: "cluster3", Failed: 1}, result.Clusters[2])
+		assert.Equal(t, result.Clusters, result.Summary().Clusters)
+	})
+
+	t.Run("should return an empty result when managed clusters cannot be listed", func(t *testing.T) {
+		clusterClient.PrependReactor("list", "managedclusters", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewServiceUnavailable("hub is unreachable")
+		})
+		result, err := ResourceSync(nil)
+		assert.Error(t, err)
+		assert.NotNil(t, result)
+		assert.Empty(t, result.Clusters)
+		assert.Empty(t, result.Resources)
+	})
+}
@@ -0,0 +1,428 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"icos/server/ocm-description-service/utils/logs"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// RevisionOfLabel links a revision ConfigMap to the primary ManifestWork of its resource
+	RevisionOfLabel = "deploymanager.icos.eu/revision-of"
+	// RevisionLabel holds the number of a revision
+	RevisionLabel = "deploymanager.icos.eu/revision"
+
+	// annotations of a revision ConfigMap describing the job that applied it
+	revisionJobAnnotation     = "deploymanager.icos.eu/job"
+	revisionJobTypeAnnotation = "deploymanager.icos.eu/job-type"
+	revisionSubTypeAnnotation = "deploymanager.icos.eu/sub-type"
+	revisionSourceAnnotation  = "deploymanager.icos.eu/rollback-of"
+	// the labels of the primary ManifestWork, as JSON, to recreate it from the revision
+	revisionLabelsAnnotation = "deploymanager.icos.eu/labels"
+
+	// the specs of the ManifestWorks of a revision, as gzipped JSON
+	revisionDataKey = "manifestworks.json.gz"
+
+	defaultRevisionHistoryLimit = 10
+	// how many revision numbers are tried when concurrent jobs record revisions of the same resource
+	revisionCreateAttempts = 5
+)
+
+// ErrRevisionNotFound is returned when a resource has no revision with the requested number.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// number of revisions kept per resource, the oldest ones are deleted first
+var revisionHistoryLimit = getRevisionHistoryLimit()
+
+func getRevisionHistoryLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("REVISION_HISTORY_LIMIT"))
+	if err != nil || limit <= 0 {
+		return defaultRevisionHistoryLimit
+	}
+	return limit
+}
+
+// Revision is a spec applied to a resource, kept in a ConfigMap of the cluster namespace on the hub.
+type Revision struct {
+	Revision     int             `json:"revision"`
+	ManifestWork string          `json:"manifest_work"`
+	JobID        string          `json:"job_id,omitempty"`
+	JobType      string          `json:"job_type,omitempty"`
+	SubType      RemediationType `json:"sub_type,omitempty"`
+	// RollbackOf is the revision restored by a rollback
+	RollbackOf int       `json:"rollback_of,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Works are the specs of the primary ManifestWork and its parts, in order. They are left out of
+	// revision lists.
+	Works []workv1.ManifestWorkSpec `json:"works,omitempty"`
+}
+
+func revisionName(primaryName string, revision int) string {
+	return fmt.Sprintf("%s-rev-%d", primaryName, revision)
+}
+
+// recordRevision keeps the specs the job left on the resource's ManifestWorks as a new revision, and
+// deletes the revisions above the history limit. Failing to record a revision does not fail the job.
+func recordRevision(j *Job, namespace, primaryName string) {
+	if clientset == nil {
+		return
+	}
+	if err := storeRevision(j, namespace, primaryName); err != nil {
+		logs.Logger.Printf("Error recording revision of ManifestWork %s/%s: %v", namespace, primaryName, err)
+	}
+}
+
+func storeRevision(j *Job, namespace, primaryName string) error {
+	primary, err := fetchManifestWork(namespace, primaryName, nil)
+	if err != nil {
+		return err
+	}
+	parts, err := fetchManifestWorkParts(namespace, primaryName)
+	if err != nil {
+		return err
+	}
+	specs := []workv1.ManifestWorkSpec{primary.Spec}
+	for _, part := range parts {
+		specs = append(specs, part.Spec)
+	}
+	data, err := encodeRevisionSpecs(specs)
+	if err != nil {
+		return err
+	}
+
+	annotations := map[string]string{
+		revisionJobAnnotation:     j.ID,
+		revisionJobTypeAnnotation: getJobTypeString(j.Type),
+	}
+	if j.Type == UpdateDeployment {
+		annotations[revisionSubTypeAnnotation] = string(j.SubType)
+	}
+	if j.SubType == Rollback && j.Remediation != nil {
+		annotations[revisionSourceAnnotation] = strconv.Itoa(j.Remediation.Revision)
+	}
+	workLabels, err := json.Marshal(primary.Labels)
+	if err != nil {
+		return fmt.Errorf("error encoding ManifestWork labels: %v", err)
+	}
+	annotations[revisionLabelsAnnotation] = string(workLabels)
+
+	// a concurrent job may take the next number first, so pick it again until the create succeeds
+	var revisions []corev1.ConfigMap
+	var configMap *corev1.ConfigMap
+	for attempt := 1; ; attempt++ {
+		revisions, err = listRevisionConfigMaps(namespace, primaryName)
+		if err != nil {
+			return err
+		}
+		number := 1
+		if len(revisions) > 0 {
+			number = revisionNumber(&revisions[len(revisions)-1]) + 1
+		}
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        revisionName(primaryName, number),
+				Namespace:   namespace,
+				Labels:      map[string]string{RevisionOfLabel: primaryName, RevisionLabel: strconv.Itoa(number)},
+				Annotations: annotations,
+			},
+			BinaryData: map[string][]byte{revisionDataKey: data},
+		}
+		_, err = clientset.CoreV1().ConfigMaps(namespace).Create(context.TODO(), configMap, metav1.CreateOptions{})
+		if err == nil {
+			logs.Logger.Printf("Recorded revision %d of ManifestWork %s/%s", number, namespace, primaryName)
+			break
+		}
+		if !apierrors.IsAlreadyExists(err) || attempt == revisionCreateAttempts {
+			return fmt.Errorf("error creating revision %d: %v", number, err)
+		}
+	}
+
+	revisions = append(revisions, *configMap)
+	for _, old := range revisions[:max(0, len(revisions)-revisionHistoryLimit)] {
+		err := clientset.CoreV1().ConfigMaps(namespace).Delete(context.TODO(), old.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting revision %s: %v", old.Name, err)
+		}
+	}
+	return nil
+}
+
+// deleteRevisions deletes the revision history of a deleted resource.
+func deleteRevisions(namespace, primaryName string) {
+	if clientset == nil {
+		return
+	}
+	revisions, err := listRevisionConfigMaps(namespace, primaryName)
+	if err != nil {
+		logs.Logger.Println("Error obtaining revisions:", err)
+		return
+	}
+	for _, revision := range revisions {
+		err := clientset.CoreV1().ConfigMaps(namespace).Delete(context.TODO(), revision.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			logs.Logger.Printf("Error deleting revision %s: %v", revision.Name, err)
+		}
+	}
+}
+
+// lastRevisionWorks rebuilds the ManifestWorks of the last revision of every resource of a cluster,
+// by name, with the spec hash the deploy manager stamped on them when it applied that revision.
+func lastRevisionWorks(namespace string) (map[string]*workv1.ManifestWork, error) {
+	works := map[string]*workv1.ManifestWork{}
+	if clientset == nil {
+		return works, nil
+	}
+	list, err := clientset.CoreV1().ConfigMaps(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: RevisionOfLabel})
+	if err != nil {
+		return works, fmt.Errorf("error listing revisions: %v", err)
+	}
+	last := map[string]*corev1.ConfigMap{}
+	for i := range list.Items {
+		configMap := &list.Items[i]
+		primaryName := configMap.Labels[RevisionOfLabel]
+		if current, ok := last[primaryName]; !ok || revisionNumber(configMap) > revisionNumber(current) {
+			last[primaryName] = configMap
+		}
+	}
+
+	for primaryName, configMap := range last {
+		specs, err := decodeRevisionSpecs(configMap.BinaryData[revisionDataKey])
+		if err != nil {
+			logs.Logger.Printf("Error decoding revision %s: %v", configMap.Name, err)
+			continue
+		}
+		primaryLabels := map[string]string{}
+		if encoded, ok := configMap.Annotations[revisionLabelsAnnotation]; ok {
+			if err := json.Unmarshal([]byte(encoded), &primaryLabels); err != nil {
+				logs.Logger.Printf("Error decoding labels of revision %s: %v", configMap.Name, err)
+			}
+		}
+		for i, spec := range specs {
+			work := &workv1.ManifestWork{
+				TypeMeta:   metav1.TypeMeta{Kind: "ManifestWork", APIVersion: workv1.GroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: primaryName, Namespace: namespace, Labels: primaryLabels},
+				Spec:       spec,
+			}
+			if i > 0 {
+				work.Name = manifestWorkPartName(primaryName, i)
+				work.Labels = map[string]string{PartOfLabel: primaryName, PartLabel: strconv.Itoa(i)}
+			}
+			if err := stampSpecHash(work); err != nil {
+				return works, err
+			}
+			works[work.Name] = work
+		}
+	}
+	return works, nil
+}
+
+// ListRevisions lists the revisions of a resource, oldest first, without their specs.
+func ListRevisions(namespace, primaryName string) ([]Revision, error) {
+	if clientset == nil {
+		return nil, fmt.Errorf("no Kubernetes client configured")
+	}
+	configMaps, err := listRevisionConfigMaps(namespace, primaryName)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]Revision, 0, len(configMaps))
+	for i := range configMaps {
+		revisions = append(revisions, revisionFromConfigMap(&configMaps[i]))
+	}
+	return revisions, nil
+}
+
+// GetRevision returns a revision of a resource with its specs.
+func GetRevision(namespace, primaryName string, number int) (*Revision, error) {
+	if clientset == nil {
+		return nil, fmt.Errorf("no Kubernetes client configured")
+	}
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), revisionName(primaryName, number), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && configMap.Labels[RevisionOfLabel] != primaryName) {
+		return nil, fmt.Errorf("%w: %s has no revision %d", ErrRevisionNotFound, primaryName, number)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining revision %d of %s: %v", number, primaryName, err)
+	}
+	revision := revisionFromConfigMap(configMap)
+	revision.Works, err = decodeRevisionSpecs(configMap.BinaryData[revisionDataKey])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding revision %d of %s: %v", number, primaryName, err)
+	}
+	return &revision, nil
+}
+
+// rollbackDeployment applies a previous revision of the job's resource, recording the fields it changes
+// and the rollback itself as a new revision.
+func rollbackDeployment(j *Job) (*Job, error) {
+	if j.Remediation == nil || j.Remediation.Revision <= 0 {
+		logErrorAndSetJobState("Invalid remediation parameters: remediation.revision is required", j, Degraded)
+		return nil, fmt.Errorf("remediation.revision is required")
+	}
+	j.Changes = nil
+
+	revision, err := GetRevision(j.Target.ClusterName, j.Resource.ResourceName, j.Remediation.Revision)
+	if err != nil {
+		logErrorAndSetJobState("Error obtaining revision", j, Degraded)
+		return nil, err
+	}
+	if len(revision.Works) == 0 {
+		logErrorAndSetJobState("Revision has no ManifestWork", j, Degraded)
+		return nil, fmt.Errorf("revision %d of %s has no ManifestWork", revision.Revision, revision.ManifestWork)
+	}
+
+	manifestWork, err := fetchManifestWork(j.Target.ClusterName, j.Resource.ResourceName, nil)
+	if err != nil {
+		logErrorAndSetJobState("Error obtaining applied ManifestWork status", j, Degraded)
+		return nil, err
+	}
+	oldParts, err := fetchManifestWorkParts(j.Target.ClusterName, manifestWork.Name)
+	if err != nil {
+		logErrorAndSetJobState("Error obtaining ManifestWork parts", j, Degraded)
+		return nil, err
+	}
+	live := append([]workv1.Manifest{}, manifestWork.Spec.Workload.Manifests...)
+	for _, part := range oldParts {
+		live = append(live, part.Spec.Workload.Manifests...)
+	}
+	restored := []workv1.Manifest{}
+	for _, spec := range revision.Works {
+		restored = append(restored, spec.Workload.Manifests...)
+	}
+	if diff, err := diffManifests(live, restored); err != nil {
+		logs.Logger.Println("Error comparing revision with the applied manifests:", err)
+	} else {
+		j.Changes = diff.Fields
+	}
+
+	manifestWork.Spec = revision.Works[0]
+	setOwnershipLabels(manifestWork, j)
+	updatedManifestWork, err := appliedWorks.update(manifestWork)
+	if err != nil {
+		logErrorAndSetJobState("Error updating ManifestWork", j, Degraded)
+		return nil, err
+	}
+
+	parts := make([]*workv1.ManifestWork, 0, len(revision.Works)-1)
+	for _, spec := range revision.Works[1:] {
+		part := &workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Namespace: j.Target.ClusterName}, Spec: spec}
+		parts = append(parts, part)
+	}
+	partNames, err := applyManifestWorkParts(j.Target.ClusterName, updatedManifestWork.Name, parts)
+	if err != nil {
+		logErrorAndSetJobState("Error updating ManifestWork parts", j, Degraded)
+		return nil, err
+	}
+	recordRevision(j, j.Target.ClusterName, updatedManifestWork.Name)
+
+	appliedParts, err := fetchManifestWorkParts(j.Target.ClusterName, updatedManifestWork.Name)
+	if err != nil {
+		logs.Logger.Println("Error obtaining ManifestWork parts status:", err)
+	}
+	aggregateManifestWorkStatus(updatedManifestWork, appliedParts)
+	j.UpdateJobResource(updatedManifestWork)
+	j.Resource.ResourceParts = partNames
+	return j, nil
+}
+
+// listRevisionConfigMaps lists the revision ConfigMaps of a resource, ordered by revision number.
+func listRevisionConfigMaps(namespace, primaryName string) ([]corev1.ConfigMap, error) {
+	list, err := clientset.CoreV1().ConfigMaps(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: RevisionOfLabel + "=" + primaryName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing revisions of ManifestWork %s: %v", primaryName, err)
+	}
+	revisions := list.Items
+	sort.Slice(revisions, func(a, b int) bool {
+		return revisionNumber(&revisions[a]) < revisionNumber(&revisions[b])
+	})
+	return revisions, nil
+}
+
+func revisionNumber(configMap *corev1.ConfigMap) int {
+	number, _ := strconv.Atoi(configMap.Labels[RevisionLabel])
+	return number
+}
+
+func revisionFromConfigMap(configMap *corev1.ConfigMap) Revision {
+	rollbackOf, _ := strconv.Atoi(configMap.Annotations[revisionSourceAnnotation])
+	return Revision{
+		Revision:     revisionNumber(configMap),
+		ManifestWork: configMap.Labels[RevisionOfLabel],
+		JobID:        configMap.Annotations[revisionJobAnnotation],
+		JobType:      configMap.Annotations[revisionJobTypeAnnotation],
+		SubType:      RemediationType(configMap.Annotations[revisionSubTypeAnnotation]),
+		RollbackOf:   rollbackOf,
+		CreatedAt:    configMap.CreationTimestamp.Time,
+	}
+}
+
+// encodeRevisionSpecs gzips the JSON encoding of the specs, which keeps revisions of split resources
+// within the size limit of a ConfigMap.
+func encodeRevisionSpecs(specs []workv1.ManifestWorkSpec) ([]byte, error) {
+	encoded, err := json.Marshal(specs)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding ManifestWork specs: %v", err)
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(encoded); err != nil {
+		return nil, fmt.Errorf("error compressing ManifestWork specs: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing ManifestWork specs: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeRevisionSpecs(data []byte) ([]workv1.ManifestWorkSpec, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	encoded, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var specs []workv1.ManifestWorkSpec
+	if err := json.Unmarshal(encoded, &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
@@ -0,0 +1,160 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestRevisionHistory(t *testing.T) {
+	workClient := newFakeWorkClient()
+	useFakeClients(t, workClient, clusterfake.NewSimpleClientset(&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}))
+	useFakeKubeClient(t, k8sfake.NewSimpleClientset())
+	t.Setenv("MANIFEST_VALIDATION", "false")
+	restoreAfterTest(t, &revisionHistoryLimit)
+
+	j := MockCreateDeploymentJob()
+	created, err := createManifestWork(&j)
+	assert.NoError(t, err)
+	liveReplicas := func() interface{} {
+		live, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		objects, err := indexManifests(live.Spec.Workload.Manifests)
+		assert.NoError(t, err)
+		deployment := objects["Deployment.apps/icos-test/nginx"].(map[string]interface{})
+		return deployment["spec"].(map[string]interface{})["replicas"]
+	}
+	update := func(subType RemediationType, params *RemediationParams) (*Job, error) {
+		job := MockUpdateJob(subType)
+		job.Resource.ResourceName = created.Name
+		job.Remediation = params
+		_, err := updateDeployment(&job)
+		return &job, err
+	}
+	revisionNumbers := func() []int {
+		revisions, err := ListRevisions("cluster1", created.Name)
+		assert.NoError(t, err)
+		numbers := []int{}
+		for _, revision := range revisions {
+			numbers = append(numbers, revision.Revision)
+		}
+		return numbers
+	}
+
+	t.Run("should record a revision for every applied spec", func(t *testing.T) {
+		_, err := update(ScaleUp, nil)
+		assert.NoError(t, err)
+
+		revisions, err := ListRevisions("cluster1", created.Name)
+		assert.NoError(t, err)
+		if !assert.Len(t, revisions, 2) {
+			return
+		}
+		assert.Equal(t, Revision{Revision: 1, ManifestWork: created.Name, JobID: j.ID, JobType: "CreateDeployment"}, revisions[0])
+		assert.Equal(t, "UpdateDeployment", revisions[1].JobType)
+		assert.Equal(t, ScaleUp, revisions[1].SubType)
+		assert.Nil(t, revisions[1].Works)
+
+		first, err := GetRevision("cluster1", created.Name, 1)
+		assert.NoError(t, err)
+		if assert.Len(t, first.Works, 1) {
+			objects, err := indexManifests(first.Works[0].Workload.Manifests)
+			assert.NoError(t, err)
+			assert.Contains(t, objects, "Service/icos-test/nginx")
+		}
+		assert.Equal(t, float64(2), liveReplicas())
+	})
+
+	t.Run("should roll back to a previous revision as a new one", func(t *testing.T) {
+		rollback, err := update(Rollback, &RemediationParams{Revision: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, float64(1), liveReplicas())
+		assert.Equal(t, []FieldChange{{Kind: "Deployment", Name: "nginx", Field: "spec.replicas", Old: "2", New: "1"}}, rollback.Changes)
+		assert.Equal(t, created.Name, rollback.Resource.ResourceName)
+
+		latest, err := GetRevision("cluster1", created.Name, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, Rollback, latest.SubType)
+		assert.Equal(t, 1, latest.RollbackOf)
+	})
+
+	t.Run("should fail to roll back to a missing revision", func(t *testing.T) {
+		_, err := GetRevision("cluster1", created.Name, 42)
+		assert.ErrorIs(t, err, ErrRevisionNotFound)
+		_, err = update(Rollback, &RemediationParams{Revision: 42})
+		assert.ErrorIs(t, err, ErrRevisionNotFound)
+		_, err = update(Rollback, nil)
+		assert.ErrorContains(t, err, "remediation.revision is required")
+	})
+
+	t.Run("should take the next number when a concurrent job recorded a revision first", func(t *testing.T) {
+		kubeClient := clientset.(*k8sfake.Clientset)
+		raced := false
+		kubeClient.PrependReactor("create", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if raced {
+				return false, nil, nil
+			}
+			raced = true
+			// another job records the same number between the list and the create
+			concurrent := action.(clienttesting.CreateAction).GetObject().(*corev1.ConfigMap).DeepCopy()
+			concurrent.Annotations = map[string]string{revisionJobAnnotation: "concurrent"}
+			assert.NoError(t, kubeClient.Tracker().Add(concurrent))
+			return true, nil, apierrors.NewAlreadyExists(corev1.Resource("configmaps"), concurrent.Name)
+		})
+		_, err := update(ScaleUp, nil)
+		assert.NoError(t, err)
+		assert.True(t, raced)
+		assert.Equal(t, []int{1, 2, 3, 4, 5}, revisionNumbers())
+
+		revisions, err := ListRevisions("cluster1", created.Name)
+		assert.NoError(t, err)
+		assert.Equal(t, "concurrent", revisions[3].JobID)
+		assert.Equal(t, j.ID, revisions[4].JobID)
+	})
+
+	t.Run("should keep the most recent revisions only", func(t *testing.T) {
+		revisionHistoryLimit = 2
+		_, err := update(ScaleUp, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{5, 6}, revisionNumbers())
+	})
+
+	t.Run("should delete the history with the resource", func(t *testing.T) {
+		deletion := MockUpdateJob(Reallocation)
+		deletion.Resource.ResourceName = created.Name
+		_, err := updateDeployment(&deletion)
+		assert.NoError(t, err)
+		assert.Empty(t, revisionNumbers())
+	})
+}
@@ -0,0 +1,333 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	yamlEncode "sigs.k8s.io/yaml"
+)
+
+const (
+	// MinReplicasAnnotation and MaxReplicasAnnotation bound the replica count of a workload, taking
+	// precedence over MIN_REPLICAS and MAX_REPLICAS
+	MinReplicasAnnotation = "app.icos.eu/min-replicas"
+	MaxReplicasAnnotation = "app.icos.eu/max-replicas"
+)
+
+// ScalableKind makes a custom kind scalable by ScaleUp and ScaleDown. ReplicasPath is the JSONPath of
+// its replica count, made of field names only, e.g. .spec.instances.
+type ScalableKind struct {
+	Group        string `json:"group"`
+	Kind         string `json:"kind"`
+	ReplicasPath string `json:"replicas_path"`
+}
+
+// VerticalScaling configures the ScaleOut and ScaleIn remediations, read as YAML or JSON from the
+// VERTICAL_SCALING environment variable. Container requests change by CPUStep and MemoryStep or, when
+// Percent is set, by a percentage of their current value, and stay within Min and Max.
+type VerticalScaling struct {
+	CPUStep    resource.Quantity   `json:"cpu_step"`
+	MemoryStep resource.Quantity   `json:"memory_step"`
+	Percent    int64               `json:"percent,omitempty"`
+	Min        corev1.ResourceList `json:"min,omitempty"`
+	Max        corev1.ResourceList `json:"max,omitempty"`
+
+	// requests to scale to, set by the job
+	targets corev1.ResourceList
+}
+
+// replicaBounds are the min and max replica counts of a workload, max being negative when unbounded.
+type replicaBounds struct {
+	min, max int32
+}
+
+var (
+	verticalScaling, verticalScalingErr = loadVerticalScaling(os.Getenv("VERTICAL_SCALING"))
+	// replica count fields of the custom kinds configured in SCALABLE_KINDS
+	scalableKinds, scalableKindsErr = loadScalableKinds(os.Getenv("SCALABLE_KINDS"))
+	// replica bounds of the workloads without bounds annotations
+	defaultReplicaBounds, defaultReplicaBoundsErr = loadReplicaBounds(os.Getenv("MIN_REPLICAS"), os.Getenv("MAX_REPLICAS"))
+)
+
+func loadReplicaBounds(min, max string) (replicaBounds, error) {
+	bounds := replicaBounds{min: 0, max: -1}
+	var err error
+	if min != "" {
+		if bounds.min, err = parseReplicas(min); err != nil {
+			return bounds, fmt.Errorf("error parsing min replicas: %v", err)
+		}
+	}
+	if max != "" {
+		if bounds.max, err = parseReplicas(max); err != nil {
+			return bounds, fmt.Errorf("error parsing max replicas: %v", err)
+		}
+		if bounds.max < bounds.min {
+			return bounds, fmt.Errorf("max replicas %d is below min replicas %d", bounds.max, bounds.min)
+		}
+	}
+	return bounds, nil
+}
+
+func parseReplicas(value string) (int32, error) {
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if replicas < 0 {
+		return 0, fmt.Errorf("%d is negative", replicas)
+	}
+	return int32(replicas), nil
+}
+
+// workloadReplicaBounds returns the replica bounds of a workload, from its annotations or the configuration.
+func workloadReplicaBounds(obj runtime.Object) (replicaBounds, error) {
+	if defaultReplicaBoundsErr != nil {
+		return replicaBounds{}, defaultReplicaBoundsErr
+	}
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return replicaBounds{}, fmt.Errorf("error accessing object metadata: %v", err)
+	}
+	annotations := metaObj.GetAnnotations()
+	min, hasMin := annotations[MinReplicasAnnotation]
+	max, hasMax := annotations[MaxReplicasAnnotation]
+	if !hasMin && !hasMax {
+		return defaultReplicaBounds, nil
+	}
+	if !hasMin {
+		min = strconv.Itoa(int(defaultReplicaBounds.min))
+	}
+	if !hasMax && defaultReplicaBounds.max >= 0 {
+		max = strconv.Itoa(int(defaultReplicaBounds.max))
+	}
+	bounds, err := loadReplicaBounds(min, max)
+	if err != nil {
+		return replicaBounds{}, fmt.Errorf("%s %s: %v", obj.GetObjectKind().GroupVersionKind().Kind, metaObj.GetName(), err)
+	}
+	return bounds, nil
+}
+
+func (b replicaBounds) clamp(replicas int32) int32 {
+	if replicas < b.min {
+		return b.min
+	}
+	if b.max >= 0 && replicas > b.max {
+		return b.max
+	}
+	return replicas
+}
+
+func loadScalableKinds(config string) (map[schema.GroupKind][]string, error) {
+	kinds := map[schema.GroupKind][]string{}
+	if config == "" {
+		return kinds, nil
+	}
+	configured := []ScalableKind{}
+	if err := yamlEncode.UnmarshalStrict([]byte(config), &configured); err != nil {
+		return nil, fmt.Errorf("error parsing SCALABLE_KINDS: %v", err)
+	}
+	for i, kind := range configured {
+		path := strings.TrimSuffix(strings.TrimPrefix(kind.ReplicasPath, "{"), "}")
+		fields := strings.Split(strings.TrimPrefix(path, "."), ".")
+		for _, field := range fields {
+			if field == "" || strings.ContainsAny(field, "[]*@?()'\"") {
+				return nil, fmt.Errorf("error parsing SCALABLE_KINDS: kind %d: replicas_path %q is not a path of field names", i, kind.ReplicasPath)
+			}
+		}
+		if kind.Kind == "" {
+			return nil, fmt.Errorf("error parsing SCALABLE_KINDS: kind %d: kind is required", i)
+		}
+		kinds[schema.GroupKind{Group: kind.Group, Kind: kind.Kind}] = fields
+	}
+	return kinds, nil
+}
+
+// replicasField returns the replica count field of the built-in scalable kinds, or nil.
+func replicasField(obj runtime.Object) **int32 {
+	switch workload := obj.(type) {
+	case *appsv1.Deployment:
+		return &workload.Spec.Replicas
+	case *appsv1.StatefulSet:
+		return &workload.Spec.Replicas
+	case *appsv1.ReplicaSet:
+		return &workload.Spec.Replicas
+	}
+	return nil
+}
+
+// getReplicas returns the replica count of a scalable object and the path of its field, ok is false
+// for objects that are not scalable.
+func getReplicas(obj runtime.Object) (replicas int32, field string, ok bool, err error) {
+	if replicasField := replicasField(obj); replicasField != nil {
+		// defaulted to 1 by the API server
+		if *replicasField == nil {
+			return 1, "spec.replicas", true, nil
+		}
+		return **replicasField, "spec.replicas", true, nil
+	}
+
+	custom, isUnstructured := obj.(*unstructured.Unstructured)
+	if !isUnstructured {
+		return 0, "", false, nil
+	}
+	fields, ok := scalableKinds[custom.GroupVersionKind().GroupKind()]
+	if !ok {
+		return 0, "", false, nil
+	}
+	field = strings.Join(fields, ".")
+	value, found, err := unstructured.NestedInt64(custom.Object, fields...)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("error reading %s of %s %s: %v", field, custom.GetKind(), custom.GetName(), err)
+	}
+	if !found {
+		return 0, "", false, fmt.Errorf("%s %s has no %s", custom.GetKind(), custom.GetName(), field)
+	}
+	if value < 0 || value > math.MaxInt32 {
+		return 0, "", false, fmt.Errorf("%s of %s %s is out of range: %d", field, custom.GetKind(), custom.GetName(), value)
+	}
+	return int32(value), field, true, nil
+}
+
+// setReplicas sets the replica count of an object getReplicas reported as scalable.
+func setReplicas(obj runtime.Object, replicas int32) error {
+	if replicasField := replicasField(obj); replicasField != nil {
+		*replicasField = &replicas
+		return nil
+	}
+	custom := obj.(*unstructured.Unstructured)
+	return unstructured.SetNestedField(custom.Object, int64(replicas), scalableKinds[custom.GroupVersionKind().GroupKind()]...)
+}
+
+func loadVerticalScaling(config string) (*VerticalScaling, error) {
+	scaling := &VerticalScaling{
+		CPUStep:    resource.MustParse("1000m"),
+		MemoryStep: resource.MustParse("1000Mi"),
+	}
+	if config == "" {
+		return scaling, nil
+	}
+	if err := yamlEncode.UnmarshalStrict([]byte(config), scaling); err != nil {
+		return nil, fmt.Errorf("error parsing VERTICAL_SCALING: %v", err)
+	}
+	if scaling.Percent < 0 || scaling.CPUStep.Sign() < 0 || scaling.MemoryStep.Sign() < 0 {
+		return nil, fmt.Errorf("error parsing VERTICAL_SCALING: steps and percent cannot be negative")
+	}
+	for name, min := range scaling.Min {
+		if max, ok := scaling.Max[name]; ok && min.Cmp(max) > 0 {
+			return nil, fmt.Errorf("error parsing VERTICAL_SCALING: min %s is above max", name)
+		}
+	}
+	return scaling, nil
+}
+
+// forJob returns the settings to use for a job, whose steps or percent replace the configured ones.
+// The bounds always come from the configuration.
+func (s VerticalScaling) forJob(params *RemediationParams) VerticalScaling {
+	if params == nil {
+		return s
+	}
+	if params.Percent > 0 {
+		s.Percent = params.Percent
+	} else if params.CPUStep != nil || params.MemoryStep != nil {
+		s.Percent = 0
+	}
+	if params.CPUStep != nil {
+		s.CPUStep = params.CPUStep.DeepCopy()
+	}
+	if params.MemoryStep != nil {
+		s.MemoryStep = params.MemoryStep.DeepCopy()
+	}
+	s.targets = params.Resources
+	return s
+}
+
+// request returns the new request of a resource: the one the job scales to, or the current one
+// adjusted by a step or percentage. It returns false when the request does not change, and an error
+// when the target of the job goes the other way than the job.
+func (s VerticalScaling) request(name corev1.ResourceName, current resource.Quantity, requested, decrease bool) (resource.Quantity, bool, error) {
+	if target, ok := s.targets[name]; ok {
+		if requested && ((decrease && target.Cmp(current) > 0) || (!decrease && target.Cmp(current) < 0)) {
+			return resource.Quantity{}, false, fmt.Errorf("cannot change the %s request from %s to %s", name, current.String(), target.String())
+		}
+		return target.DeepCopy(), true, nil
+	}
+	if decrease && !requested {
+		return resource.Quantity{}, false, nil
+	}
+
+	adjustment, ok := s.adjustment(name, current, requested)
+	if !ok {
+		return resource.Quantity{}, false, nil
+	}
+	if decrease {
+		adjustment.Neg()
+	}
+	newQuantity := *resource.NewQuantity(0, adjustment.Format)
+	if requested {
+		newQuantity = current.DeepCopy()
+	}
+	newQuantity.Add(adjustment)
+	return newQuantity, true, nil
+}
+
+// adjustment returns how much the request of a resource changes, or false when it cannot.
+func (s VerticalScaling) adjustment(name corev1.ResourceName, current resource.Quantity, requested bool) (resource.Quantity, bool) {
+	if s.Percent == 0 {
+		switch name {
+		case corev1.ResourceCPU:
+			return s.CPUStep.DeepCopy(), !s.CPUStep.IsZero()
+		case corev1.ResourceMemory:
+			return s.MemoryStep.DeepCopy(), !s.MemoryStep.IsZero()
+		}
+		return resource.Quantity{}, false
+	}
+	// a percentage of nothing is nothing
+	if !requested {
+		return resource.Quantity{}, false
+	}
+	if name == corev1.ResourceCPU {
+		return *resource.NewMilliQuantity(current.MilliValue()*s.Percent/100, current.Format), true
+	}
+	return *resource.NewQuantity(current.Value()*s.Percent/100, current.Format), true
+}
+
+// clamp bounds a request by the configured min and max.
+func (s VerticalScaling) clamp(name corev1.ResourceName, quantity resource.Quantity) resource.Quantity {
+	if min, ok := s.Min[name]; ok && quantity.Cmp(min) < 0 {
+		return min.DeepCopy()
+	}
+	if max, ok := s.Max[name]; ok && quantity.Cmp(max) > 0 {
+		return max.DeepCopy()
+	}
+	return quantity
+}
@@ -0,0 +1,395 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestVerticalScaling(t *testing.T) {
+	restoreAfterTest(t, &verticalScaling)
+	restoreAfterTest(t, &verticalScalingErr)
+	deploymentYaml := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.25
+        resources:
+          requests:
+            cpu: 500m
+            memory: 256Mi
+          limits:
+            cpu: "1"
+            memory: 256Mi
+      - name: sidecar
+        image: envoy:1.30
+      - name: cache
+        image: redis:7
+        resources:
+          limits:
+            memory: 1Gi`
+	scale := func(t *testing.T, subType RemediationType, params *RemediationParams) (*appsv1.Deployment, []FieldChange) {
+		obj, err := decodeYAMLToObject(deploymentYaml)
+		assert.NoError(t, err)
+		j := MockUpdateJob(subType)
+		j.Remediation = params
+		manifest, changes, err := updateResourceRequirements(obj, &j)
+		assert.NoError(t, err)
+		return manifest.Object.(*appsv1.Deployment), changes
+	}
+	resources := func(deployment *appsv1.Deployment, container int) corev1.ResourceRequirements {
+		return deployment.Spec.Template.Spec.Containers[container].Resources
+	}
+
+	t.Run("should scale every container by the configured steps and keep limits consistent", func(t *testing.T) {
+		verticalScaling, verticalScalingErr = loadVerticalScaling(`{cpu_step: 250m, memory_step: 128Mi}`)
+		assert.NoError(t, verticalScalingErr)
+
+		deployment, changes := scale(t, ScaleOut, nil)
+		app := resources(deployment, 0)
+		assert.Equal(t, "750m", app.Requests.Cpu().String())
+		assert.Equal(t, "384Mi", app.Requests.Memory().String())
+		assert.Equal(t, "1250m", app.Limits.Cpu().String())
+		assert.Equal(t, "384Mi", app.Limits.Memory().String())
+		sidecar := resources(deployment, 1)
+		assert.Equal(t, "250m", sidecar.Requests.Cpu().String(), "containers without requests get one")
+		assert.Nil(t, sidecar.Limits)
+		cache := resources(deployment, 2)
+		assert.Equal(t, "1152Mi", cache.Requests.Memory().String(), "a missing request defaults to the limit")
+		assert.Equal(t, "1152Mi", cache.Limits.Memory().String())
+		assert.Contains(t, changes, FieldChange{Kind: "Deployment", Name: "web", Field: "containers[app].resources.limits.cpu", Old: "1", New: "1250m"})
+		assert.Len(t, changes, 9)
+
+		deployment, _ = scale(t, ScaleIn, nil)
+		app = resources(deployment, 0)
+		assert.Equal(t, "250m", app.Requests.Cpu().String())
+		assert.Equal(t, "128Mi", app.Requests.Memory().String())
+		assert.Equal(t, "128Mi", app.Limits.Memory().String(), "limits follow requests down")
+		assert.Empty(t, resources(deployment, 1).Requests, "nothing to scale in")
+	})
+
+	t.Run("should only scale the named containers by the job's percentage", func(t *testing.T) {
+		verticalScaling, verticalScalingErr = loadVerticalScaling("")
+
+		deployment, changes := scale(t, ScaleOut, &RemediationParams{Containers: []string{"app"}, Percent: 50})
+		app := resources(deployment, 0)
+		assert.Equal(t, "750m", app.Requests.Cpu().String())
+		assert.Equal(t, "384Mi", app.Requests.Memory().String())
+		assert.Empty(t, resources(deployment, 1).Requests)
+		cache := resources(deployment, 2)
+		assert.Equal(t, "1Gi", cache.Limits.Memory().String())
+		assert.Len(t, changes, 4)
+	})
+
+	t.Run("should keep requests within the configured bounds", func(t *testing.T) {
+		verticalScaling, verticalScalingErr = loadVerticalScaling(`
+cpu_step: "1"
+memory_step: 1Gi
+min: {cpu: 200m, memory: 128Mi}
+max: {cpu: "1", memory: 1Gi}`)
+		assert.NoError(t, verticalScalingErr)
+
+		deployment, _ := scale(t, ScaleOut, nil)
+		app := resources(deployment, 0)
+		assert.Equal(t, "1", app.Requests.Cpu().String())
+		assert.Equal(t, "1Gi", app.Requests.Memory().String())
+		cache := resources(deployment, 2)
+		assert.Equal(t, "1Gi", cache.Requests.Memory().String())
+
+		deployment, _ = scale(t, ScaleIn, nil)
+		app = resources(deployment, 0)
+		assert.Equal(t, "200m", app.Requests.Cpu().String())
+		assert.Equal(t, "128Mi", app.Requests.Memory().String())
+		assert.Equal(t, "700m", app.Limits.Cpu().String())
+	})
+
+	t.Run("should reject invalid settings", func(t *testing.T) {
+		_, err := loadVerticalScaling(`{min: {cpu: "2"}, max: {cpu: "1"}}`)
+		assert.ErrorContains(t, err, "min cpu is above max")
+		_, err = loadVerticalScaling(`{percent: -10}`)
+		assert.Error(t, err)
+		step := resource.MustParse("-1")
+		assert.Error(t, (&RemediationParams{CPUStep: &step}).validate())
+	})
+}
+
+func TestHorizontalScaling(t *testing.T) {
+	workClient := newFakeWorkClient()
+	useFakeClients(t, workClient, clusterfake.NewSimpleClientset())
+	t.Setenv("MANIFEST_VALIDATION", "false")
+	restoreAfterTest(t, &scalableKinds)
+	restoreAfterTest(t, &scalableKindsErr)
+	scalableKinds, scalableKindsErr = loadScalableKinds(`[{group: example.com, kind: Database, replicas_path: "{.spec.instances}"}]`)
+	assert.NoError(t, scalableKindsErr)
+
+	j := MockCreateDeploymentJob()
+	j.Manifests = append(j.Manifests, PlainManifest{YamlString: `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  serviceName: db
+  selector:
+    matchLabels: {app: db}
+  template:
+    metadata:
+      labels: {app: db}
+    spec:
+      containers:
+      - name: db
+        image: postgres:16
+---
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: worker
+spec:
+  replicas: 2
+  selector:
+    matchLabels: {app: worker}
+  template:
+    metadata:
+      labels: {app: worker}
+    spec:
+      containers:
+      - name: worker
+        image: busybox:1.36
+---
+apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: cluster
+spec:
+  instances: 3
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  key: value`})
+	created, err := createManifestWork(&j)
+	assert.NoError(t, err)
+	manifestCount := len(created.Spec.Workload.Manifests)
+
+	t.Run("should scale every scalable kind and keep the other manifests", func(t *testing.T) {
+		update := MockUpdateJob(ScaleUp)
+		update.Resource.ResourceName = created.Name
+		_, err := updateDeploymentAttributes(&update)
+		assert.NoError(t, err)
+
+		live, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, live.Spec.Workload.Manifests, manifestCount)
+		assert.Equal(t, created.Spec.Workload.Manifests[0], live.Spec.Workload.Manifests[0], "the namespace is kept as it is")
+
+		kinds := []string{}
+		for _, manifest := range live.Spec.Workload.Manifests {
+			kinds = append(kinds, manifest.Object.GetObjectKind().GroupVersionKind().Kind)
+		}
+		assert.Equal(t, []string{"Namespace", "Deployment", "Service", "StatefulSet", "ReplicaSet", "Database", "ConfigMap"}, kinds)
+
+		change := func(kind, name, field, old, new string) FieldChange {
+			return FieldChange{Kind: kind, Name: name, Field: field, Old: old, New: new}
+		}
+		assert.Equal(t, []FieldChange{
+			change("Deployment", "nginx", "spec.replicas", "1", "2"),
+			change("StatefulSet", "db", "spec.replicas", "1", "2"),
+			change("ReplicaSet", "worker", "spec.replicas", "2", "3"),
+			change("Database", "cluster", "spec.instances", "3", "4"),
+		}, update.Changes)
+		database := live.Spec.Workload.Manifests[5].Object.(*unstructured.Unstructured)
+		instances, _, _ := unstructured.NestedInt64(database.Object, "spec", "instances")
+		assert.Equal(t, int64(4), instances)
+	})
+
+	t.Run("should fail when a custom kind has no replica count", func(t *testing.T) {
+		obj, err := decodeYAMLToObject("apiVersion: example.com/v1\nkind: Database\nmetadata:\n  name: empty\nspec: {}")
+		assert.NoError(t, err)
+		_, _, err = updateReplicaCount(obj, &Job{SubType: ScaleUp})
+		assert.ErrorContains(t, err, "Database empty has no spec.instances")
+	})
+
+	t.Run("should fail when the replica count of a custom kind is out of range", func(t *testing.T) {
+		obj, err := decodeYAMLToObject("apiVersion: example.com/v1\nkind: Database\nmetadata:\n  name: huge\nspec:\n  instances: 4294967296")
+		assert.NoError(t, err)
+		_, _, err = updateReplicaCount(obj, &Job{SubType: ScaleUp})
+		assert.ErrorContains(t, err, "spec.instances of Database huge is out of range: 4294967296")
+	})
+
+	t.Run("should report custom kinds as unvalidated like any custom resource", func(t *testing.T) {
+		report, err := ValidateJob(&j, "")
+		assert.NoError(t, err)
+		assert.Empty(t, report.Errors)
+		if assert.Len(t, report.Unvalidated, 1) {
+			assert.Equal(t, "Database", report.Unvalidated[0].Kind)
+		}
+	})
+
+	t.Run("should reject invalid replica paths", func(t *testing.T) {
+		_, err := loadScalableKinds(`[{group: example.com, kind: Database, replicas_path: ".spec.members[0].count"}]`)
+		assert.ErrorContains(t, err, "is not a path of field names")
+		_, err = loadScalableKinds(`[{group: example.com, replicas_path: ".spec.instances"}]`)
+		assert.ErrorContains(t, err, "kind is required")
+	})
+}
+
+func TestScalingTargets(t *testing.T) {
+	restoreAfterTest(t, &defaultReplicaBounds)
+	restoreAfterTest(t, &defaultReplicaBoundsErr)
+	defaultReplicaBounds, defaultReplicaBoundsErr = loadReplicaBounds("", "")
+	replicas := func(n int32) *int32 { return &n }
+	scale := func(t *testing.T, annotations string, subType RemediationType, params *RemediationParams) (int32, error) {
+		yamlString := strings.Replace(mockDeploymentYaml, "metadata:\n  name: nginx", "metadata:\n  name: nginx\n  annotations:"+annotations, 1)
+		if annotations == "" {
+			yamlString = mockDeploymentYaml
+		}
+		obj, err := decodeYAMLToObject(yamlString)
+		assert.NoError(t, err)
+		j := MockUpdateJob(subType)
+		j.Remediation = params
+		manifest, _, err := updateReplicaCount(obj, &j)
+		if err != nil {
+			return 0, err
+		}
+		return *manifest.Object.(*appsv1.Deployment).Spec.Replicas, nil
+	}
+
+	t.Run("should scale to the replica count of the job", func(t *testing.T) {
+		count, err := scale(t, "", ScaleUp, &RemediationParams{Replicas: replicas(5)})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(5), count)
+	})
+
+	t.Run("should never scale below zero replicas", func(t *testing.T) {
+		count, err := scale(t, "", ScaleDown, &RemediationParams{Replicas: replicas(0)})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(0), count)
+
+		obj, err := decodeYAMLToObject(strings.Replace(mockDeploymentYaml, "replicas: 1", "replicas: 0", 1))
+		assert.NoError(t, err)
+		j := MockUpdateJob(ScaleDown)
+		manifest, _, err := updateReplicaCount(obj, &j)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(0), *manifest.Object.(*appsv1.Deployment).Spec.Replicas)
+	})
+
+	t.Run("should clamp to the bounds of the app annotations, then of the configuration", func(t *testing.T) {
+		count, err := scale(t, "\n    app.icos.eu/max-replicas: \"3\"", ScaleUp, &RemediationParams{Replicas: replicas(5)})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(3), count)
+
+		defaultReplicaBounds, defaultReplicaBoundsErr = loadReplicaBounds("2", "4")
+		assert.NoError(t, defaultReplicaBoundsErr)
+		count, err = scale(t, "", ScaleUp, &RemediationParams{Replicas: replicas(10)})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(4), count)
+		count, err = scale(t, "\n    app.icos.eu/min-replicas: \"1\"", ScaleUp, &RemediationParams{Replicas: replicas(10)})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(4), count, "the configured max applies when the app only sets a min")
+		count, err = scale(t, "", ScaleDown, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), count, "replicas below the min are raised to it")
+
+		_, err = scale(t, "\n    app.icos.eu/max-replicas: \"1\"", ScaleUp, nil)
+		assert.ErrorContains(t, err, "Deployment nginx: max replicas 1 is below min replicas 2")
+		_, err = scale(t, "\n    app.icos.eu/min-replicas: many", ScaleUp, nil)
+		assert.ErrorContains(t, err, "error parsing min replicas")
+	})
+
+	t.Run("should reject replica counts that go the other way than the job", func(t *testing.T) {
+		defaultReplicaBounds, defaultReplicaBoundsErr = loadReplicaBounds("", "")
+		_, err := scale(t, "", ScaleDown, &RemediationParams{Replicas: replicas(10)})
+		assert.ErrorContains(t, err, "scale-down cannot change spec.replicas of Deployment nginx from 1 to 10")
+		_, err = scale(t, "", ScaleUp, &RemediationParams{Replicas: replicas(0)})
+		assert.ErrorContains(t, err, "scale-up cannot change spec.replicas of Deployment nginx from 1 to 0")
+		count, err := scale(t, "", ScaleDown, &RemediationParams{Replicas: replicas(1)})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), count)
+	})
+
+	t.Run("should reject resources that go the other way than the job", func(t *testing.T) {
+		obj, err := decodeYAMLToObject(mockDeploymentYaml)
+		assert.NoError(t, err)
+		j := MockUpdateJob(ScaleIn)
+		j.Remediation = &RemediationParams{Resources: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}}
+		_, _, err = updateResourceRequirements(obj, &j)
+		assert.ErrorContains(t, err, "container nginx: scale-in cannot change the cpu request from 500m to 2")
+
+		j = MockUpdateJob(ScaleOut)
+		j.Remediation = &RemediationParams{Resources: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")}}
+		_, _, err = updateResourceRequirements(obj, &j)
+		assert.ErrorContains(t, err, "scale-out cannot change the memory request from 256Mi to 128Mi")
+	})
+
+	t.Run("should scale to the resources of the job", func(t *testing.T) {
+		obj, err := decodeYAMLToObject(mockDeploymentYaml)
+		assert.NoError(t, err)
+		j := MockUpdateJob(ScaleOut)
+		noStep := resource.MustParse("0")
+		j.Remediation = &RemediationParams{Resources: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}, MemoryStep: &noStep}
+		manifest, changes, err := updateResourceRequirements(obj, &j)
+		assert.NoError(t, err)
+		requests := manifest.Object.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Resources.Requests
+		assert.Equal(t, "2", requests.Cpu().String())
+		assert.Equal(t, "256Mi", requests.Memory().String(), "resources the job does not set are left alone")
+		assert.Len(t, changes, 1)
+	})
+
+	t.Run("should only remediate the named component", func(t *testing.T) {
+		j := MockUpdateJob(ScaleUp)
+		j.Remediation = &RemediationParams{Component: "other"}
+		manifests, err := buildJobManifests(&j)
+		assert.NoError(t, err)
+		updated, err := updateManifestsAttributes(manifests, &j)
+		assert.NoError(t, err)
+		assert.Equal(t, manifests, updated)
+		assert.Empty(t, j.Changes)
+
+		j.Remediation.Component = "nginx"
+		updated, err = updateManifestsAttributes(manifests, &j)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), *updated[0].Object.(*appsv1.Deployment).Spec.Replicas)
+	})
+
+	t.Run("should reject invalid targets", func(t *testing.T) {
+		assert.Error(t, (&RemediationParams{Replicas: replicas(-1)}).validate())
+		assert.Error(t, (&RemediationParams{Resources: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")}}).validate())
+		_, err := loadReplicaBounds("3", "2")
+		assert.Error(t, err)
+	})
+}
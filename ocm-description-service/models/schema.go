@@ -0,0 +1,249 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"icos/server/ocm-description-service/utils/logs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/kube-openapi/pkg/schemaconv"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	smdschema "sigs.k8s.io/structured-merge-diff/v4/schema"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var (
+	//go:embed schemas/*.yaml
+	bundledSchemaFiles embed.FS
+	// bundledSchemas maps a Kubernetes minor version to its bundled schema file, for the versions up to
+	// the one of the client-go release the service is built with
+	bundledSchemas = map[uint]string{
+		27: "schemas/kubernetes-v1.27.yaml",
+		28: "schemas/kubernetes-v1.28.yaml",
+		29: "schemas/kubernetes-v1.29.yaml",
+		30: "schemas/kubernetes-v1.30.yaml",
+	}
+	// directory holding a v1.<minor> directory of OpenAPI v3 documents for each additional version
+	manifestSchemaDir = os.Getenv("MANIFEST_SCHEMA_DIR")
+
+	loadedSchemas   = map[uint]*kubeSchema{}
+	loadedSchemasMu sync.Mutex
+)
+
+// kubeSchema is the structural schema of the API types of a Kubernetes minor version.
+type kubeSchema struct {
+	minor  uint
+	parser *typed.Parser
+	// kinds maps each kind described by OpenAPI documents to its schema type. It is nil for the
+	// bundled schemas, whose kinds are the ones of the client-go scheme.
+	kinds map[schema.GroupVersionKind]string
+}
+
+// typeOf returns the schema type of a kind. groupKnown reports whether the schema describes other
+// kinds of the same group version, which tells misspelt built-in kinds from custom resources.
+func (s *kubeSchema) typeOf(gvk schema.GroupVersionKind) (parseableType typed.ParseableType, found, groupKnown bool) {
+	if s.kinds == nil {
+		typeName, err := schemaTypeName(gvk)
+		if err != nil {
+			return typed.ParseableType{}, false, scheme.Scheme.IsVersionRegistered(gvk.GroupVersion())
+		}
+		parseableType = s.parser.Type(typeName)
+		return parseableType, parseableType.IsValid(), true
+	}
+
+	if typeName, ok := s.kinds[gvk]; ok {
+		parseableType = s.parser.Type(typeName)
+		return parseableType, parseableType.IsValid(), true
+	}
+	for kind := range s.kinds {
+		if kind.GroupVersion() == gvk.GroupVersion() {
+			return typed.ParseableType{}, false, true
+		}
+	}
+	return typed.ParseableType{}, false, false
+}
+
+// ErrSchemaNotFound is returned when neither the bundled schemas nor MANIFEST_SCHEMA_DIR describe the
+// Kubernetes version manifests are validated against.
+var ErrSchemaNotFound = errors.New("no schema")
+
+// loadSchema returns the schema of the given minor version, or the newest one available when minor
+// is 0. Schemas in MANIFEST_SCHEMA_DIR take precedence over the bundled ones.
+func loadSchema(minor uint) (*kubeSchema, error) {
+	directories := schemaDirectories()
+	if minor == 0 {
+		for available := range bundledSchemas {
+			minor = max(minor, available)
+		}
+		for available := range directories {
+			minor = max(minor, available)
+		}
+	}
+	directory, inDirectory := directories[minor]
+	if _, bundled := bundledSchemas[minor]; !bundled && !inDirectory {
+		return nil, fmt.Errorf("%w for Kubernetes v1.%d, add its OpenAPI documents to MANIFEST_SCHEMA_DIR", ErrSchemaNotFound, minor)
+	}
+
+	loadedSchemasMu.Lock()
+	defer loadedSchemasMu.Unlock()
+	if loaded, ok := loadedSchemas[minor]; ok {
+		return loaded, nil
+	}
+
+	var loaded *kubeSchema
+	var err error
+	if inDirectory {
+		loaded, err = loadOpenAPISchema(directory, minor)
+	} else {
+		loaded, err = loadBundledSchema(minor)
+	}
+	if err != nil {
+		return nil, err
+	}
+	loadedSchemas[minor] = loaded
+	return loaded, nil
+}
+
+// schemaDirectories returns the directories of MANIFEST_SCHEMA_DIR named after a Kubernetes version,
+// by minor version.
+func schemaDirectories() map[uint]string {
+	directories := map[uint]string{}
+	if manifestSchemaDir == "" {
+		return directories
+	}
+	entries, err := os.ReadDir(manifestSchemaDir)
+	if err != nil {
+		logs.Logger.Println("Error reading manifest schema directory:", err)
+		return directories
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		parsed, err := version.ParseGeneric(entry.Name())
+		if err != nil {
+			continue
+		}
+		directories[parsed.Minor()] = filepath.Join(manifestSchemaDir, entry.Name())
+	}
+	return directories
+}
+
+func loadBundledSchema(minor uint) (*kubeSchema, error) {
+	schemaYAML, err := bundledSchemaFiles.ReadFile(bundledSchemas[minor])
+	if err != nil {
+		return nil, fmt.Errorf("error reading bundled schema: %v", err)
+	}
+	parser, err := typed.NewParser(typed.YAMLObject(schemaYAML))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing bundled schema: %v", err)
+	}
+	return &kubeSchema{minor: minor, parser: parser}, nil
+}
+
+// loadOpenAPISchema builds the schema of a minor version from the OpenAPI v3 documents of a
+// directory, as served by the /openapi/v3 endpoints of a cluster of that version.
+func loadOpenAPISchema(directory string, minor uint) (*kubeSchema, error) {
+	files, err := filepath.Glob(filepath.Join(directory, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing schemas of %s: %v", directory, err)
+	}
+	models := map[string]*spec.Schema{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading schema %s: %v", file, err)
+		}
+		var document struct {
+			Components struct {
+				Schemas map[string]*spec.Schema `json:"schemas"`
+			} `json:"components"`
+		}
+		if err := json.Unmarshal(content, &document); err != nil {
+			return nil, fmt.Errorf("error decoding schema %s: %v", file, err)
+		}
+		for name, model := range document.Components.Schemas {
+			models[name] = model
+		}
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no OpenAPI v3 schemas found in %s", directory)
+	}
+
+	types, err := schemaconv.ToSchemaFromOpenAPI(models, false)
+	if err != nil {
+		return nil, fmt.Errorf("error converting schemas of %s: %v", directory, err)
+	}
+	loaded := &kubeSchema{
+		minor:  minor,
+		parser: &typed.Parser{Schema: smdschema.Schema{Types: types.Types}},
+		kinds:  map[schema.GroupVersionKind]string{},
+	}
+	for name, model := range models {
+		for _, gvk := range modelKinds(model) {
+			loaded.kinds[gvk] = name
+		}
+	}
+	return loaded, nil
+}
+
+// modelKinds returns the kinds an OpenAPI model describes, from its x-kubernetes-group-version-kind
+// extension.
+func modelKinds(model *spec.Schema) []schema.GroupVersionKind {
+	extension, _ := model.Extensions["x-kubernetes-group-version-kind"].([]interface{})
+	kinds := []schema.GroupVersionKind{}
+	for _, item := range extension {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, _ := fields["group"].(string)
+		groupVersion, _ := fields["version"].(string)
+		kind, _ := fields["kind"].(string)
+		if groupVersion != "" && kind != "" {
+			kinds = append(kinds, schema.GroupVersionKind{Group: group, Version: groupVersion, Kind: kind})
+		}
+	}
+	return kinds
+}
+
+// schemaTypeName maps a built-in kind to its schema type name, e.g. io.k8s.api.apps.v1.Deployment.
+func schemaTypeName(gvk schema.GroupVersionKind) (string, error) {
+	obj, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a known kind of %s", gvk.Kind, gvk.GroupVersion().String())
+	}
+	objType := reflect.TypeOf(obj).Elem()
+	// k8s.io/api/apps/v1 -> io.k8s.api.apps.v1
+	packagePath := strings.TrimPrefix(objType.PkgPath(), "k8s.io/")
+	return "io.k8s." + strings.ReplaceAll(packagePath, "/", ".") + "." + objType.Name(), nil
+}
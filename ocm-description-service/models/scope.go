@@ -0,0 +1,120 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// built-in kinds that are not namespaced
+var builtinClusterScopedKinds = map[schema.GroupKind]bool{
+	{Group: "", Kind: "Namespace"}:                                                    true,
+	{Group: "", Kind: "Node"}:                                                         true,
+	{Group: "", Kind: "PersistentVolume"}:                                             true,
+	{Group: "", Kind: "ComponentStatus"}:                                              true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                         true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                  true,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:                 true,
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                             true,
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                   true,
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                      true,
+	{Group: "storage.k8s.io", Kind: "CSINode"}:                                        true,
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"}:                               true,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                               true,
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                      true,
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                                true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}:   true,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:     true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"}:        true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"}: true,
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}:                 true,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"}:                       true,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"}:       true,
+	{Group: "policy", Kind: "PodSecurityPolicy"}:                                      true,
+}
+
+// cluster-scoped kinds, the built-in ones together with the custom kinds configured in
+// CLUSTER_SCOPED_KINDS; every other kind is handled as namespaced
+var clusterScopedKinds = parseClusterScopedKinds(os.Getenv("CLUSTER_SCOPED_KINDS"))
+
+// parseClusterScopedKinds adds the comma separated kinds of value, each written as Kind.group, e.g.
+// ClusterIssuer.cert-manager.io, to the built-in cluster-scoped kinds.
+func parseClusterScopedKinds(value string) map[schema.GroupKind]bool {
+	kinds := map[schema.GroupKind]bool{}
+	for kind := range builtinClusterScopedKinds {
+		kinds[kind] = true
+	}
+	for _, kind := range strings.Split(value, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			kinds[schema.ParseGroupKind(kind)] = true
+		}
+	}
+	return kinds
+}
+
+// namespaces that manifests may name explicitly instead of the job namespace, "*" allows any
+var explicitNamespaces = parseExplicitNamespaces(os.Getenv("EXPLICIT_NAMESPACES"))
+
+func parseExplicitNamespaces(value string) map[string]bool {
+	namespaces := map[string]bool{}
+	for _, namespace := range strings.Split(value, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaces[namespace] = true
+		}
+	}
+	return namespaces
+}
+
+func isClusterScoped(gvk schema.GroupVersionKind) bool {
+	return clusterScopedKinds[gvk.GroupKind()]
+}
+
+// setObjectNamespace places a decoded object in the job namespace. Cluster-scoped objects get no
+// namespace, and namespaced objects may only name another namespace when EXPLICIT_NAMESPACES allows it.
+func setObjectNamespace(obj runtime.Object, namespace string) error {
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return fmt.Errorf("error accessing object metadata: %v", err)
+	}
+
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if isClusterScoped(gvk) {
+		metaObj.SetNamespace("")
+		return nil
+	}
+
+	explicit := metaObj.GetNamespace()
+	if explicit != "" && explicit != namespace {
+		if explicitNamespaces["*"] || explicitNamespaces[explicit] {
+			return nil
+		}
+		return fmt.Errorf("%s %s: namespace %s is not allowed, manifests may only use the job namespace %s or EXPLICIT_NAMESPACES",
+			gvk.Kind, metaObj.GetName(), explicit, namespace)
+	}
+	metaObj.SetNamespace(namespace)
+	return nil
+}
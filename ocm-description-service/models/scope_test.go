@@ -0,0 +1,107 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespaceScope(t *testing.T) {
+	restoreAfterTest(t, &explicitNamespaces)
+	restoreAfterTest(t, &clusterScopedKinds)
+	scopedManifest := `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+  namespace: ignored
+---
+apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: local
+provisioner: rancher.io/local-path
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: dashboards
+  namespace: monitoring`
+	issuerManifest := `apiVersion: cert-manager.io/v1
+kind: ClusterIssuer
+metadata:
+  name: letsencrypt
+spec:
+  acme: {}`
+
+	namespaces := func(t *testing.T, manifests ...string) []string {
+		j := MockCreateDeploymentJob()
+		for _, manifest := range manifests {
+			j.Manifests = append(j.Manifests, PlainManifest{YamlString: manifest})
+		}
+		built, err := buildJobManifests(&j)
+		assert.NoError(t, err)
+		result := []string{}
+		for _, manifest := range built {
+			metaObj, err := meta.Accessor(manifest.Object)
+			assert.NoError(t, err)
+			result = append(result, metaObj.GetNamespace())
+		}
+		return result
+	}
+
+	t.Run("should not namespace cluster-scoped objects", func(t *testing.T) {
+		explicitNamespaces = parseExplicitNamespaces("monitoring")
+		// Deployment, Service, ClusterRole, StorageClass, ConfigMap
+		assert.Equal(t, []string{"icos-test", "icos-test", "", "", "monitoring"}, namespaces(t, scopedManifest))
+	})
+
+	t.Run("should not namespace configured cluster-scoped custom kinds", func(t *testing.T) {
+		clusterScopedKinds = parseClusterScopedKinds("")
+		assert.Equal(t, []string{"icos-test", "icos-test", "icos-test"}, namespaces(t, issuerManifest))
+		clusterScopedKinds = parseClusterScopedKinds("ClusterPolicy.kyverno.io, ClusterIssuer.cert-manager.io")
+		assert.Equal(t, []string{"icos-test", "icos-test", ""}, namespaces(t, issuerManifest))
+	})
+
+	t.Run("should keep explicit namespaces allowed by policy", func(t *testing.T) {
+		explicitNamespaces = parseExplicitNamespaces("logging, monitoring")
+		assert.Equal(t, []string{"icos-test", "icos-test", "", "", "monitoring"}, namespaces(t, scopedManifest))
+		explicitNamespaces = parseExplicitNamespaces("*")
+		assert.Equal(t, []string{"icos-test", "icos-test", "", "", "monitoring"}, namespaces(t, scopedManifest))
+	})
+
+	t.Run("should fail jobs with objects in namespaces not allowed by policy", func(t *testing.T) {
+		explicitNamespaces = parseExplicitNamespaces("logging")
+		j := MockCreateDeploymentJob()
+		j.Manifests = append(j.Manifests, PlainManifest{YamlString: scopedManifest})
+		_, err := buildJobManifests(&j)
+		assert.ErrorContains(t, err, "ConfigMap dashboards: namespace monitoring is not allowed")
+	})
+
+	t.Run("should fail on objects without metadata instead of exiting", func(t *testing.T) {
+		err := updateNamespaceAndAnnotations(&metav1.Status{}, "icos-test", "app", "component", "instance", "manifest")
+		assert.ErrorContains(t, err, "error accessing object metadata")
+	})
+}
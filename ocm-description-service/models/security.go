@@ -0,0 +1,168 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+func boolValue(value *bool) string {
+	if value == nil {
+		return unsetValue
+	}
+	return strconv.FormatBool(*value)
+}
+
+// hardenWorkload applies the SecurityRemediation to a workload: a hardened security context on the
+// targeted containers, the image bump of the job if any, rewritten and checked like the images of
+// create jobs, and no service account token automounting.
+// Objects that are not workloads are left untouched.
+func hardenWorkload(obj runtime.Object, params *RemediationParams) (*workv1.Manifest, []FieldChange, error) {
+	podSpec := workloadPodSpec(obj)
+	if podSpec == nil {
+		return nil, nil, nil
+	}
+	recorder, err := newChangeRecorder(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hardenContainers := func(field string, containers []corev1.Container, bumpImages bool) error {
+		for i := range containers {
+			container := &containers[i]
+			if !params.targetsContainer(container.Name) {
+				continue
+			}
+			prefix := fmt.Sprintf("%s[%s]", field, container.Name)
+			hardenSecurityContext(container, prefix, recorder)
+			if !bumpImages {
+				continue
+			}
+			image, err := bumpImage(container.Image, params)
+			if err == nil && image != container.Image {
+				// bumped images are placed like the ones of create jobs
+				image, err = placeImage(image)
+			}
+			if err != nil {
+				return fmt.Errorf("container %s: %v", container.Name, err)
+			}
+			recorder.record(prefix+".image", container.Image, image)
+			container.Image = image
+		}
+		return nil
+	}
+	if err := hardenContainers("initContainers", podSpec.InitContainers, false); err != nil {
+		return nil, nil, err
+	}
+	if err := hardenContainers("containers", podSpec.Containers, true); err != nil {
+		return nil, nil, err
+	}
+
+	disabled := false
+	recorder.record("automountServiceAccountToken", boolValue(podSpec.AutomountServiceAccountToken), boolValue(&disabled))
+	podSpec.AutomountServiceAccountToken = &disabled
+
+	return &workv1.Manifest{RawExtension: runtime.RawExtension{Object: obj}}, recorder.changes, nil
+}
+
+// hardenSecurityContext sets the fields of a container security context required by the restricted
+// Pod Security Standard, plus a read-only root filesystem.
+func hardenSecurityContext(container *corev1.Container, prefix string, recorder *changeRecorder) {
+	if container.SecurityContext == nil {
+		container.SecurityContext = &corev1.SecurityContext{}
+	}
+	securityContext := container.SecurityContext
+	field := prefix + ".securityContext"
+
+	setTrue := func(name string, value **bool) {
+		enabled := true
+		recorder.record(field+"."+name, boolValue(*value), boolValue(&enabled))
+		*value = &enabled
+	}
+	setTrue("runAsNonRoot", &securityContext.RunAsNonRoot)
+	setTrue("readOnlyRootFilesystem", &securityContext.ReadOnlyRootFilesystem)
+
+	escalation := false
+	recorder.record(field+".allowPrivilegeEscalation", boolValue(securityContext.AllowPrivilegeEscalation), boolValue(&escalation))
+	securityContext.AllowPrivilegeEscalation = &escalation
+
+	if securityContext.Capabilities == nil {
+		securityContext.Capabilities = &corev1.Capabilities{}
+	}
+	drop := []string{}
+	for _, capability := range securityContext.Capabilities.Drop {
+		drop = append(drop, string(capability))
+	}
+	if len(drop) != 1 || drop[0] != "ALL" {
+		old := unsetValue
+		if len(drop) > 0 {
+			old = "[" + strings.Join(drop, ",") + "]"
+		}
+		recorder.record(field+".capabilities.drop", old, "[ALL]")
+		securityContext.Capabilities.Drop = []corev1.Capability{"ALL"}
+	}
+
+	// a Localhost profile is at least as strict as RuntimeDefault
+	profile := securityContext.SeccompProfile
+	if profile == nil || (profile.Type != corev1.SeccompProfileTypeRuntimeDefault && profile.Type != corev1.SeccompProfileTypeLocalhost) {
+		old := unsetValue
+		if profile != nil {
+			old = string(profile.Type)
+		}
+		recorder.record(field+".seccompProfile.type", old, string(corev1.SeccompProfileTypeRuntimeDefault))
+		securityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+}
+
+// bumpImage replaces the tag or digest of an image with the one of the job, keeping its repository
+// as written.
+func bumpImage(image string, params *RemediationParams) (string, error) {
+	if params == nil || (params.ImageTag == "" && params.ImageDigest == "") {
+		return image, nil
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image %q: %v", image, err)
+	}
+	repository := reference.TrimNamed(named)
+
+	var bumped reference.Named
+	if params.ImageDigest != "" {
+		bumped, err = reference.WithDigest(repository, digest.Digest(params.ImageDigest))
+	} else {
+		bumped, err = reference.WithTag(repository, params.ImageTag)
+	}
+	if err != nil {
+		return "", err
+	}
+	if reference.FamiliarString(named) == image {
+		return reference.FamiliarString(bumped), nil
+	}
+	return bumped.String(), nil
+}
@@ -0,0 +1,168 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSecurityRemediation(t *testing.T) {
+	workClient := newFakeWorkClient()
+	useFakeClients(t, workClient, clusterfake.NewSimpleClientset())
+	t.Setenv("MANIFEST_VALIDATION", "false")
+
+	j := MockCreateDeploymentJob()
+	created, err := createManifestWork(&j)
+	assert.NoError(t, err)
+
+	t.Run("should harden the workloads and bump their images", func(t *testing.T) {
+		update := MockUpdateJob(SecurityRemediation)
+		update.Resource.ResourceName = created.Name
+		update.Remediation = &RemediationParams{ImageTag: "1.26", Containers: []string{"nginx"}}
+		_, err := updateDeploymentAttributes(&update)
+		assert.NoError(t, err)
+
+		live, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		var deployment *appsv1.Deployment
+		for _, manifest := range live.Spec.Workload.Manifests {
+			if d, ok := manifest.Object.(*appsv1.Deployment); ok {
+				deployment = d
+			}
+		}
+		if !assert.NotNil(t, deployment) {
+			return
+		}
+		podSpec := deployment.Spec.Template.Spec
+		container := podSpec.Containers[0]
+		assert.Equal(t, "nginx:1.26", container.Image)
+		assert.False(t, *podSpec.AutomountServiceAccountToken)
+		assert.True(t, *container.SecurityContext.RunAsNonRoot)
+		assert.True(t, *container.SecurityContext.ReadOnlyRootFilesystem)
+		assert.False(t, *container.SecurityContext.AllowPrivilegeEscalation)
+		assert.Equal(t, []corev1.Capability{"ALL"}, container.SecurityContext.Capabilities.Drop)
+		assert.Equal(t, corev1.SeccompProfileTypeRuntimeDefault, container.SecurityContext.SeccompProfile.Type)
+
+		change := func(field, old, new string) FieldChange {
+			return FieldChange{Kind: "Deployment", Name: "nginx", Field: field, Old: old, New: new}
+		}
+		assert.Equal(t, []FieldChange{
+			change("containers[nginx].securityContext.runAsNonRoot", unsetValue, "true"),
+			change("containers[nginx].securityContext.readOnlyRootFilesystem", unsetValue, "true"),
+			change("containers[nginx].securityContext.allowPrivilegeEscalation", unsetValue, "false"),
+			change("containers[nginx].securityContext.capabilities.drop", unsetValue, "[ALL]"),
+			change("containers[nginx].securityContext.seccompProfile.type", unsetValue, "RuntimeDefault"),
+			change("containers[nginx].image", "nginx:1.25", "nginx:1.26"),
+			change("automountServiceAccountToken", unsetValue, "false"),
+		}, update.Changes)
+
+		// applying the remediation again changes nothing
+		update.Remediation = nil
+		_, err = updateDeploymentAttributes(&update)
+		assert.NoError(t, err)
+		assert.Empty(t, update.Changes)
+	})
+
+	t.Run("should keep the repository of images as written", func(t *testing.T) {
+		digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+		bumped, err := bumpImage("registry.edge:5000/icos/app:1.0", &RemediationParams{ImageDigest: digest})
+		assert.NoError(t, err)
+		assert.Equal(t, "registry.edge:5000/icos/app@"+digest, bumped)
+		bumped, err = bumpImage("docker.io/library/nginx:1.25", &RemediationParams{ImageTag: "1.26"})
+		assert.NoError(t, err)
+		assert.Equal(t, "docker.io/library/nginx:1.26", bumped)
+	})
+
+	t.Run("should only remediate the named containers", func(t *testing.T) {
+		params := &RemediationParams{Containers: []string{"sidecar"}}
+		assert.True(t, params.targetsContainer("sidecar"))
+		assert.False(t, params.targetsContainer("nginx"))
+	})
+
+	t.Run("should reject invalid image bumps", func(t *testing.T) {
+		update := MockUpdateJob(SecurityRemediation)
+		update.Resource.ResourceName = created.Name
+		update.Remediation = &RemediationParams{ImageTag: "1.26", ImageDigest: "sha256:0123"}
+		_, err := updateDeploymentAttributes(&update)
+		assert.ErrorContains(t, err, "cannot be set together")
+		assert.Equal(t, Degraded, update.State)
+
+		assert.Error(t, (&RemediationParams{ImageDigest: "sha256:0123", Containers: []string{"nginx"}}).validate())
+		assert.Error(t, (&RemediationParams{ImageTag: "-bad", Containers: []string{"nginx"}}).validate())
+	})
+
+	t.Run("should require the containers whose images are bumped", func(t *testing.T) {
+		update := MockUpdateJob(SecurityRemediation)
+		update.Resource.ResourceName = created.Name
+		update.Remediation = &RemediationParams{ImageTag: "1.27"}
+		_, err := updateDeploymentAttributes(&update)
+		assert.ErrorContains(t, err, "containers is required to bump images")
+
+		live, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		for _, manifest := range live.Spec.Workload.Manifests {
+			if deployment, ok := manifest.Object.(*appsv1.Deployment); ok {
+				assert.Equal(t, "nginx:1.26", deployment.Spec.Template.Spec.Containers[0].Image)
+			}
+		}
+	})
+
+	t.Run("should rewrite bumped images and require them to be pinned", func(t *testing.T) {
+		restoreAfterTest(t, &imageRewriteRules)
+		restoreAfterTest(t, &requireImageDigest)
+		imageRewriteRules = []ImageRewriteRule{{Registry: "docker.io", Replacement: "mirror.edge:5000"}}
+		requireImageDigest = true
+		liveImage := func() string {
+			live, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+			assert.NoError(t, err)
+			for _, manifest := range live.Spec.Workload.Manifests {
+				if deployment, ok := manifest.Object.(*appsv1.Deployment); ok {
+					return deployment.Spec.Template.Spec.Containers[0].Image
+				}
+			}
+			return ""
+		}
+
+		update := MockUpdateJob(SecurityRemediation)
+		update.Resource.ResourceName = created.Name
+		update.Remediation = &RemediationParams{ImageTag: "1.27", Containers: []string{"nginx"}}
+		_, err := updateDeploymentAttributes(&update)
+		assert.ErrorContains(t, err, "image mirror.edge:5000/library/nginx:1.27 is not pinned to a digest")
+		assert.Equal(t, Degraded, update.State)
+		assert.Equal(t, "nginx:1.26", liveImage())
+
+		digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+		update = MockUpdateJob(SecurityRemediation)
+		update.Resource.ResourceName = created.Name
+		update.Remediation = &RemediationParams{ImageDigest: digest, Containers: []string{"nginx"}}
+		_, err = updateDeploymentAttributes(&update)
+		assert.NoError(t, err)
+		assert.Equal(t, "mirror.edge:5000/library/nginx@"+digest, liveImage())
+	})
+}
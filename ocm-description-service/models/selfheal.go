@@ -0,0 +1,413 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"icos/server/ocm-description-service/utils/logs"
+	"os"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// SpecHashAnnotation holds the hash of the spec the deploy manager last applied to a ManifestWork
+	SpecHashAnnotation = "deploymanager.icos.eu/spec-hash"
+
+	// reasons of the audit events recorded by the self-healing loop
+	ReasonDriftReverted    = "DriftReverted"
+	ReasonWorkRestored     = "ManifestWorkRestored"
+	ReasonDriftDetected    = "DriftDetected"
+	ReasonSelfHealingError = "SelfHealingFailed"
+
+	defaultSelfHealingInterval = time.Minute
+	auditEventComponent        = "deploy-manager"
+)
+
+var (
+	// self-healing is opt-in, see RunSelfHealing
+	selfHealingEnabled  = os.Getenv("SELF_HEALING") == "true"
+	selfHealingInterval = getSelfHealingInterval()
+
+	appliedWorks = newAppliedWorkStore()
+)
+
+func getSelfHealingInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("SELF_HEALING_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return defaultSelfHealingInterval
+	}
+	return interval
+}
+
+// SelfHealingEnabled reports whether the self-healing loop has been enabled with SELF_HEALING=true.
+func SelfHealingEnabled() bool {
+	return selfHealingEnabled
+}
+
+// appliedWorkStore keeps the ManifestWorks as last applied by the deploy manager, which the self-healing
+// loop restores. The lock only guards the store, hub API calls are made without it. Healing leaves
+// alone the works with a write in progress, so the loop never mistakes such a write for drift.
+type appliedWorkStore struct {
+	mu    sync.Mutex
+	works map[string]*workv1.ManifestWork
+	// writing counts the writes in progress to each ManifestWork
+	writing map[string]int
+}
+
+func newAppliedWorkStore() *appliedWorkStore {
+	return &appliedWorkStore{works: map[string]*workv1.ManifestWork{}, writing: map[string]int{}}
+}
+
+func workKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// create stamps the spec hash on a ManifestWork and creates it, remembering the result under the name
+// the hub assigned. A work created with only a generateName cannot be known to healing before it
+// exists, so no write is guarded for it.
+func (s *appliedWorkStore) create(work *workv1.ManifestWork) (*workv1.ManifestWork, error) {
+	if err := stampSpecHash(work); err != nil {
+		return nil, err
+	}
+	key := ""
+	if work.Name != "" {
+		key = s.startWrite(work)
+	}
+	created, err := clientsetWorkOper.WorkV1().ManifestWorks(work.Namespace).Create(context.TODO(), work, metav1.CreateOptions{})
+	s.finishWrite(key, created, err)
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// update stamps the spec hash on a ManifestWork and updates it, remembering the result.
+func (s *appliedWorkStore) update(work *workv1.ManifestWork) (*workv1.ManifestWork, error) {
+	if err := stampSpecHash(work); err != nil {
+		return nil, err
+	}
+	key := s.startWrite(work)
+	updated, err := clientsetWorkOper.WorkV1().ManifestWorks(work.Namespace).Update(context.TODO(), work, metav1.UpdateOptions{})
+	s.finishWrite(key, updated, err)
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// delete forgets a ManifestWork, so that it is not restored, and deletes it.
+func (s *appliedWorkStore) delete(namespace, name string) error {
+	s.mu.Lock()
+	delete(s.works, workKey(namespace, name))
+	s.mu.Unlock()
+	return clientsetWorkOper.WorkV1().ManifestWorks(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+}
+
+func (s *appliedWorkStore) startWrite(work *workv1.ManifestWork) string {
+	key := workKey(work.Namespace, work.Name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writing[key]++
+	return key
+}
+
+// finishWrite remembers the result of a successful write. An empty key finishes an unguarded write.
+func (s *appliedWorkStore) finishWrite(key string, written *workv1.ManifestWork, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key != "" {
+		if s.writing[key]--; s.writing[key] <= 0 {
+			delete(s.writing, key)
+		}
+	}
+	if err == nil {
+		s.remember(written)
+	}
+}
+
+// remember must be called with the lock held. Remembered works are never modified, so that healing
+// can use them without the lock.
+func (s *appliedWorkStore) remember(work *workv1.ManifestWork) {
+	if !selfHealingEnabled {
+		return
+	}
+	s.works[workKey(work.Namespace, work.Name)] = work.DeepCopy()
+}
+
+// snapshot returns the work remembered under key, unless it is unknown or being written.
+func (s *appliedWorkStore) snapshot(key string) (*workv1.ManifestWork, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	applied, ok := s.works[key]
+	return applied, ok && s.writing[key] == 0
+}
+
+// unchanged tells whether applied is still the work remembered under key, with no write in progress.
+func (s *appliedWorkStore) unchanged(key string, applied *workv1.ManifestWork) bool {
+	current, ok := s.snapshot(key)
+	return ok && current == applied
+}
+
+// replace remembers a restored work, unless the work was written or forgotten while it was restored.
+func (s *appliedWorkStore) replace(key string, applied, restored *workv1.ManifestWork) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.works[key] == applied {
+		s.remember(restored)
+	}
+}
+
+func (s *appliedWorkStore) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.works))
+	for key := range s.works {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// specHash hashes the spec of a ManifestWork. The spec goes through a generic decoding first, so that
+// manifests holding typed objects and manifests holding raw JSON hash the same.
+func specHash(spec workv1.ManifestWorkSpec) (string, error) {
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("error encoding ManifestWork spec: %v", err)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		return "", fmt.Errorf("error decoding ManifestWork spec: %v", err)
+	}
+	normalized, err := json.Marshal(generic)
+	if err != nil {
+		return "", fmt.Errorf("error encoding ManifestWork spec: %v", err)
+	}
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func stampSpecHash(work *workv1.ManifestWork) error {
+	hash, err := specHash(work.Spec)
+	if err != nil {
+		return err
+	}
+	annotations := work.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[SpecHashAnnotation] = hash
+	work.SetAnnotations(annotations)
+	return nil
+}
+
+// RunSelfHealing restores, every SELF_HEALING_INTERVAL, the ManifestWorks whose spec was changed or
+// that were deleted outside of the deploy manager, until ctx is done. Every restore is recorded as an
+// event on the ManifestWork.
+func RunSelfHealing(ctx context.Context) {
+	logs.Logger.Println("Self-healing enabled, checking ManifestWorks every", selfHealingInterval)
+	seedAppliedWorks()
+
+	ticker := time.NewTicker(selfHealingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			healManifestWorks()
+		}
+	}
+}
+
+// seedAppliedWorks remembers the ManifestWorks applied before the service started: the ones whose spec
+// still matches the hash the deploy manager stamped on them, and, from the last revision of their
+// resource, the ones deleted since and the ones edited since whose revision holds the stamped spec.
+func seedAppliedWorks() {
+	managedClusters, err := clientsetClusterOper.ClusterV1().ManagedClusters().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logs.Logger.Println("Error obtaining managed clusters:", err)
+		return
+	}
+
+	intact := []*workv1.ManifestWork{}
+	for _, managedCluster := range managedClusters.Items {
+		works, err := clientsetWorkOper.WorkV1().ManifestWorks(managedCluster.Name).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			logs.Logger.Printf("Error listing ManifestWorks of cluster %s: %v", managedCluster.Name, err)
+			continue
+		}
+		revised, err := lastRevisionWorks(managedCluster.Name)
+		if err != nil {
+			logs.Logger.Printf("Error obtaining revisions of cluster %s: %v", managedCluster.Name, err)
+		}
+
+		for i := range works.Items {
+			work := &works.Items[i]
+			revision := revised[work.Name]
+			delete(revised, work.Name)
+			stamped, ok := work.Annotations[SpecHashAnnotation]
+			if !ok {
+				continue
+			}
+			hash, err := specHash(work.Spec)
+			if err == nil && hash == stamped {
+				intact = append(intact, work)
+				continue
+			}
+			if revision != nil && revision.Annotations[SpecHashAnnotation] == stamped {
+				work.Spec = revision.Spec
+				intact = append(intact, work)
+				continue
+			}
+			recordAuditEvent(work, corev1.EventTypeWarning, ReasonDriftDetected,
+				"spec differs from the one last applied by the deploy manager, which is unknown since its restart")
+		}
+		// what is left of the revised works was deleted
+		for _, revision := range revised {
+			intact = append(intact, revision)
+		}
+	}
+
+	appliedWorks.mu.Lock()
+	defer appliedWorks.mu.Unlock()
+	for _, work := range intact {
+		key := workKey(work.Namespace, work.Name)
+		// jobs may have written the work since it was listed
+		if _, known := appliedWorks.works[key]; !known && appliedWorks.writing[key] == 0 {
+			appliedWorks.remember(work)
+		}
+	}
+}
+
+// healManifestWorks restores every remembered ManifestWork that drifted or was deleted.
+func healManifestWorks() {
+	for _, key := range appliedWorks.keys() {
+		if err := healManifestWork(key); err != nil {
+			logs.Logger.Printf("Error healing ManifestWork %s: %v", key, err)
+		}
+	}
+}
+
+// healManifestWork restores the ManifestWork remembered under key. The work is read and written without
+// the store lock; a job that writes it in the meantime either makes healing skip it, or makes the
+// restore fail on the resource version.
+func healManifestWork(key string) error {
+	applied, ok := appliedWorks.snapshot(key)
+	if !ok {
+		return nil
+	}
+	client := clientsetWorkOper.WorkV1().ManifestWorks(applied.Namespace)
+
+	live, err := client.Get(context.TODO(), applied.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if !appliedWorks.unchanged(key, applied) {
+		// written or deleted by a job since the snapshot
+		return nil
+	}
+	if apierrors.IsNotFound(err) {
+		restored := applied.DeepCopy()
+		restored.ResourceVersion = ""
+		restored.UID = ""
+		restored.Status = workv1.ManifestWorkStatus{}
+		created, err := client.Create(context.TODO(), restored, metav1.CreateOptions{})
+		if err != nil {
+			recordAuditEvent(applied, corev1.EventTypeWarning, ReasonSelfHealingError, fmt.Sprintf("restoring deleted ManifestWork: %v", err))
+			return err
+		}
+		appliedWorks.replace(key, applied, created)
+		message := fmt.Sprintf("ManifestWork was deleted outside of the deploy manager and has been recreated, its UID changed from %s to %s", applied.UID, created.UID)
+		if applied.UID == "" {
+			message = "ManifestWork was deleted outside of the deploy manager and has been recreated from the last revision of its resource"
+		}
+		recordAuditEvent(created, corev1.EventTypeWarning, ReasonWorkRestored, message)
+		return nil
+	}
+
+	liveHash, err := specHash(live.Spec)
+	if err != nil {
+		return err
+	}
+	appliedHash, err := specHash(applied.Spec)
+	if err != nil {
+		return err
+	}
+	if liveHash == appliedHash {
+		return nil
+	}
+
+	live.Spec = *applied.Spec.DeepCopy()
+	reverted, err := client.Update(context.TODO(), live, metav1.UpdateOptions{})
+	if err != nil {
+		recordAuditEvent(live, corev1.EventTypeWarning, ReasonSelfHealingError, fmt.Sprintf("reverting drifted spec: %v", err))
+		return err
+	}
+	appliedWorks.replace(key, applied, reverted)
+	recordAuditEvent(reverted, corev1.EventTypeWarning, ReasonDriftReverted,
+		"spec was changed outside of the deploy manager and has been restored to the last applied one")
+	return nil
+}
+
+// recordAuditEvent logs an action of the self-healing loop and records it as an event on the ManifestWork.
+func recordAuditEvent(work *workv1.ManifestWork, eventType, reason, message string) {
+	logs.Logger.Printf("%s %s/%s: %s", reason, work.Namespace, work.Name, message)
+	if clientset == nil {
+		return
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// named like the events of client-go's event recorder
+			Name:      fmt.Sprintf("%v.%x", work.Name, now.UnixNano()),
+			Namespace: work.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      workv1.GroupVersion.String(),
+			Kind:            "ManifestWork",
+			Namespace:       work.Namespace,
+			Name:            work.Name,
+			UID:             work.UID,
+			ResourceVersion: work.ResourceVersion,
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: auditEventComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := clientset.CoreV1().Events(work.Namespace).Create(context.TODO(), event, metav1.CreateOptions{}); err != nil {
+		logs.Logger.Println("Error recording audit event:", err)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestSelfHealing(t *testing.T) {
+	workClient := newFakeWorkClient()
+	useFakeClients(t, workClient, clusterfake.NewSimpleClientset(&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}))
+	eventClient := k8sfake.NewSimpleClientset()
+	useFakeKubeClient(t, eventClient)
+	t.Setenv("MANIFEST_VALIDATION", "false")
+	setForTest(t, &selfHealingEnabled, true)
+
+	eventReasons := func() []string {
+		events, err := eventClient.CoreV1().Events("cluster1").List(context.TODO(), metav1.ListOptions{})
+		assert.NoError(t, err)
+		reasons := []string{}
+		for _, event := range events.Items {
+			reasons = append(reasons, event.Reason)
+		}
+		return reasons
+	}
+
+	j := MockCreateDeploymentJob()
+	created, err := createManifestWork(&j)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.Annotations[SpecHashAnnotation])
+	appliedSpec := created.Spec.DeepCopy()
+
+	t.Run("should revert manual edits of the spec", func(t *testing.T) {
+		edited := created.DeepCopy()
+		edited.Spec.Workload.Manifests = edited.Spec.Workload.Manifests[:1]
+		_, err := workClient.WorkV1().ManifestWorks("cluster1").Update(context.TODO(), edited, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+
+		healManifestWorks()
+		live, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, *appliedSpec, live.Spec)
+		assert.Equal(t, []string{ReasonDriftReverted}, eventReasons())
+	})
+
+	t.Run("should leave changes made by the deploy manager alone", func(t *testing.T) {
+		update := MockUpdateJob(ScaleUp)
+		update.Resource.ResourceName = created.Name
+		_, err := updateDeploymentAttributes(&update)
+		assert.NoError(t, err)
+
+		healManifestWorks()
+		live, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.NotEqual(t, *appliedSpec, live.Spec)
+		assert.Equal(t, []string{ReasonDriftReverted}, eventReasons())
+	})
+
+	t.Run("should leave works being written by a job alone", func(t *testing.T) {
+		edited, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		edited.Spec.Workload.Manifests = edited.Spec.Workload.Manifests[:1]
+		edited, err = workClient.WorkV1().ManifestWorks("cluster1").Update(context.TODO(), edited, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+
+		key := appliedWorks.startWrite(edited)
+		healManifestWorks()
+		live, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, live.Spec.Workload.Manifests, 1)
+		appliedWorks.finishWrite(key, live, nil)
+		assert.Equal(t, []string{ReasonDriftReverted}, eventReasons())
+	})
+
+	t.Run("should not hold the store lock while calling the hub", func(t *testing.T) {
+		workClient.PrependReactor("*", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			locked := appliedWorks.mu.TryLock()
+			if locked {
+				appliedWorks.mu.Unlock()
+			}
+			assert.True(t, locked, "%s %s with the store lock held", action.GetVerb(), action.GetResource().Resource)
+			return false, nil, nil
+		})
+		defer func() { workClient.ReactionChain = workClient.ReactionChain[1:] }()
+
+		update := MockUpdateJob(ScaleUp)
+		update.Resource.ResourceName = created.Name
+		_, err := updateDeploymentAttributes(&update)
+		assert.NoError(t, err)
+		healManifestWorks()
+	})
+
+	t.Run("should restore works deleted out of band", func(t *testing.T) {
+		err := workClient.WorkV1().ManifestWorks("cluster1").Delete(context.TODO(), created.Name, metav1.DeleteOptions{})
+		assert.NoError(t, err)
+
+		healManifestWorks()
+		_, err = workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{ReasonDriftReverted, ReasonWorkRestored}, eventReasons())
+	})
+
+	t.Run("should not restore works deleted by a job", func(t *testing.T) {
+		j.Resource.ResourceName = created.Name
+		_, err := deleteDeployment(&j)
+		assert.NoError(t, err)
+
+		healManifestWorks()
+		_, err = workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("should only remember works that still match their spec hash after a restart", func(t *testing.T) {
+		intact := MockCreateDeploymentJob()
+		intactWork, err := createManifestWork(&intact)
+		assert.NoError(t, err)
+		drifted := intactWork.DeepCopy()
+		drifted.Name = "edited"
+		drifted.ResourceVersion = ""
+		drifted.Spec.Workload.Manifests = nil
+		_, err = workClient.WorkV1().ManifestWorks("cluster1").Create(context.TODO(), drifted, metav1.CreateOptions{})
+		assert.NoError(t, err)
+
+		appliedWorks = newAppliedWorkStore()
+		seedAppliedWorks()
+		assert.Equal(t, []string{"cluster1/" + intactWork.Name}, appliedWorks.keys())
+		assert.Contains(t, eventReasons(), ReasonDriftDetected)
+		assert.NoError(t, workClient.WorkV1().ManifestWorks("cluster1").Delete(context.TODO(), "edited", metav1.DeleteOptions{}))
+	})
+
+	t.Run("should restore works deleted or edited while the service was down from their last revision", func(t *testing.T) {
+		appliedWorks = newAppliedWorkStore()
+		deleted := MockCreateDeploymentJob()
+		deleted.Resource = &Resource{BaseUUID: BaseUUID{ID: "8f7e6d5c-4b3a-4f2e-9d1c-0b9a8f7e6d5c"}, ResourceName: "redis"}
+		deletedWork, err := createManifestWork(&deleted)
+		assert.NoError(t, err)
+		edited := MockCreateDeploymentJob()
+		edited.Resource = &Resource{BaseUUID: BaseUUID{ID: "7f6e5d4c-3b2a-4f1e-8d9c-0b1a2f3e4d5c"}, ResourceName: "web"}
+		editedWork, err := createManifestWork(&edited)
+		assert.NoError(t, err)
+
+		// the service restarts, meanwhile one work is deleted and the other edited
+		appliedWorks = newAppliedWorkStore()
+		assert.NoError(t, workClient.WorkV1().ManifestWorks("cluster1").Delete(context.TODO(), deletedWork.Name, metav1.DeleteOptions{}))
+		drifted := editedWork.DeepCopy()
+		drifted.Spec.Workload.Manifests = drifted.Spec.Workload.Manifests[:1]
+		_, err = workClient.WorkV1().ManifestWorks("cluster1").Update(context.TODO(), drifted, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+
+		seedAppliedWorks()
+		healManifestWorks()
+		restored, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), deletedWork.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, deletedWork.Labels, restored.Labels)
+		assert.Len(t, restored.Spec.Workload.Manifests, len(deletedWork.Spec.Workload.Manifests))
+		reverted, err := workClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), editedWork.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, reverted.Spec.Workload.Manifests, len(editedWork.Spec.Workload.Manifests))
+	})
+}
+
+func TestAppliedWorkStore(t *testing.T) {
+	workClient := newFakeWorkClient()
+	useFakeClients(t, workClient, clusterfake.NewSimpleClientset())
+	setForTest(t, &selfHealingEnabled, true)
+
+	t.Run("should remember works created with a generated name under the name the hub assigned", func(t *testing.T) {
+		workClient.PrependReactor("create", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			assert.Empty(t, appliedWorks.writing, "no write is guarded for a work without a name")
+			return false, nil, nil
+		})
+		defer func() { workClient.ReactionChain = workClient.ReactionChain[1:] }()
+
+		work := &workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{GenerateName: "nginx-", Namespace: "cluster1"}}
+		created, err := appliedWorks.create(work)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"cluster1/" + created.Name}, appliedWorks.keys())
+		assert.Empty(t, appliedWorks.writing)
+	})
+}
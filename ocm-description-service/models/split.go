@@ -0,0 +1,317 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"icos/server/ocm-description-service/utils/logs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// PartOfLabel links an additional ManifestWork to the primary ManifestWork of its resource
+	PartOfLabel = "deploymanager.icos.eu/part-of"
+	// PartLabel holds the index of an additional ManifestWork within its resource
+	PartLabel = "deploymanager.icos.eu/part"
+
+	defaultManifestWorkMaxSize = 500 * 1024
+	// bytes kept free in every ManifestWork for the labels and annotations set when it is applied
+	manifestWorkMetadataReserve = 2 * 1024
+	// bytes kept free for the status the work agent reports on each manifest
+	manifestStatusReserve = 512
+)
+
+// maximum encoded size, in bytes, of a single ManifestWork, including its metadata and status
+var manifestWorkMaxSize = getManifestWorkMaxSize()
+
+func getManifestWorkMaxSize() int {
+	size, err := strconv.Atoi(os.Getenv("MANIFESTWORK_MAX_SIZE"))
+	if err != nil || size <= 0 {
+		return defaultManifestWorkMaxSize
+	}
+	return size
+}
+
+// GenerateManifestWorks generates the ManifestWorks for the given job. The first one is the primary
+// ManifestWork of the resource; when the encoded manifests exceed the size limit the remaining ones
+// carry the overflow and are named and linked to the primary once it has been created.
+func GenerateManifestWorks(j *Job) ([]*workv1.ManifestWork, error) {
+	work, err := GenerateManifestWork(j)
+	if err != nil {
+		return nil, err
+	}
+
+	budget, err := manifestBudget(work)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := splitManifests(work.Spec.Workload.Manifests, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	work.Spec.Workload.Manifests = chunks[0]
+	works := []*workv1.ManifestWork{work}
+	for _, chunk := range chunks[1:] {
+		// parts carry the spec options of the primary but none of its labels; the ones linking them
+		// to the primary are set once it is named
+		part := &workv1.ManifestWork{
+			TypeMeta:   work.TypeMeta,
+			ObjectMeta: metav1.ObjectMeta{Namespace: work.Namespace},
+			Spec:       *work.Spec.DeepCopy(),
+		}
+		part.Spec.Workload.Manifests = chunk
+		works = append(works, part)
+	}
+	if len(works) > 1 {
+		logs.Logger.Printf("Manifests of Job %s split across %d ManifestWorks", j.ID, len(works))
+	}
+	return works, nil
+}
+
+// manifestBudget returns the bytes of a ManifestWork left for its manifests once its metadata, the
+// labels and annotations set when it is applied, and the status of its manifests are accounted for.
+func manifestBudget(work *workv1.ManifestWork) (int, error) {
+	envelope := *work
+	envelope.Spec.Workload.Manifests = nil
+	encoded, err := json.Marshal(&envelope)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding ManifestWork: %v", err)
+	}
+	return manifestWorkMaxSize - len(encoded) - manifestWorkMetadataReserve, nil
+}
+
+// splitManifests partitions the manifests, keeping their order, into chunks whose encoded size, with
+// the status reserved for each manifest, does not exceed limit bytes.
+func splitManifests(manifests []workv1.Manifest, limit int) ([][]workv1.Manifest, error) {
+	chunks := [][]workv1.Manifest{}
+	chunk := []workv1.Manifest{}
+	chunkSize := 0
+
+	for i, manifest := range manifests {
+		encoded, err := json.Marshal(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding manifest %d: %v", i, err)
+		}
+		size := len(encoded) + manifestStatusReserve
+		if size > limit {
+			return nil, fmt.Errorf("manifest %d (%s) is %d bytes, above the ManifestWork size limit of %d bytes once its metadata and status are accounted for", i, describeManifest(manifest), len(encoded), max(limit-manifestStatusReserve, 0))
+		}
+		if chunkSize+size > limit {
+			chunks = append(chunks, chunk)
+			chunk = []workv1.Manifest{}
+			chunkSize = 0
+		}
+		chunk = append(chunk, manifest)
+		chunkSize += size
+	}
+	return append(chunks, chunk), nil
+}
+
+// describeManifest returns kind/name of a manifest, for logging purposes.
+func describeManifest(manifest workv1.Manifest) string {
+	if manifest.Object == nil {
+		return "raw manifest"
+	}
+	kind := manifest.Object.GetObjectKind().GroupVersionKind().Kind
+	metaObj, err := meta.Accessor(manifest.Object)
+	if err != nil {
+		return kind
+	}
+	return kind + "/" + metaObj.GetName()
+}
+
+// manifestWorkPartName returns the name of the additional ManifestWork with the given index.
+func manifestWorkPartName(primaryName string, index int) string {
+	return fmt.Sprintf("%s-part-%d", primaryName, index)
+}
+
+// applyManifestWorkParts creates or updates the additional ManifestWorks of a resource so that they
+// match parts, and deletes the ones that are no longer needed. It returns the names of the parts.
+func applyManifestWorkParts(namespace, primaryName string, parts []*workv1.ManifestWork) ([]string, error) {
+	names := make([]string, 0, len(parts))
+	for i, part := range parts {
+		index := i + 1
+		part.Name = manifestWorkPartName(primaryName, index)
+		if part.Labels == nil {
+			part.Labels = map[string]string{}
+		}
+		part.Labels[PartOfLabel] = primaryName
+		part.Labels[PartLabel] = strconv.Itoa(index)
+
+		existing, err := clientsetWorkOper.WorkV1().ManifestWorks(namespace).Get(context.TODO(), part.Name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			_, err = appliedWorks.create(part)
+		case err == nil:
+			existing.Labels = part.Labels
+			existing.Spec = part.Spec
+			_, err = appliedWorks.update(existing)
+		}
+		if err != nil {
+			return names, fmt.Errorf("error applying ManifestWork %s: %v", part.Name, err)
+		}
+		names = append(names, part.Name)
+	}
+
+	return names, deleteManifestWorkParts(namespace, primaryName, len(parts))
+}
+
+// updateManifestWorks updates the ManifestWorks of a resource in order. previous holds the works as
+// they were before being changed; when an update fails the works already updated are restored to
+// them, so that the resource is not left half updated, and the ones that cannot be are named in the
+// error.
+func updateManifestWorks(works, previous []*workv1.ManifestWork) ([]*workv1.ManifestWork, error) {
+	updated := make([]*workv1.ManifestWork, 0, len(works))
+	for _, work := range works {
+		result, err := appliedWorks.update(work)
+		if err == nil {
+			updated = append(updated, result)
+			continue
+		}
+
+		err = fmt.Errorf("error updating ManifestWork %s: %v", work.Name, err)
+		unrestored := []string{}
+		for i, written := range updated {
+			written.Labels = previous[i].Labels
+			written.Spec = previous[i].Spec
+			if _, restoreErr := appliedWorks.update(written); restoreErr != nil {
+				logs.Logger.Printf("Error restoring ManifestWork %s: %v", written.Name, restoreErr)
+				unrestored = append(unrestored, written.Name)
+			}
+		}
+		if len(unrestored) > 0 {
+			err = fmt.Errorf("%v, ManifestWorks %s were updated and could not be restored", err, strings.Join(unrestored, ", "))
+		}
+		return nil, err
+	}
+	return updated, nil
+}
+
+// fetchManifestWorkParts lists the additional ManifestWorks of a resource, ordered by index.
+func fetchManifestWorkParts(namespace, primaryName string) ([]workv1.ManifestWork, error) {
+	list, err := clientsetWorkOper.WorkV1().ManifestWorks(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: PartOfLabel + "=" + primaryName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing parts of ManifestWork %s: %v", primaryName, err)
+	}
+	parts := list.Items
+	sort.Slice(parts, func(a, b int) bool {
+		return partIndex(&parts[a]) < partIndex(&parts[b])
+	})
+	return parts, nil
+}
+
+// listManifestWorkParts returns the additional ManifestWorks of a cluster by the name of their primary
+// ManifestWork, in part order.
+func listManifestWorkParts(namespace string) (map[string][]workv1.ManifestWork, error) {
+	list, err := clientsetWorkOper.WorkV1().ManifestWorks(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: PartOfLabel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing ManifestWork parts of cluster %s: %v", namespace, err)
+	}
+	partsOf := map[string][]workv1.ManifestWork{}
+	for _, part := range list.Items {
+		partsOf[part.Labels[PartOfLabel]] = append(partsOf[part.Labels[PartOfLabel]], part)
+	}
+	for _, parts := range partsOf {
+		sort.Slice(parts, func(a, b int) bool {
+			return partIndex(&parts[a]) < partIndex(&parts[b])
+		})
+	}
+	return partsOf, nil
+}
+
+// deleteManifestWorkParts deletes the additional ManifestWorks of a resource whose index is above keep.
+func deleteManifestWorkParts(namespace, primaryName string, keep int) error {
+	parts, err := fetchManifestWorkParts(namespace, primaryName)
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if partIndex(&part) <= keep {
+			continue
+		}
+		err := appliedWorks.delete(namespace, part.Name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting ManifestWork %s: %v", part.Name, err)
+		}
+		logs.Logger.Println("Deleted ManifestWork part:", part.Name)
+	}
+	return nil
+}
+
+func partIndex(work *workv1.ManifestWork) int {
+	index, _ := strconv.Atoi(work.Labels[PartLabel])
+	return index
+}
+
+// aggregateManifestWorkStatus folds the conditions of the additional ManifestWorks into the status of
+// the primary one: Applied and Available only hold when they hold for every work, Progressing and
+// Degraded as soon as they hold for one of them.
+func aggregateManifestWorkStatus(primary *workv1.ManifestWork, parts []workv1.ManifestWork) {
+	if len(parts) == 0 {
+		return
+	}
+	conditions := append([]metav1.Condition(nil), primary.Status.Conditions...)
+
+	for _, part := range parts {
+		for _, condition := range part.Status.Conditions {
+			current := meta.FindStatusCondition(conditions, condition.Type)
+			if current == nil {
+				conditions = append(conditions, condition)
+				continue
+			}
+			anyTrue := condition.Type == workv1.WorkProgressing || condition.Type == workv1.WorkDegraded
+			if anyTrue == (condition.Status == metav1.ConditionTrue) && current.Status != condition.Status {
+				*current = condition
+				current.Message = fmt.Sprintf("%s: %s", part.Name, condition.Message)
+			}
+		}
+	}
+
+	// a condition that is missing on some work cannot hold for the whole set
+	works := append([]workv1.ManifestWork{*primary}, parts...)
+	for i := range conditions {
+		if conditions[i].Type != workv1.WorkApplied && conditions[i].Type != workv1.WorkAvailable {
+			continue
+		}
+		for _, work := range works {
+			if meta.FindStatusCondition(work.Status.Conditions, conditions[i].Type) == nil {
+				conditions[i].Status = metav1.ConditionUnknown
+				conditions[i].Message = fmt.Sprintf("%s: condition not reported yet", work.Name)
+			}
+		}
+	}
+	primary.Status.Conditions = conditions
+}
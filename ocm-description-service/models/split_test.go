@@ -0,0 +1,162 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestSplitManifestWork(t *testing.T) {
+	workClient := newFakeWorkClient()
+	useFakeClients(t, workClient, clusterfake.NewSimpleClientset())
+	restoreAfterTest(t, &manifestWorkMaxSize)
+
+	t.Run("should keep small jobs in a single manifest work", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		works, err := GenerateManifestWorks(&j)
+		assert.NoError(t, err)
+		assert.Len(t, works, 1)
+	})
+
+	t.Run("should split, replace and delete oversized jobs as a set", func(t *testing.T) {
+		manifestWorkMaxSize = 4500
+		j := MockCreateDeploymentJob()
+
+		created, err := createManifestWork(&j)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{created.Name + "-part-1"}, j.Resource.ResourceParts)
+
+		parts, err := fetchManifestWorkParts(j.Target.ClusterName, created.Name)
+		assert.NoError(t, err)
+		assert.Len(t, parts, 1)
+		assert.Equal(t, "Service", parts[0].Spec.Workload.Manifests[0].Object.GetObjectKind().GroupVersionKind().Kind)
+		assert.Equal(t, map[string]string{PartOfLabel: created.Name, PartLabel: "1"}, parts[0].Labels)
+
+		// replacing with a smaller job removes the parts that are no longer needed
+		j.Type = ReplaceDeployment
+		j.Resource.ResourceName = created.Name
+		j.Manifests = j.Manifests[:1]
+		_, err = replaceDeployment(&j)
+		assert.NoError(t, err)
+		assert.Empty(t, j.Resource.ResourceParts)
+		parts, err = fetchManifestWorkParts(j.Target.ClusterName, created.Name)
+		assert.NoError(t, err)
+		assert.Empty(t, parts)
+
+		j.Manifests = MockCreateDeploymentJob().Manifests
+		_, err = replaceDeployment(&j)
+		assert.NoError(t, err)
+		assert.Len(t, j.Resource.ResourceParts, 1)
+
+		_, err = deleteDeployment(&j)
+		assert.NoError(t, err)
+		works, err := workClient.WorkV1().ManifestWorks(j.Target.ClusterName).List(context.TODO(), metav1.ListOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, works.Items)
+	})
+
+	t.Run("should restore the works already updated when a part fails to update", func(t *testing.T) {
+		manifestWorkMaxSize = 4500
+		j := MockCreateDeploymentJob()
+		created, err := createManifestWork(&j)
+		assert.NoError(t, err)
+		assert.Len(t, j.Resource.ResourceParts, 1)
+
+		workClient.PrependReactor("update", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			work := action.(clienttesting.UpdateAction).GetObject().(*workv1.ManifestWork)
+			if work.Name == created.Name+"-part-1" {
+				return true, nil, errors.New("etcdserver: request timed out")
+			}
+			return false, nil, nil
+		})
+		defer func() { workClient.ReactionChain = workClient.ReactionChain[1:] }()
+
+		update := MockUpdateJob(ScaleUp)
+		update.Resource.ResourceName = created.Name
+		_, err = updateDeploymentAttributes(&update)
+		assert.ErrorContains(t, err, "request timed out")
+		assert.Equal(t, Degraded, update.State)
+
+		primary, err := workClient.WorkV1().ManifestWorks(j.Target.ClusterName).Get(context.TODO(), created.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, created.Spec, primary.Spec)
+	})
+
+	t.Run("should leave room for the metadata and status of the manifest work", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		work, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
+		manifestsSize := 0
+		for _, manifest := range work.Spec.Workload.Manifests {
+			encoded, err := json.Marshal(manifest)
+			assert.NoError(t, err)
+			manifestsSize += len(encoded)
+		}
+		envelope := *work
+		envelope.Spec.Workload.Manifests = nil
+		encoded, err := json.Marshal(&envelope)
+		assert.NoError(t, err)
+
+		// the manifests fit next to the metadata, but not with the reserves for status and labels
+		manifestWorkMaxSize = len(encoded) + manifestsSize + manifestWorkMetadataReserve
+		works, err := GenerateManifestWorks(&j)
+		assert.NoError(t, err)
+		assert.Greater(t, len(works), 1)
+	})
+
+	t.Run("should reject a manifest above the size limit", func(t *testing.T) {
+		manifestWorkMaxSize = 100
+		j := MockCreateDeploymentJob()
+		_, err := GenerateManifestWorks(&j)
+		assert.ErrorContains(t, err, "above the ManifestWork size limit")
+	})
+
+	t.Run("should aggregate the status of the parts", func(t *testing.T) {
+		primary := &workv1.ManifestWork{Status: workv1.ManifestWorkStatus{Conditions: []metav1.Condition{
+			{Type: workv1.WorkApplied, Status: metav1.ConditionTrue},
+			{Type: workv1.WorkAvailable, Status: metav1.ConditionTrue},
+		}}}
+		parts := []workv1.ManifestWork{{ObjectMeta: metav1.ObjectMeta{Name: "part-1"}, Status: workv1.ManifestWorkStatus{Conditions: []metav1.Condition{
+			{Type: workv1.WorkApplied, Status: metav1.ConditionTrue},
+			{Type: workv1.WorkAvailable, Status: metav1.ConditionFalse, Message: "waiting"},
+			{Type: workv1.WorkDegraded, Status: metav1.ConditionTrue},
+		}}}}
+
+		aggregateManifestWorkStatus(primary, parts)
+		assert.Equal(t, metav1.ConditionTrue, meta.FindStatusCondition(primary.Status.Conditions, workv1.WorkApplied).Status)
+		assert.Equal(t, metav1.ConditionFalse, meta.FindStatusCondition(primary.Status.Conditions, workv1.WorkAvailable).Status)
+		assert.Equal(t, "part-1: waiting", meta.FindStatusCondition(primary.Status.Conditions, workv1.WorkAvailable).Message)
+		assert.Equal(t, metav1.ConditionTrue, meta.FindStatusCondition(primary.Status.Conditions, workv1.WorkDegraded).Status)
+	})
+}
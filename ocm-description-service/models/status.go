@@ -0,0 +1,228 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"icos/server/ocm-description-service/utils/logs"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStatusBatchSize      = 50
+	defaultStatusResendInterval = time.Hour
+	statusRequestTimeout        = 30 * time.Second
+)
+
+var (
+	// shared by every status update, so that connections to the Job Manager are reused
+	statusClient = &http.Client{Timeout: statusRequestTimeout}
+
+	// the status of every resource is sent again after this long, even if it did not change, in case
+	// the Job Manager lost it
+	statusResendInterval = getStatusResendInterval()
+
+	reportedStatus = newStatusHashStore()
+)
+
+// StatusReport is the outcome of reporting the status of resources to the Job Manager. Unchanged
+// resources were reported with the same status before and are not sent again.
+type StatusReport struct {
+	Sent      int           `json:"sent"`
+	Unchanged int           `json:"unchanged"`
+	Batches   []BatchReport `json:"batches"`
+}
+
+// BatchReport is the outcome of sending the status of a batch of resources, one update per resource.
+// StatusCode is the last response status of the Job Manager, unset when no request could be sent, and
+// Error the last failure.
+type BatchReport struct {
+	Resources  int    `json:"resources"`
+	Failed     int    `json:"failed,omitempty"`
+	Succeeded  bool   `json:"succeeded"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func getStatusBatchSize() int {
+	size, err := strconv.Atoi(os.Getenv("STATUS_BATCH_SIZE"))
+	if err != nil || size <= 0 {
+		return defaultStatusBatchSize
+	}
+	return size
+}
+
+func getStatusResendInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("STATUS_RESEND_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return defaultStatusResendInterval
+	}
+	return interval
+}
+
+// statusHashStore keeps the hash of the status last reported for each resource, by resource ID, and
+// when it was reported.
+type statusHashStore struct {
+	mu     sync.Mutex
+	hashes map[string]reportedHash
+}
+
+type reportedHash struct {
+	hash       string
+	reportedAt time.Time
+}
+
+func newStatusHashStore() *statusHashStore {
+	return &statusHashStore{hashes: map[string]reportedHash{}}
+}
+
+// changed reports whether the status must be sent: it differs from the one last reported, or that
+// one was reported more than statusResendInterval ago.
+func (s *statusHashStore) changed(id, hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reported, ok := s.hashes[id]
+	return !ok || reported.hash != hash || time.Since(reported.reportedAt) > statusResendInterval
+}
+
+func (s *statusHashStore) record(id, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hashes[id] = reportedHash{hash: hash, reportedAt: time.Now()}
+}
+
+// prune forgets the hashes that would not prevent a resend anymore, including those of the resources
+// deleted since they were reported.
+func (s *statusHashStore) prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, reported := range s.hashes {
+		if time.Since(reported.reportedAt) > statusResendInterval {
+			delete(s.hashes, id)
+		}
+	}
+}
+
+func statusHash(resource *Resource) (string, error) {
+	encoded, err := json.Marshal(resource)
+	if err != nil {
+		return "", fmt.Errorf("error encoding status of resource %s: %v", resource.ID, err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ReportResourceStatus sends the status of the resources whose status changed since it was last
+// reported to the Job Manager, or was last reported more than STATUS_RESEND_INTERVAL ago, in batches
+// of STATUS_BATCH_SIZE. The status of the resources the Job Manager did not accept is sent again on
+// the next call.
+func ReportResourceStatus(ctx context.Context, authHeader string, resources []Resource) *StatusReport {
+	reportedStatus.prune()
+	report := &StatusReport{Batches: []BatchReport{}}
+	changed := []Resource{}
+	hashes := []string{}
+	for i := range resources {
+		hash, err := statusHash(&resources[i])
+		if err != nil {
+			logs.Logger.Println(err)
+			continue
+		}
+		if !reportedStatus.changed(resources[i].ID, hash) {
+			report.Unchanged++
+			continue
+		}
+		changed = append(changed, resources[i])
+		hashes = append(hashes, hash)
+	}
+
+	batchSize := getStatusBatchSize()
+	for start := 0; start < len(changed); start += batchSize {
+		end := min(start+batchSize, len(changed))
+		batch, sent := sendStatusBatch(ctx, authHeader, changed[start:end])
+		for i, ok := range sent {
+			if ok {
+				report.Sent++
+				reportedStatus.record(changed[start+i].ID, hashes[start+i])
+			}
+		}
+		if !batch.Succeeded {
+			logs.Logger.Printf("Error reporting the status of %d resources: %s", batch.Failed, batch.Error)
+		}
+		report.Batches = append(report.Batches, batch)
+	}
+	return report
+}
+
+// sendStatusBatch sends the status of a batch of resources to the Job Manager, one update per resource
+// as the Job Manager takes no other, and reports which resources were sent.
+func sendStatusBatch(ctx context.Context, authHeader string, resources []Resource) (BatchReport, []bool) {
+	batch := BatchReport{Resources: len(resources)}
+	sent := make([]bool, len(resources))
+	for i := range resources {
+		code, failure := putStatus(ctx, authHeader, "jobmanager/resources/status", &resources[i])
+		batch.StatusCode = code
+		if failure != "" {
+			batch.Failed++
+			batch.Error = fmt.Sprintf("resource %s: %s", resources[i].ID, failure)
+			continue
+		}
+		sent[i] = true
+	}
+	batch.Succeeded = batch.Failed == 0
+	return batch, sent
+}
+
+// putStatus sends a status update to the given Job Manager endpoint and returns its response status,
+// or the reason the update failed.
+func putStatus(ctx context.Context, authHeader, path string, payload interface{}) (int, string) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Sprintf("error encoding status update: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT", jobmanagerBaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Sprintf("error creating status update request: %v", err)
+	}
+	req.Header.Add("Authorization", authHeader)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := statusClient.Do(req)
+	if err != nil {
+		return 0, fmt.Sprintf("error performing status update request: %v", err)
+	}
+	defer resp.Body.Close()
+	// drain the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Sprintf("status update rejected: %s", resp.Status)
+	}
+	return resp.StatusCode, ""
+}
@@ -0,0 +1,123 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReportResourceStatus(t *testing.T) {
+	// IDs of the resources received by the Job Manager, by request
+	var requests [][]string
+	rejected := map[string]bool{}
+	jobManager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/jobmanager/resources/status":
+			var resource Resource
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&resource))
+			requests = append(requests, []string{resource.ID})
+			if rejected[resource.ID] {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer jobManager.Close()
+	setForTest(t, &jobmanagerBaseURL, jobManager.URL+"/")
+	setForTest(t, &reportedStatus, newStatusHashStore())
+	restoreAfterTest(t, &statusResendInterval)
+	t.Setenv("STATUS_BATCH_SIZE", "2")
+
+	ids := []string{"1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d", "2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e", "3c2b1a0f-9e8d-4c7b-a6f5-e4d3c2b1a0f9"}
+	resources := []Resource{}
+	for _, id := range ids {
+		resources = append(resources, Resource{BaseUUID: BaseUUID{ID: id}, ResourceName: "nginx-" + id[:4], Conditions: []metav1.Condition{
+			{Type: workv1.WorkApplied, Status: metav1.ConditionTrue, Reason: "AppliedManifestWorkComplete"},
+		}})
+	}
+
+	t.Run("should send the status of each resource", func(t *testing.T) {
+		report := ReportResourceStatus(context.TODO(), "Bearer token", resources)
+		assert.Equal(t, 3, report.Sent)
+		assert.Equal(t, 0, report.Unchanged)
+		assert.Equal(t, []BatchReport{
+			{Resources: 2, Succeeded: true, StatusCode: http.StatusOK},
+			{Resources: 1, Succeeded: true, StatusCode: http.StatusOK},
+		}, report.Batches)
+		assert.Equal(t, [][]string{{ids[0]}, {ids[1]}, {ids[2]}}, requests)
+	})
+
+	t.Run("should only send the status of resources that changed", func(t *testing.T) {
+		requests = nil
+		report := ReportResourceStatus(context.TODO(), "Bearer token", resources)
+		assert.Equal(t, 3, report.Unchanged)
+		assert.Empty(t, report.Batches)
+		assert.Empty(t, requests)
+
+		resources[1].Conditions = append(resources[1].Conditions, metav1.Condition{Type: workv1.WorkAvailable, Status: metav1.ConditionTrue, Reason: "ResourcesAvailable"})
+		report = ReportResourceStatus(context.TODO(), "Bearer token", resources)
+		assert.Equal(t, 1, report.Sent)
+		assert.Equal(t, 2, report.Unchanged)
+		assert.Equal(t, [][]string{{ids[1]}}, requests)
+	})
+
+	t.Run("should send again the status of a rejected resource", func(t *testing.T) {
+		requests = nil
+		resources[0].Conditions[0].Status = metav1.ConditionFalse
+		resources[2].Conditions[0].Status = metav1.ConditionFalse
+		rejected[ids[0]] = true
+		report := ReportResourceStatus(context.TODO(), "Bearer token", resources)
+		assert.Equal(t, 1, report.Sent)
+		assert.Equal(t, []BatchReport{
+			{Resources: 2, Failed: 1, StatusCode: http.StatusOK, Error: "resource " + ids[0] + ": status update rejected: 503 Service Unavailable"},
+		}, report.Batches)
+
+		rejected[ids[0]] = false
+		report = ReportResourceStatus(context.TODO(), "Bearer token", resources)
+		assert.Equal(t, 1, report.Sent)
+		assert.Equal(t, [][]string{{ids[0]}, {ids[2]}, {ids[0]}}, requests)
+	})
+
+	t.Run("should send unchanged status again once the resend interval passed", func(t *testing.T) {
+		requests = nil
+		statusResendInterval = time.Millisecond
+		time.Sleep(2 * time.Millisecond)
+		report := ReportResourceStatus(context.TODO(), "Bearer token", resources[:1])
+		assert.Equal(t, 1, report.Sent)
+		assert.Equal(t, 0, report.Unchanged)
+		// the hashes of the resources not reported since, such as deleted ones, are forgotten
+		assert.Len(t, reportedStatus.hashes, 1)
+	})
+}
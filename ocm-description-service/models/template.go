@@ -0,0 +1,139 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterContext is the information about the target ManagedCluster available to manifest templates.
+type ClusterContext struct {
+	Name   string
+	Labels map[string]string
+	Claims map[string]string
+}
+
+// TemplateData is the data manifest templates are executed with.
+type TemplateData struct {
+	Params    map[string]string
+	Cluster   ClusterContext
+	Namespace string
+	JobID     string
+	AppName   string
+}
+
+// fetchClusterContext reads the name, labels and cluster claims of a ManagedCluster.
+func fetchClusterContext(clusterName string) (*ClusterContext, error) {
+	if clientsetClusterOper == nil {
+		return nil, fmt.Errorf("error obtaining managed cluster %s: cluster client is not initialised", clusterName)
+	}
+	managedCluster, err := clientsetClusterOper.ClusterV1().ManagedClusters().Get(context.TODO(), clusterName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining managed cluster %s: %v", clusterName, err)
+	}
+
+	clusterContext := &ClusterContext{
+		Name:   managedCluster.Name,
+		Labels: map[string]string{},
+		Claims: map[string]string{},
+	}
+	for key, value := range managedCluster.Labels {
+		clusterContext.Labels[key] = value
+	}
+	for _, claim := range managedCluster.Status.ClusterClaims {
+		clusterContext.Claims[claim.Name] = claim.Value
+	}
+	return clusterContext, nil
+}
+
+// cluster returns the context of the job's target cluster, fetching it only once per job.
+func (j *Job) cluster() (*ClusterContext, error) {
+	if j.clusterContext == nil {
+		clusterContext, err := fetchClusterContext(j.Target.ClusterName)
+		if err != nil {
+			return nil, err
+		}
+		j.clusterContext = clusterContext
+	}
+	return j.clusterContext, nil
+}
+
+// templateData builds the template data of a job.
+func (j *Job) templateData() (*TemplateData, error) {
+	clusterContext, err := j.cluster()
+	if err != nil {
+		return nil, err
+	}
+
+	params := j.Parameters
+	if params == nil {
+		params = map[string]string{}
+	}
+	return &TemplateData{
+		Params:    params,
+		Cluster:   *clusterContext,
+		Namespace: j.Namespace,
+		JobID:     j.ID,
+		AppName:   j.JobGroupName,
+	}, nil
+}
+
+// renderTemplate executes text as a Go template with the job's template data when the job is templated,
+// and returns it unchanged otherwise. Any variable that cannot be resolved is an error.
+func renderTemplate(j *Job, name, text string) (string, error) {
+	if !j.Templated {
+		return text, nil
+	}
+	data, err := j.templateData()
+	if err != nil {
+		return "", err
+	}
+
+	lookup := func(kind string, values map[string]string) func(string) (string, error) {
+		return func(key string) (string, error) {
+			value, ok := values[key]
+			if !ok {
+				return "", fmt.Errorf("%s %q is not defined", kind, key)
+			}
+			return value, nil
+		}
+	}
+	funcs := template.FuncMap{
+		"param": lookup("parameter", data.Params),
+		"label": lookup("cluster label", data.Cluster.Labels),
+		"claim": lookup("cluster claim", data.Cluster.Claims),
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error executing template: %v", err)
+	}
+	return buf.String(), nil
+}
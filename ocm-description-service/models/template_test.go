@@ -0,0 +1,100 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	workfake "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	useFakeClients(t, workfake.NewSimpleClientset(), clusterfake.NewSimpleClientset(&clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster1", Labels: map[string]string{"region": "eu-south"}},
+		Status: clusterv1.ManagedClusterStatus{ClusterClaims: []clusterv1.ManagedClusterClaim{
+			{Name: "arch.icos.eu", Value: "arm64"},
+		}},
+	}))
+	templatedConfigMap := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .AppName }}-{{ .Cluster.Name }}
+data:
+  region: {{ .Cluster.Labels.region }}
+  arch: {{ claim "arch.icos.eu" }}
+  level: {{ .Params.level | printf "%q" }}`
+
+	t.Run("should fill placeholders from job parameters and cluster context", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Templated = true
+		j.Parameters = map[string]string{"level": "debug"}
+		j.Manifests = []PlainManifest{{YamlString: templatedConfigMap}}
+
+		manifestWork, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
+		configMap := manifestWork.Spec.Workload.Manifests[1].Object.(*corev1.ConfigMap)
+		assert.Equal(t, "nginx-app-cluster1", configMap.Name)
+		assert.Equal(t, map[string]string{"region": "eu-south", "arch": "arm64", "level": "debug"}, configMap.Data)
+	})
+
+	t.Run("should fail on unresolved variables", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Templated = true
+		j.Manifests = []PlainManifest{{YamlString: templatedConfigMap}}
+
+		_, err := GenerateManifestWork(&j)
+		assert.ErrorContains(t, err, "manifest 0")
+		assert.ErrorContains(t, err, `map has no entry for key "level"`)
+
+		j.Parameters = map[string]string{"level": "debug"}
+		j.Manifests = []PlainManifest{{YamlString: `{{ claim "region.icos.eu" }}`}}
+		_, err = GenerateManifestWork(&j)
+		assert.ErrorContains(t, err, `cluster claim "region.icos.eu" is not defined`)
+	})
+
+	t.Run("should fail without a cluster client", func(t *testing.T) {
+		useFakeClients(t, workfake.NewSimpleClientset(), nil)
+		j := MockCreateDeploymentJob()
+		j.Templated = true
+		j.Parameters = map[string]string{"level": "debug"}
+		j.Manifests = []PlainManifest{{YamlString: templatedConfigMap}}
+
+		_, err := GenerateManifestWork(&j)
+		assert.ErrorContains(t, err, "cluster client is not initialised")
+	})
+
+	t.Run("should leave manifests untouched unless templated", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Manifests = []PlainManifest{{YamlString: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: alerts\ndata:\n  summary: '{{ $labels.instance }} is down'"}}
+
+		manifestWork, err := GenerateManifestWork(&j)
+		assert.NoError(t, err)
+		configMap := manifestWork.Spec.Workload.Manifests[1].Object.(*corev1.ConfigMap)
+		assert.Equal(t, "{{ $labels.instance }} is down", configMap.Data["summary"])
+	})
+}
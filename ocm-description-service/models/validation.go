@@ -0,0 +1,362 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"icos/server/ocm-description-service/utils/logs"
+	"os"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+var (
+	// manifest validation is enabled unless explicitly disabled
+	manifestValidationEnabled = os.Getenv("MANIFEST_VALIDATION") != "false"
+
+	// apiLifecycles lists the minor versions in which built-in APIs were introduced or removed, as
+	// published in the deprecated API migration guide of Kubernetes v1.30
+	// (https://kubernetes.io/docs/reference/using-api/deprecation-guide/). It is maintained by hand and
+	// must be updated along with the bundled schemas. Entries keyed by group/version/kind take
+	// precedence over group/version entries.
+	apiLifecycles = map[string]apiLifecycle{
+		"apps/v1beta1":                                              {removed: 16},
+		"apps/v1beta2":                                              {removed: 16},
+		"batch/v1/CronJob":                                          {introduced: 21},
+		"batch/v1beta1":                                             {removed: 25},
+		"autoscaling/v2":                                            {introduced: 23},
+		"autoscaling/v2beta1":                                       {removed: 25},
+		"autoscaling/v2beta2":                                       {removed: 26},
+		"policy/v1":                                                 {introduced: 21},
+		"policy/v1beta1":                                            {removed: 25},
+		"networking.k8s.io/v1/Ingress":                              {introduced: 19},
+		"networking.k8s.io/v1/IngressClass":                         {introduced: 19},
+		"networking.k8s.io/v1beta1":                                 {removed: 22},
+		"extensions/v1beta1":                                        {removed: 22},
+		"discovery.k8s.io/v1":                                       {introduced: 21},
+		"discovery.k8s.io/v1beta1":                                  {removed: 25},
+		"events.k8s.io/v1":                                          {introduced: 19},
+		"events.k8s.io/v1beta1":                                     {removed: 25},
+		"node.k8s.io/v1":                                            {introduced: 20},
+		"node.k8s.io/v1beta1":                                       {removed: 25},
+		"rbac.authorization.k8s.io/v1beta1":                         {removed: 22},
+		"admissionregistration.k8s.io/v1beta1":                      {removed: 22},
+		"scheduling.k8s.io/v1beta1":                                 {removed: 22},
+		"certificates.k8s.io/v1beta1":                               {removed: 22},
+		"coordination.k8s.io/v1beta1":                               {removed: 22},
+		"storage.k8s.io/v1beta1":                                    {removed: 22},
+		"storage.k8s.io/v1beta1/CSIStorageCapacity":                 {removed: 27},
+		"storage.k8s.io/v1/CSIStorageCapacity":                      {introduced: 24},
+		"flowcontrol.apiserver.k8s.io/v1":                           {introduced: 29},
+		"flowcontrol.apiserver.k8s.io/v1beta1":                      {removed: 26},
+		"flowcontrol.apiserver.k8s.io/v1beta2":                      {removed: 29},
+		"flowcontrol.apiserver.k8s.io/v1beta3":                      {removed: 32},
+		"admissionregistration.k8s.io/v1/ValidatingAdmissionPolicy": {introduced: 30},
+	}
+)
+
+type apiLifecycle struct {
+	introduced uint
+	removed    uint
+}
+
+// ValidationError is a field-level problem found in one document of a job's manifests.
+type ValidationError struct {
+	// Source is the part of the job the document comes from, e.g. manifests[0], chart or kustomization
+	Source   string `json:"source"`
+	Document int    `json:"document"`
+	Kind     string `json:"kind,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	object := e.Kind
+	if e.Name != "" {
+		object += "/" + e.Name
+	}
+	message := e.Message
+	if e.Field != "" {
+		message = e.Field + ": " + message
+	}
+	return fmt.Sprintf("%s document %d %s: %s", e.Source, e.Document, object, message)
+}
+
+// ValidationReport is the result of validating a job's manifests against the schema of a Kubernetes
+// version.
+type ValidationReport struct {
+	Valid         bool              `json:"valid"`
+	KubeVersion   string            `json:"kube_version,omitempty"`
+	SchemaVersion string            `json:"schema_version"`
+	Errors        []ValidationError `json:"errors,omitempty"`
+	// Unvalidated are the objects whose kind the schema does not describe, such as custom resources,
+	// which are only checked for their name and API version
+	Unvalidated []ValidationError `json:"unvalidated,omitempty"`
+}
+
+// ValidateJob checks every object the job would deploy against the Kubernetes schema of kubeVersion,
+// and against the APIs served by that version. An empty kubeVersion validates against the newest
+// schema, and a version without a bundled schema or one in MANIFEST_SCHEMA_DIR fails with
+// ErrSchemaNotFound. Objects of kinds the schema does not describe are reported as
+// unvalidated rather than invalid.
+func ValidateJob(j *Job, kubeVersion string) (*ValidationReport, error) {
+	minor, err := parseKubeMinor(kubeVersion)
+	if err != nil {
+		return nil, err
+	}
+	kubeSchema, err := loadSchema(minor)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ValidationReport{KubeVersion: kubeVersion, SchemaVersion: fmt.Sprintf("v1.%d", kubeSchema.minor)}
+	minor = kubeSchema.minor
+	validate := func(source, yamlString string) {
+		validationErrors, unvalidated := validateYAML(source, yamlString, kubeSchema, minor)
+		report.Errors = append(report.Errors, validationErrors...)
+		report.Unvalidated = append(report.Unvalidated, unvalidated...)
+	}
+
+	for i, stringManifest := range j.Manifests {
+		source := fmt.Sprintf("manifests[%d]", i)
+		yamlString, err := renderTemplate(j, fmt.Sprintf("manifest-%d", i), stringManifest.YamlString)
+		if err != nil {
+			report.Errors = append(report.Errors, ValidationError{Source: source, Message: err.Error()})
+			continue
+		}
+		validate(source, yamlString)
+	}
+
+	if j.Chart != nil {
+		manifest, err := renderHelmManifest(j)
+		if err != nil {
+			report.Errors = append(report.Errors, ValidationError{Source: "chart", Message: err.Error()})
+		} else {
+			validate("chart", manifest)
+		}
+	}
+
+	// the overlay can only be built once its base is valid
+	if j.Kustomization != nil && len(report.Errors) == 0 {
+		output, err := buildJobKustomization(j)
+		if err != nil {
+			report.Errors = append(report.Errors, ValidationError{Source: "kustomization", Message: err.Error()})
+		} else {
+			validate("kustomization", string(output))
+		}
+	}
+
+	report.Valid = len(report.Errors) == 0
+	return report, nil
+}
+
+// preflightValidation validates the job's manifests against the target cluster's Kubernetes version
+// before any ManifestWork is created or updated.
+func preflightValidation(j *Job) error {
+	if !manifestValidationEnabled {
+		return nil
+	}
+
+	kubeVersion, err := FetchClusterKubeVersion(j.Target.ClusterName)
+	if err != nil {
+		logs.Logger.Println("Could not obtain cluster version, validating against the bundled schema:", err)
+	}
+
+	report, err := ValidateJob(j, kubeVersion)
+	if err != nil {
+		return err
+	}
+	for _, unvalidated := range report.Unvalidated {
+		logs.Logger.Printf("Validating Job %s: %s", j.ID, unvalidated.Error())
+	}
+	if !report.Valid {
+		messages := make([]string, 0, len(report.Errors))
+		for _, validationError := range report.Errors {
+			messages = append(messages, validationError.Error())
+		}
+		return fmt.Errorf("manifest validation failed against Kubernetes %s: %s", report.SchemaVersion, strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// FetchClusterKubeVersion returns the Kubernetes version reported by a ManagedCluster.
+func FetchClusterKubeVersion(clusterName string) (string, error) {
+	if clientsetClusterOper == nil {
+		return "", fmt.Errorf("error obtaining managed cluster %s: cluster client is not initialised", clusterName)
+	}
+	managedCluster, err := clientsetClusterOper.ClusterV1().ManagedClusters().Get(context.TODO(), clusterName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return managedCluster.Status.Version.Kubernetes, nil
+}
+
+// buildJobKustomization decodes the job's base objects and builds its kustomization.
+func buildJobKustomization(j *Job) ([]byte, error) {
+	base := []runtime.Object{}
+	for i, stringManifest := range j.Manifests {
+		yamlString, err := renderTemplate(j, fmt.Sprintf("manifest-%d", i), stringManifest.YamlString)
+		if err != nil {
+			return nil, fmt.Errorf("manifest %d: %v", i, err)
+		}
+		objs, err := decodeYAMLDocuments(yamlString)
+		if err != nil {
+			return nil, fmt.Errorf("manifest %d: %v", i, err)
+		}
+		base = append(base, objs...)
+	}
+	if j.Chart != nil {
+		objs, err := renderHelmChart(j)
+		if err != nil {
+			return nil, err
+		}
+		base = append(base, objs...)
+	}
+	return buildKustomizeOverlay(j.Kustomization, base)
+}
+
+// validateYAML validates every document of a multi-document YAML string, returning the problems found
+// and the documents whose kind the schema does not describe.
+func validateYAML(source, yamlString string, kubeSchema *kubeSchema, minor uint) (validationErrors, unvalidated []ValidationError) {
+	documents, err := splitYAMLDocuments(yamlString)
+	if err != nil {
+		return []ValidationError{{Source: source, Message: err.Error()}}, nil
+	}
+
+	for index, document := range documents {
+		if document == nil {
+			continue
+		}
+		documentErrors, notInSchema := validateDocument(source, index, document, kubeSchema, minor)
+		validationErrors = append(validationErrors, documentErrors...)
+		if notInSchema != nil {
+			unvalidated = append(unvalidated, *notInSchema)
+		}
+	}
+	return validationErrors, unvalidated
+}
+
+// validateDocument validates a single YAML document against the schema and the served APIs. When the
+// schema does not describe its kind, only the name and API version are checked and the document is
+// returned as unvalidated.
+func validateDocument(source string, index int, document []byte, kubeSchema *kubeSchema, minor uint) ([]ValidationError, *ValidationError) {
+	newError := func(field, message string) ValidationError {
+		return ValidationError{Source: source, Document: index, Field: field, Message: message}
+	}
+
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal(document, &obj); err != nil {
+		return []ValidationError{newError("", err.Error())}, nil
+	}
+
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	if apiVersion == "" || kind == "" {
+		return []ValidationError{newError("", "apiVersion and kind are required")}, nil
+	}
+	gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+
+	name := ""
+	generateName := ""
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		name, _ = metadata["name"].(string)
+		generateName, _ = metadata["generateName"].(string)
+	}
+
+	validationErrors := []ValidationError{}
+	addError := func(field, message string) {
+		validationError := newError(field, message)
+		validationError.Kind = kind
+		validationError.Name = name
+		validationErrors = append(validationErrors, validationError)
+	}
+
+	if name == "" && generateName == "" {
+		addError(".metadata.name", "name or generateName is required")
+	}
+
+	if message := checkAPIServed(gvk, minor); message != "" {
+		addError(".apiVersion", message)
+	}
+
+	parseableType, found, groupKnown := kubeSchema.typeOf(gvk)
+	if !found {
+		if groupKnown {
+			addError(".kind", fmt.Sprintf("%s is not a known kind of %s", kind, apiVersion))
+			return validationErrors, nil
+		}
+		unvalidated := newError("", fmt.Sprintf("no schema for %s %s, only its name and API version were checked", apiVersion, kind))
+		unvalidated.Kind = kind
+		unvalidated.Name = name
+		return validationErrors, &unvalidated
+	}
+
+	if _, err := parseableType.FromUnstructured(obj); err != nil {
+		if fieldErrors, ok := err.(typed.ValidationErrors); ok {
+			for _, fieldError := range fieldErrors {
+				addError(fieldError.Path, fieldError.ErrorMessage)
+			}
+		} else {
+			addError("", err.Error())
+		}
+	}
+	return validationErrors, nil
+}
+
+// checkAPIServed returns why the API of gvk is not served by the given minor version, if it is not.
+func checkAPIServed(gvk schema.GroupVersionKind, minor uint) string {
+	groupVersion := gvk.GroupVersion().String()
+	lifecycle, ok := apiLifecycles[groupVersion+"/"+gvk.Kind]
+	if !ok {
+		lifecycle, ok = apiLifecycles[groupVersion]
+	}
+	if !ok {
+		return ""
+	}
+	if lifecycle.introduced != 0 && minor < lifecycle.introduced {
+		return fmt.Sprintf("%s %s is not served by Kubernetes v1.%d (introduced in v1.%d)", groupVersion, gvk.Kind, minor, lifecycle.introduced)
+	}
+	if lifecycle.removed != 0 && minor >= lifecycle.removed {
+		return fmt.Sprintf("%s %s is not served by Kubernetes v1.%d (removed in v1.%d)", groupVersion, gvk.Kind, minor, lifecycle.removed)
+	}
+	return ""
+}
+
+// parseKubeMinor returns the minor version of a Kubernetes version string such as v1.28.3+k3s1,
+// or 0 when no version is given.
+func parseKubeMinor(kubeVersion string) (uint, error) {
+	if kubeVersion == "" {
+		return 0, nil
+	}
+	parsed, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Kubernetes version %q: %v", kubeVersion, err)
+	}
+	return parsed.Minor(), nil
+}
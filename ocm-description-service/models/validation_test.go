@@ -0,0 +1,133 @@
+/*
+  OCM-DESCRIPTION-SERVICE
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateJob(t *testing.T) {
+	t.Run("should accept valid manifests", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		report, err := ValidateJob(&j, "v1.29.4")
+		assert.NoError(t, err)
+		assert.True(t, report.Valid, "%v", report.Errors)
+		assert.Equal(t, "v1.29", report.SchemaVersion)
+
+		report, err = ValidateJob(&j, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.30", report.SchemaVersion)
+	})
+
+	t.Run("should report unknown fields with their path", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Manifests = []PlainManifest{{YamlString: mockServiceYaml + "\n---\n" + strings.Replace(mockDeploymentYaml, "image:", "imagee:", 1)}}
+		report, err := ValidateJob(&j, "")
+		assert.NoError(t, err)
+		assert.False(t, report.Valid)
+		assert.Len(t, report.Errors, 1)
+		assert.Equal(t, 1, report.Errors[0].Document)
+		assert.Equal(t, "Deployment", report.Errors[0].Kind)
+		assert.Contains(t, report.Errors[0].Field, "imagee")
+	})
+
+	t.Run("should reject APIs not served by the cluster version", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Manifests = []PlainManifest{{YamlString: "apiVersion: flowcontrol.apiserver.k8s.io/v1\nkind: FlowSchema\nmetadata:\n  name: backup\nspec:\n  matchingPrecedence: 1000\n  priorityLevelConfiguration:\n    name: workload-low"}}
+		report, err := ValidateJob(&j, "v1.28.15")
+		assert.NoError(t, err)
+		assert.False(t, report.Valid)
+		assert.Contains(t, report.Errors[0].Message, "introduced in v1.29")
+
+		report, err = ValidateJob(&j, "v1.29.0+k3s1")
+		assert.NoError(t, err)
+		assert.True(t, report.Valid, "%v", report.Errors)
+	})
+
+	t.Run("should report custom resources as unvalidated", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Manifests = append(j.Manifests, PlainManifest{YamlString: "apiVersion: monitoring.coreos.com/v1\nkind: ServiceMonitor\nmetadata:\n  name: nginx\nspec:\n  endpoints:\n  - port: http"})
+		report, err := ValidateJob(&j, "")
+		assert.NoError(t, err)
+		assert.True(t, report.Valid, "%v", report.Errors)
+		assert.Len(t, report.Unvalidated, 1)
+		assert.Equal(t, "ServiceMonitor", report.Unvalidated[0].Kind)
+		assert.Equal(t, "manifests[2]", report.Unvalidated[0].Source)
+	})
+
+	t.Run("should reject unknown kinds of built-in APIs", func(t *testing.T) {
+		j := MockCreateDeploymentJob()
+		j.Manifests = []PlainManifest{{YamlString: strings.Replace(mockDeploymentYaml, "kind: Deployment", "kind: Deploymnt", 1)}}
+		report, err := ValidateJob(&j, "")
+		assert.NoError(t, err)
+		assert.False(t, report.Valid)
+		assert.Contains(t, report.Errors[0].Message, "Deploymnt is not a known kind of apps/v1")
+	})
+
+	t.Run("should validate against the schema of the cluster version", func(t *testing.T) {
+		schemaDir := t.TempDir()
+		assert.NoError(t, os.Mkdir(filepath.Join(schemaDir, "v1.28"), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(schemaDir, "v1.28", "apis_example.com_v1.json"), []byte(`{"components": {"schemas": {
+			"com.example.v1.Database": {
+				"type": "object",
+				"properties": {
+					"apiVersion": {"type": "string"},
+					"kind": {"type": "string"},
+					"metadata": {"type": "object", "x-kubernetes-preserve-unknown-fields": true},
+					"spec": {"type": "object", "properties": {"instances": {"type": "integer"}}}
+				},
+				"x-kubernetes-group-version-kind": [{"group": "example.com", "version": "v1", "kind": "Database"}]
+			}
+		}}}`), 0o644))
+		setForTest(t, &manifestSchemaDir, schemaDir)
+		setForTest(t, &loadedSchemas, map[uint]*kubeSchema{})
+
+		j := MockCreateDeploymentJob()
+		j.Manifests = []PlainManifest{{YamlString: "apiVersion: example.com/v1\nkind: Database\nmetadata:\n  name: db\nspec:\n  instances: 3\n  replicas: 3"}}
+		report, err := ValidateJob(&j, "v1.28.7")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.28", report.SchemaVersion)
+		assert.False(t, report.Valid)
+		assert.Contains(t, report.Errors[0].Field, "replicas")
+
+		report, err = ValidateJob(&j, "v1.27.2")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.27", report.SchemaVersion)
+		assert.True(t, report.Valid, "%v", report.Errors)
+		assert.Len(t, report.Unvalidated, 1)
+	})
+
+	t.Run("should fail without a schema of the cluster version", func(t *testing.T) {
+		setForTest(t, &manifestSchemaDir, t.TempDir())
+
+		j := MockCreateDeploymentJob()
+		_, err := ValidateJob(&j, "v1.31.0")
+		assert.ErrorIs(t, err, ErrSchemaNotFound)
+
+		_, err = ValidateJob(&j, "v1.20.15")
+		assert.ErrorIs(t, err, ErrSchemaNotFound)
+	})
+}